@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"fiber-learning-community/internal/bookimport"
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/handlers"
+	"fiber-learning-community/internal/models"
+)
+
+// runCommand chạy lệnh quản trị khi binary được gọi kèm tên lệnh, vd. `go run . import-book ...`.
+// ok = false nếu args không phải lệnh nào, khi đó main khởi động web server như bình thường.
+func runCommand(args []string) (code int, ok bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "import-book":
+		return importBookCommand(args[1:]), true
+	}
+	return 0, false
+}
+
+// importBookCommand nhập sách từ file ZIP Markdown cho tài khoản có email -author.
+func importBookCommand(args []string) int {
+	flags := flag.NewFlagSet("import-book", flag.ContinueOnError)
+	author := flags.String("author", "", "email của tác giả (bắt buộc)")
+	file := flags.String("file", "", "đường dẫn file ZIP (bắt buộc)")
+	title := flags.String("title", "", "tiêu đề sách, ghi đè title trong book.yml")
+	description := flags.String("description", "", "mô tả sách, ghi đè description trong book.yml")
+	category := flags.String("category", "", "danh mục sách")
+	tag := flags.String("tag", "", "tag sách")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Cách dùng: import-book -author email -file sach.zip [-title ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" && flags.NArg() == 1 {
+		*file = flags.Arg(0)
+	}
+	if *author == "" || *file == "" {
+		flags.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Không đọc được file: %v\n", err)
+		return 1
+	}
+
+	db := database.Init()
+	var user models.User
+	if err := db.Where("email = ?", strings.ToLower(strings.TrimSpace(*author))).First(&user).Error; err != nil {
+		fmt.Fprintf(os.Stderr, "Không tìm thấy tài khoản %s: %v\n", *author, err)
+		return 1
+	}
+
+	result, err := handlers.ImportBookArchive(db, &user, data, handlers.BookImportOptions{
+		Title:       *title,
+		Description: *description,
+		Category:    *category,
+		Tag:         *tag,
+	})
+	var importErr bookimport.Error
+	if errors.As(err, &importErr) {
+		fmt.Fprintln(os.Stderr, importErr.Error())
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Lỗi nhập sách: %v\n", err)
+		return 1
+	}
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "Cảnh báo: %s\n", warning)
+	}
+	fmt.Printf("Đã nhập sách #%d \"%s\" (/books/%s): %d trang, %d chương, %d ảnh\n",
+		result.Book.ID, result.Book.Title, result.Book.Slug, result.Pages, result.Chapters, result.Images)
+	return 0
+}
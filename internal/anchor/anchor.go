@@ -0,0 +1,269 @@
+// Package anchor gắn ghi chú (chú thích, bình luận theo dòng, highlight trong sách) vào nội dung
+// bằng text-quote selector thay vì chỉ số dòng/offset thô, để ghi chú không bị lệch khi nội dung được sửa.
+package anchor
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// contextLength là số ký tự ngữ cảnh lấy từ khối liền trước/liền sau.
+const contextLength = 32
+
+// minFuzzyScore là độ tương đồng tối thiểu để gắn lại vào một khối đã bị sửa câu chữ.
+const minFuzzyScore = 0.6
+
+// Block là một "dòng" hiển thị trong bài viết, đánh số giống script ở trang chi tiết.
+type Block struct {
+	Line int    // Số thứ tự bắt đầu từ 1
+	Text string // Văn bản đã chuẩn hóa khoảng trắng
+	Hash string // ID theo nội dung của khối
+}
+
+// Selector mô tả vị trí ghi chú bằng nội dung khối và ngữ cảnh xung quanh.
+type Selector struct {
+	Hash   string
+	Exact  string
+	Prefix string
+	Suffix string
+}
+
+// IsZero cho biết selector chưa được tính (dữ liệu cũ chỉ có số dòng).
+func (s Selector) IsZero() bool {
+	return s.Hash == "" && s.Exact == ""
+}
+
+var blockTags = map[string]bool{
+	"p": true, "pre": true, "blockquote": true, "li": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+var mediaTags = map[string]bool{"img": true, "figure": true, "video": true, "iframe": true}
+
+// Normalize gộp khoảng trắng liên tiếp và cắt hai đầu, dùng chung khi so khớp.
+func Normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// HashText trả về ID theo nội dung của một khối văn bản.
+func HashText(text string) string {
+	sum := sha1.Sum([]byte(Normalize(text)))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// Blocks tách HTML thành các dòng theo đúng thứ tự duyệt của trình duyệt:
+// mọi thẻ p, pre, blockquote, h1-h6, li (kể cả lồng nhau), tách tiếp theo <br> trực tiếp,
+// bỏ qua dòng rỗng trừ khi có ảnh/video.
+func Blocks(htmlContent string) []Block {
+	root, err := xhtml.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil
+	}
+
+	var blocks []Block
+	add := func(text string, hasMedia bool) {
+		text = Normalize(text)
+		if text == "" && !hasMedia {
+			return
+		}
+		blocks = append(blocks, Block{Line: len(blocks) + 1, Text: text, Hash: HashText(text)})
+	}
+
+	var walk func(*xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		if n.Type == xhtml.ElementNode && blockTags[n.Data] {
+			if containsBr(n) {
+				var part strings.Builder
+				partMedia := false
+				for child := n.FirstChild; child != nil; child = child.NextSibling {
+					if child.Type == xhtml.ElementNode && child.Data == "br" {
+						add(part.String(), partMedia)
+						part.Reset()
+						partMedia = false
+						continue
+					}
+					part.WriteString(textContent(child))
+					partMedia = partMedia || hasMedia(child)
+				}
+				add(part.String(), partMedia)
+			} else {
+				add(textContent(n), hasMedia(n))
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+	return blocks
+}
+
+// At tạo selector cho dòng line (bắt đầu từ 1).
+func At(blocks []Block, line int) (Selector, bool) {
+	if line < 1 || line > len(blocks) {
+		return Selector{}, false
+	}
+	b := blocks[line-1]
+	sel := Selector{Hash: b.Hash, Exact: b.Text}
+	if line > 1 {
+		sel.Prefix = tail(blocks[line-2].Text, contextLength)
+	}
+	if line < len(blocks) {
+		sel.Suffix = head(blocks[line].Text, contextLength)
+	}
+	return sel, true
+}
+
+// Locate tìm dòng cho ghi chú mới. Nếu client gửi kèm đoạn trích (quote) thì ưu tiên
+// khối có cùng nội dung gần line nhất, vì cách đánh số phía client có thể lệch đôi chút.
+func Locate(blocks []Block, line int, quote string) (int, Selector, bool) {
+	if quote = Normalize(quote); quote != "" {
+		hash := HashText(quote)
+		best := 0
+		for _, b := range blocks {
+			if b.Hash == hash && (best == 0 || abs(b.Line-line) < abs(best-line)) {
+				best = b.Line
+			}
+		}
+		if best != 0 {
+			sel, _ := At(blocks, best)
+			return best, sel, true
+		}
+	}
+	sel, ok := At(blocks, line)
+	return line, sel, ok
+}
+
+// Resolve tìm lại dòng của selector trong nội dung mới.
+// hint là số dòng cũ, dùng để phân xử khi có nhiều khối giống nhau.
+// exact=false nghĩa là khối đã bị sửa câu chữ và được gắn lại theo độ tương đồng.
+func Resolve(blocks []Block, sel Selector, hint int) (line int, exact bool, ok bool) {
+	hash := sel.Hash
+	if hash == "" {
+		hash = HashText(sel.Exact)
+	}
+
+	bestScore := -1
+	for _, b := range blocks {
+		if b.Hash != hash {
+			continue
+		}
+		score := contextScore(blocks, b.Line, sel)
+		if score > bestScore || (score == bestScore && abs(b.Line-hint) < abs(line-hint)) {
+			bestScore = score
+			line = b.Line
+		}
+	}
+	if line != 0 {
+		return line, true, true
+	}
+
+	exactText := Normalize(sel.Exact)
+	if exactText == "" {
+		return 0, false, false
+	}
+	bestSimilarity := 0.0
+	for _, b := range blocks {
+		similarity := diceSimilarity(exactText, b.Text)
+		// Ngữ cảnh khớp giúp phân biệt các đoạn na ná nhau
+		similarity += 0.1 * float64(contextScore(blocks, b.Line, sel))
+		if similarity > bestSimilarity || (similarity == bestSimilarity && line != 0 && abs(b.Line-hint) < abs(line-hint)) {
+			bestSimilarity = similarity
+			line = b.Line
+		}
+	}
+	if bestSimilarity >= minFuzzyScore {
+		return line, false, true
+	}
+	return 0, false, false
+}
+
+func contextScore(blocks []Block, line int, sel Selector) int {
+	score := 0
+	if sel.Prefix != "" && line > 1 && strings.HasSuffix(blocks[line-2].Text, sel.Prefix) {
+		score++
+	}
+	if sel.Suffix != "" && line < len(blocks) && strings.HasPrefix(blocks[line].Text, sel.Suffix) {
+		score++
+	}
+	return score
+}
+
+// diceSimilarity so sánh hai đoạn theo tập từ (hệ số Sørensen–Dice).
+func diceSimilarity(a, b string) float64 {
+	wordsA := strings.Fields(strings.ToLower(a))
+	wordsB := strings.Fields(strings.ToLower(b))
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(wordsA))
+	for _, w := range wordsA {
+		counts[w]++
+	}
+	common := 0
+	for _, w := range wordsB {
+		if counts[w] > 0 {
+			counts[w]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(wordsA)+len(wordsB))
+}
+
+func containsBr(n *xhtml.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xhtml.ElementNode && (child.Data == "br" || containsBr(child)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMedia(n *xhtml.Node) bool {
+	if n.Type == xhtml.ElementNode && mediaTags[n.Data] {
+		return true
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if hasMedia(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func textContent(n *xhtml.Node) string {
+	if n.Type == xhtml.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+func head(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func tail(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[len(runes)-n:])
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,135 @@
+package anchor
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// QuoteContextLength là số ký tự ngữ cảnh lưu trước/sau đoạn được chọn.
+const QuoteContextLength = 32
+
+// TextQuote tương ứng TextQuoteSelector của W3C Web Annotation:
+// đoạn văn bản chính xác cùng một ít ngữ cảnh đứng trước và đứng sau.
+type TextQuote struct {
+	Exact  string
+	Prefix string
+	Suffix string
+}
+
+// Trimmed cắt ngữ cảnh client gửi lên về đúng độ dài lưu trữ.
+func (q TextQuote) Trimmed() TextQuote {
+	q.Prefix = tail(q.Prefix, QuoteContextLength)
+	q.Suffix = head(q.Suffix, QuoteContextLength)
+	return q
+}
+
+// PlainText trả về văn bản thô của một trang giống textContent phía trình duyệt,
+// bỏ qua phần ghi chú của highlight (span.highlight-note) vốn bị ẩn khi đọc.
+func PlainText(htmlContent string) string {
+	root, err := xhtml.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	var walk func(*xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		if n.Type == xhtml.TextNode {
+			sb.WriteString(n.Data)
+			return
+		}
+		if n.Type == xhtml.ElementNode && (isHighlightNote(n) || n.Data == "script" || n.Data == "style") {
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+	return sb.String()
+}
+
+// QuoteAt dựng selector cho đoạn [start, end) của text.
+// Offset tính theo đơn vị UTF-16 như String.length trong JavaScript.
+func QuoteAt(text string, start, end int) (TextQuote, bool) {
+	units := utf16.Encode([]rune(text))
+	if start < 0 || end > len(units) || start >= end {
+		return TextQuote{}, false
+	}
+	return TextQuote{
+		Exact:  string(utf16.Decode(units[start:end])),
+		Prefix: string(utf16.Decode(units[max(0, start-QuoteContextLength):start])),
+		Suffix: string(utf16.Decode(units[end:min(len(units), end+QuoteContextLength)])),
+	}, true
+}
+
+// FindQuote tìm lại vị trí của selector trong text.
+// Khi đoạn trích xuất hiện nhiều lần, chọn chỗ có ngữ cảnh khớp nhiều nhất,
+// rồi tới chỗ gần hint (offset cũ) nhất. ok=false nghĩa là đoạn trích không còn trong trang.
+func FindQuote(text string, q TextQuote, hint int) (start, end int, ok bool) {
+	exact := utf16.Encode([]rune(q.Exact))
+	if len(exact) == 0 {
+		return 0, 0, false
+	}
+	units := utf16.Encode([]rune(text))
+	prefix := utf16.Encode([]rune(q.Prefix))
+	suffix := utf16.Encode([]rune(q.Suffix))
+
+	bestScore := -1
+	for i := 0; i+len(exact) <= len(units); i++ {
+		if !equalUnits(units[i:i+len(exact)], exact) {
+			continue
+		}
+		score := commonSuffix(units[:i], prefix) + commonPrefix(units[i+len(exact):], suffix)
+		if score > bestScore || (score == bestScore && abs(i-hint) < abs(start-hint)) {
+			bestScore = score
+			start = i
+		}
+	}
+	if bestScore < 0 {
+		return 0, 0, false
+	}
+	return start, start + len(exact), true
+}
+
+func isHighlightNote(n *xhtml.Node) bool {
+	if n.Data != "span" {
+		return false
+	}
+	for _, attr := range n.Attr {
+		if attr.Key == "class" {
+			for _, class := range strings.Fields(attr.Val) {
+				if class == "highlight-note" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func equalUnits(a, b []uint16) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func commonPrefix(a, b []uint16) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func commonSuffix(a, b []uint16) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
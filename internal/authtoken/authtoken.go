@@ -0,0 +1,102 @@
+// Package authtoken tạo và kiểm tra token ký HMAC, có hạn dùng, cho các link gửi qua email
+// (xác minh email, đặt lại mật khẩu). Token không lưu trong DB; "dấu vân tay" gắn với trạng thái
+// hiện tại của tài khoản khiến token tự mất hiệu lực khi trạng thái đó thay đổi (vd. đã đổi mật khẩu).
+package authtoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mục đích của token, để token xác minh email không dùng được cho đặt lại mật khẩu và ngược lại.
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+)
+
+var (
+	ErrInvalid = errors.New("token không hợp lệ")
+	ErrExpired = errors.New("token đã hết hạn")
+)
+
+type claims struct {
+	Purpose     string `json:"p"`
+	UserID      uint   `json:"u"`
+	ExpiresAt   int64  `json:"e"`
+	Fingerprint string `json:"f"`
+}
+
+var (
+	secret     []byte
+	secretOnce sync.Once
+)
+
+// key đọc APP_SECRET; nếu chưa cấu hình thì sinh khóa ngẫu nhiên (token mất hiệu lực khi khởi động lại).
+func key() []byte {
+	secretOnce.Do(func() {
+		if value := strings.TrimSpace(os.Getenv("APP_SECRET")); value != "" {
+			secret = []byte(value)
+			return
+		}
+		log.Println("⚠️  APP_SECRET chưa được cấu hình, link trong email sẽ hết hiệu lực khi khởi động lại")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	})
+	return secret
+}
+
+// Fingerprint rút gọn trạng thái tài khoản (vd. hash mật khẩu, email) để nhúng vào token.
+func Fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// Sign tạo token cho userID với mục đích purpose, hết hạn sau ttl.
+func Sign(purpose string, userID uint, fingerprint string, ttl time.Duration) string {
+	payload, _ := json.Marshal(claims{
+		Purpose:     purpose,
+		UserID:      userID,
+		ExpiresAt:   time.Now().Add(ttl).Unix(),
+		Fingerprint: fingerprint,
+	})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signature(encoded)
+}
+
+// Parse kiểm tra chữ ký, mục đích, hạn dùng và trả về userID cùng dấu vân tay đã nhúng.
+// Người gọi phải so dấu vân tay với trạng thái hiện tại của tài khoản.
+func Parse(token, purpose string) (userID uint, fingerprint string, err error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signature(encoded))) {
+		return 0, "", ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, "", ErrInvalid
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Purpose != purpose || c.UserID == 0 {
+		return 0, "", ErrInvalid
+	}
+	if time.Now().Unix() > c.ExpiresAt {
+		return 0, "", ErrExpired
+	}
+	return c.UserID, c.Fingerprint, nil
+}
+
+func signature(encoded string) string {
+	mac := hmac.New(sha256.New, key())
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
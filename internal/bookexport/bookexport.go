@@ -0,0 +1,282 @@
+// Package bookexport xuất sách ra EPUB 3 và một file HTML tối ưu cho in (Ctrl+P → Lưu PDF).
+// Nội dung trang được sanitize, chuẩn hóa thành XHTML và nhúng ảnh /images/:id vào file xuất.
+package bookexport
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Book là dữ liệu đầu vào của một lần xuất.
+type Book struct {
+	ID          uint
+	Title       string
+	Author      string
+	Description string
+	Language    string
+	Modified    time.Time
+	CoverURL    string // Ảnh bìa; EPUB chỉ nhúng được ảnh trong kho /images/:id
+	CoverColor  string // Màu nền để vẽ bìa khi không có ảnh nhúng được
+	BaseURL     string // Dùng để đổi link tương đối (/books/...) thành tuyệt đối
+	Pages       []Page
+	TOC         []TOCEntry
+}
+
+// Page là một trang theo thứ tự đọc; Content là HTML do tác giả soạn.
+type Page struct {
+	ID      uint
+	Title   string
+	Content string
+}
+
+// TOCEntry là một mục mục lục; mục có Children là chương, PageID là trang mở đầu của mục.
+type TOCEntry struct {
+	Title    string
+	PageID   uint
+	Children []TOCEntry
+}
+
+// Image là ảnh lấy từ kho ảnh để nhúng vào file xuất.
+type Image struct {
+	ContentType string
+	Data        []byte
+}
+
+// ImageLoader tải ảnh theo ID; trả lỗi nếu ảnh không còn.
+type ImageLoader func(id uint) (*Image, error)
+
+// contentPolicy giữ lại định dạng mà trình soạn thảo sách tạo ra và bỏ script, iframe, handler sự kiện...
+var contentPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowRelativeURLs(true)
+	p.AllowImages()
+	p.AllowAttrs("src", "alt", "title", "width", "height").OnElements("img")
+	p.AllowElements("font", "u", "s", "mark")
+	p.AllowAttrs("color").OnElements("font")
+	p.AllowStyles("color", "background-color", "text-align", "font-size", "font-weight", "font-style", "text-decoration").Globally()
+	return p
+}()
+
+var imagePathPattern = regexp.MustCompile(`^/images/(\d+)$`)
+
+// imageID trả về ID ảnh nếu src trỏ tới kho ảnh của site (đường dẫn tương đối hoặc tuyệt đối cùng host).
+func imageID(src, baseURL string) uint {
+	parsed, err := url.Parse(strings.TrimSpace(src))
+	if err != nil {
+		return 0
+	}
+	if parsed.Host != "" {
+		base, err := url.Parse(baseURL)
+		if err != nil || base.Host != parsed.Host {
+			return 0
+		}
+	}
+	match := imagePathPattern.FindStringSubmatch(parsed.Path)
+	if match == nil {
+		return 0
+	}
+	id, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+var imageExtensions = map[string]string{
+	"image/jpeg":    "jpg",
+	"image/png":     "png",
+	"image/gif":     "gif",
+	"image/webp":    "webp",
+	"image/svg+xml": "svg",
+}
+
+// imageRewriter quyết định src mới cho ảnh; trả "" để thay ảnh bằng chữ alt.
+type imageRewriter func(src string) string
+
+// toXHTML sanitize content rồi serialize lại dưới dạng XHTML hợp lệ (thẻ rỗng tự đóng, thuộc tính có giá trị).
+func toXHTML(content, baseURL string, rewrite imageRewriter) (string, error) {
+	clean := contentPolicy.Sanitize(content)
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(clean), context)
+	if err != nil {
+		return "", err
+	}
+
+	// Gắn các node vào context để ảnh ở cấp ngoài cùng cũng thay được bằng chữ alt.
+	for _, node := range nodes {
+		context.AppendChild(node)
+	}
+	normalizeNode(context, baseURL, rewrite)
+
+	var buf bytes.Buffer
+	for node := context.FirstChild; node != nil; node = node.NextSibling {
+		if err := html.Render(&buf, node); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func normalizeNode(node *html.Node, baseURL string, rewrite imageRewriter) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		normalizeNode(child, baseURL, rewrite)
+		child = next
+	}
+	if node.Type != html.ElementNode {
+		return
+	}
+
+	switch node.DataAtom {
+	case atom.Img:
+		src := attr(node, "src")
+		if newSrc := rewrite(src); newSrc != "" {
+			setAttr(node, "src", newSrc)
+			if attr(node, "alt") == "" {
+				setAttr(node, "alt", "")
+			}
+			return
+		}
+		// Ảnh không nhúng được: giữ lại chú thích để người đọc biết có hình ở đây.
+		alt := strings.TrimSpace(attr(node, "alt"))
+		if alt == "" {
+			alt = "hình ảnh"
+		}
+		replacement := &html.Node{Type: html.TextNode, Data: "[" + alt + "]"}
+		if node.Parent != nil {
+			node.Parent.InsertBefore(replacement, node)
+			node.Parent.RemoveChild(node)
+		}
+	case atom.Font:
+		// <font> không có trong XHTML5, đổi thành <span style="color:...">.
+		color := attr(node, "color")
+		node.Data, node.DataAtom, node.Attr = "span", atom.Span, nil
+		if color != "" && !strings.ContainsAny(color, ";\"") {
+			setAttr(node, "style", "color: "+color)
+		}
+	case atom.A:
+		if href := attr(node, "href"); strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") && baseURL != "" {
+			setAttr(node, "href", strings.TrimRight(baseURL, "/")+href)
+		}
+	}
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(node *html.Node, key, value string) {
+	for i, a := range node.Attr {
+		if a.Key == key {
+			node.Attr[i].Val = value
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: key, Val: value})
+}
+
+// chapterStarts gom tiêu đề các chương theo trang mở đầu để in tiêu đề chương ngay trước trang đó.
+func chapterStarts(entries []TOCEntry, depth int, starts map[uint][]heading) {
+	for _, entry := range entries {
+		if len(entry.Children) == 0 || entry.PageID == 0 {
+			continue
+		}
+		starts[entry.PageID] = append(starts[entry.PageID], heading{Title: entry.Title, Level: depth + 1})
+		chapterStarts(entry.Children, depth+1, starts)
+	}
+}
+
+type heading struct {
+	Title string
+	Level int // 1..3, tương ứng thẻ h1..h3
+}
+
+// tocOrDefault trả về mục lục của sách, hoặc danh sách trang phẳng nếu sách chưa chia chương.
+func tocOrDefault(book Book) []TOCEntry {
+	if len(book.TOC) > 0 {
+		return book.TOC
+	}
+	entries := make([]TOCEntry, 0, len(book.Pages))
+	for i, page := range book.Pages {
+		entries = append(entries, TOCEntry{Title: pageLabel(page, i), PageID: page.ID})
+	}
+	return entries
+}
+
+func pageLabel(page Page, index int) string {
+	if title := strings.TrimSpace(page.Title); title != "" {
+		return title
+	}
+	return fmt.Sprintf("Trang %d", index+1)
+}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// coverColors trả về màu nền hợp lệ và màu chữ tương phản (cùng công thức với trình đọc sách).
+func coverColors(color string) (background, text string) {
+	if !hexColorPattern.MatchString(color) {
+		color = "#1e293b"
+	}
+	r, _ := strconv.ParseUint(color[1:3], 16, 8)
+	g, _ := strconv.ParseUint(color[3:5], 16, 8)
+	b, _ := strconv.ParseUint(color[5:7], 16, 8)
+	if (0.299*float64(r)+0.587*float64(g)+0.114*float64(b))/255 > 0.5 {
+		return color, "#0f172a"
+	}
+	return color, "#f8fafc"
+}
+
+// wrapWords chia tiêu đề thành các dòng tối đa width ký tự để vẽ lên bìa SVG.
+func wrapWords(text string, width int) []string {
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(text) {
+		w := []rune(word)
+		if len(line) > 0 && len(line)+1+len(w) > width {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, w...)
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// coverSVG vẽ bìa đơn giản từ màu bìa, tiêu đề và tác giả khi sách không có ảnh bìa.
+func coverSVG(book Book) []byte {
+	background, text := coverColors(book.CoverColor)
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="600" height="900" viewBox="0 0 600 900">`)
+	fmt.Fprintf(&buf, `<rect width="600" height="900" fill="%s"/>`, background)
+	fmt.Fprintf(&buf, `<g fill="%s" font-family="serif" text-anchor="middle">`, text)
+	y := 320
+	for _, line := range wrapWords(book.Title, 18) {
+		fmt.Fprintf(&buf, `<text x="300" y="%d" font-size="48" font-weight="bold">%s</text>`, y, html.EscapeString(line))
+		y += 60
+	}
+	if book.Author != "" {
+		fmt.Fprintf(&buf, `<text x="300" y="%d" font-size="28">%s</text>`, y+60, html.EscapeString(book.Author))
+	}
+	buf.WriteString(`</g></svg>`)
+	return buf.Bytes()
+}
@@ -0,0 +1,295 @@
+package bookexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"time"
+)
+
+const epubStyle = `body { font-family: serif; line-height: 1.6; margin: 0 5%; }
+h1, h2, h3 { font-family: sans-serif; line-height: 1.3; }
+h1.chapter-title { page-break-before: always; margin-top: 3em; }
+img { max-width: 100%; height: auto; }
+table { border-collapse: collapse; width: 100%; }
+td, th { border: 1px solid #999; padding: 0.3em; }
+pre, code { font-family: monospace; white-space: pre-wrap; }
+.cover { margin: 0; padding: 0; text-align: center; }
+.cover img { height: 100%; max-height: 100vh; }
+`
+
+type manifestItem struct {
+	ID         string
+	Href       string
+	MediaType  string
+	Properties string
+}
+
+// epubBuilder gom các file của EPUB trước khi ghi ra zip.
+type epubBuilder struct {
+	book     Book
+	load     ImageLoader
+	images   map[uint]string // ID ảnh trong kho -> đường dẫn trong EPUB, "" nếu không tải được
+	files    map[string][]byte
+	manifest []manifestItem
+	coverID  string
+}
+
+func (b *epubBuilder) add(item manifestItem, data []byte) {
+	b.manifest = append(b.manifest, item)
+	b.files[item.Href] = data
+}
+
+// embedImage thêm ảnh vào EPUB (mỗi ảnh một lần) và trả về đường dẫn tương đối từ file nội dung.
+func (b *epubBuilder) embedImage(src string) string {
+	id := imageID(src, b.book.BaseURL)
+	if id == 0 {
+		return ""
+	}
+	if href, ok := b.images[id]; ok {
+		return href
+	}
+	b.images[id] = ""
+	image, err := b.load(id)
+	if err != nil || image == nil {
+		return ""
+	}
+	ext, ok := imageExtensions[image.ContentType]
+	if !ok {
+		return ""
+	}
+	href := fmt.Sprintf("images/image-%d.%s", id, ext)
+	b.add(manifestItem{ID: fmt.Sprintf("image-%d", id), Href: href, MediaType: image.ContentType}, image.Data)
+	b.images[id] = href
+	return href
+}
+
+func xhtmlDocument(title, body string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<!DOCTYPE html>` + "\n")
+	buf.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">` + "\n")
+	fmt.Fprintf(&buf, "<head><meta charset=\"UTF-8\"/><title>%s</title><link rel=\"stylesheet\" type=\"text/css\" href=\"style.css\"/></head>\n", html.EscapeString(title))
+	buf.WriteString("<body>\n")
+	buf.WriteString(body)
+	buf.WriteString("\n</body>\n</html>\n")
+	return buf.Bytes()
+}
+
+func pageFile(index int) string {
+	return fmt.Sprintf("page-%d.xhtml", index+1)
+}
+
+// WriteEPUB ghi sách ra w theo chuẩn EPUB 3, kèm toc.ncx cho các trình đọc EPUB 2 cũ.
+func WriteEPUB(w io.Writer, book Book, load ImageLoader) error {
+	if book.Language == "" {
+		book.Language = "vi"
+	}
+	b := &epubBuilder{book: book, load: load, images: map[uint]string{}, files: map[string][]byte{}}
+	modified := book.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	b.add(manifestItem{ID: "style", Href: "style.css", MediaType: "text/css"}, []byte(epubStyle))
+
+	// Bìa: ảnh trong kho nếu có, ngược lại vẽ SVG từ màu bìa.
+	coverHref := ""
+	if book.CoverURL != "" {
+		coverHref = b.embedImage(book.CoverURL)
+		for i := range b.manifest {
+			if b.manifest[i].Href == coverHref {
+				b.manifest[i].Properties = "cover-image"
+				b.coverID = b.manifest[i].ID
+			}
+		}
+	}
+	if coverHref == "" {
+		coverHref = "images/cover.svg"
+		b.coverID = "cover-image"
+		b.add(manifestItem{ID: b.coverID, Href: coverHref, MediaType: "image/svg+xml", Properties: "cover-image"}, coverSVG(book))
+	}
+	coverBody := fmt.Sprintf(`<section class="cover" epub:type="cover"><img src="%s" alt="%s"/></section>`, coverHref, html.EscapeString(book.Title))
+	b.add(manifestItem{ID: "cover", Href: "cover.xhtml", MediaType: "application/xhtml+xml"}, xhtmlDocument(book.Title, coverBody))
+
+	// Mỗi trang sách là một file XHTML; tiêu đề chương đặt trước trang mở đầu chương.
+	starts := map[uint][]heading{}
+	toc := tocOrDefault(book)
+	chapterStarts(toc, 0, starts)
+	pageFiles := make(map[uint]string, len(book.Pages))
+	var spine []string
+	for i, page := range book.Pages {
+		body, err := toXHTML(page.Content, book.BaseURL, b.embedImage)
+		if err != nil {
+			return fmt.Errorf("trang %d: %w", i+1, err)
+		}
+		var buf bytes.Buffer
+		for _, h := range starts[page.ID] {
+			class := ""
+			if h.Level == 1 {
+				class = ` class="chapter-title"`
+			}
+			fmt.Fprintf(&buf, "<h%d%s>%s</h%d>\n", h.Level, class, html.EscapeString(h.Title), h.Level)
+		}
+		if title := page.Title; title != "" {
+			fmt.Fprintf(&buf, "<h4>%s</h4>\n", html.EscapeString(title))
+		}
+		buf.WriteString(body)
+
+		href := pageFile(i)
+		pageFiles[page.ID] = href
+		id := fmt.Sprintf("page-%d", i+1)
+		b.add(manifestItem{ID: id, Href: href, MediaType: "application/xhtml+xml"}, xhtmlDocument(pageLabel(page, i), buf.String()))
+		spine = append(spine, id)
+	}
+
+	b.add(manifestItem{ID: "nav", Href: "nav.xhtml", MediaType: "application/xhtml+xml", Properties: "nav"}, navDocument(book, toc, pageFiles))
+	b.add(manifestItem{ID: "ncx", Href: "toc.ncx", MediaType: "application/x-dtbncx+xml"}, ncxDocument(book, toc, pageFiles))
+
+	return b.write(w, book, modified, spine)
+}
+
+func (b *epubBuilder) write(w io.Writer, book Book, modified time.Time, spine []string) error {
+	zw := zip.NewWriter(w)
+
+	// mimetype phải là file đầu tiên và không nén.
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := mimetype.Write([]byte("application/epub+zip")); err != nil {
+		return err
+	}
+
+	container := `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"META-INF/container.xml", []byte(container)},
+		{"OEBPS/content.opf", packageDocument(book, modified, b.coverID, b.manifest, spine)},
+	}
+	for _, item := range b.manifest {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{"OEBPS/" + item.Href, b.files[item.Href]})
+	}
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func packageDocument(book Book, modified time.Time, coverID string, manifest []manifestItem, spine []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + html.EscapeString(book.Language) + `">` + "\n")
+	buf.WriteString(`<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&buf, "<dc:identifier id=\"book-id\">%s</dc:identifier>\n", html.EscapeString(bookIdentifier(book)))
+	fmt.Fprintf(&buf, "<dc:title>%s</dc:title>\n", html.EscapeString(book.Title))
+	fmt.Fprintf(&buf, "<dc:language>%s</dc:language>\n", html.EscapeString(book.Language))
+	if book.Author != "" {
+		fmt.Fprintf(&buf, "<dc:creator>%s</dc:creator>\n", html.EscapeString(book.Author))
+	}
+	if book.Description != "" {
+		fmt.Fprintf(&buf, "<dc:description>%s</dc:description>\n", html.EscapeString(book.Description))
+	}
+	fmt.Fprintf(&buf, "<meta property=\"dcterms:modified\">%s</meta>\n", modified.UTC().Format("2006-01-02T15:04:05Z"))
+	fmt.Fprintf(&buf, "<meta name=\"cover\" content=\"%s\"/>\n", coverID)
+	buf.WriteString("</metadata>\n<manifest>\n")
+	for _, item := range manifest {
+		fmt.Fprintf(&buf, `<item id="%s" href="%s" media-type="%s"`, item.ID, item.Href, item.MediaType)
+		if item.Properties != "" {
+			fmt.Fprintf(&buf, ` properties="%s"`, item.Properties)
+		}
+		buf.WriteString("/>\n")
+	}
+	buf.WriteString("</manifest>\n<spine toc=\"ncx\">\n")
+	buf.WriteString(`<itemref idref="cover" linear="yes"/>` + "\n")
+	for _, id := range spine {
+		fmt.Fprintf(&buf, "<itemref idref=\"%s\"/>\n", id)
+	}
+	buf.WriteString("</spine>\n</package>\n")
+	return buf.Bytes()
+}
+
+func bookIdentifier(book Book) string {
+	if book.BaseURL != "" {
+		return fmt.Sprintf("%s/books/%d", book.BaseURL, book.ID)
+	}
+	return fmt.Sprintf("urn:book:%d", book.ID)
+}
+
+func navDocument(book Book, toc []TOCEntry, pageFiles map[uint]string) []byte {
+	var buf bytes.Buffer
+	var write func(entries []TOCEntry)
+	write = func(entries []TOCEntry) {
+		buf.WriteString("<ol>\n")
+		for _, entry := range entries {
+			href, ok := pageFiles[entry.PageID]
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(&buf, `<li><a href="%s">%s</a>`, href, html.EscapeString(entry.Title))
+			if hasLinkedChildren(entry.Children, pageFiles) {
+				write(entry.Children)
+			}
+			buf.WriteString("</li>\n")
+		}
+		buf.WriteString("</ol>\n")
+	}
+	buf.WriteString(`<nav epub:type="toc" id="toc"><h1>Mục lục</h1>` + "\n")
+	write(toc)
+	buf.WriteString("</nav>")
+	return xhtmlDocument("Mục lục", buf.String())
+}
+
+func hasLinkedChildren(entries []TOCEntry, pageFiles map[uint]string) bool {
+	for _, entry := range entries {
+		if _, ok := pageFiles[entry.PageID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func ncxDocument(book Book, toc []TOCEntry, pageFiles map[uint]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">` + "\n")
+	fmt.Fprintf(&buf, "<head><meta name=\"dtb:uid\" content=\"%s\"/></head>\n", html.EscapeString(bookIdentifier(book)))
+	fmt.Fprintf(&buf, "<docTitle><text>%s</text></docTitle>\n<navMap>\n", html.EscapeString(book.Title))
+	order := 0
+	var write func(entries []TOCEntry)
+	write = func(entries []TOCEntry) {
+		for _, entry := range entries {
+			href, ok := pageFiles[entry.PageID]
+			if !ok {
+				continue
+			}
+			order++
+			fmt.Fprintf(&buf, "<navPoint id=\"nav-%d\" playOrder=\"%d\"><navLabel><text>%s</text></navLabel><content src=\"%s\"/>\n",
+				order, order, html.EscapeString(entry.Title), href)
+			write(entry.Children)
+			buf.WriteString("</navPoint>\n")
+		}
+	}
+	write(toc)
+	buf.WriteString("</navMap>\n</ncx>\n")
+	return buf.Bytes()
+}
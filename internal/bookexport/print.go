@@ -0,0 +1,172 @@
+package bookexport
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// printTemplate là một trang HTML độc lập: ảnh nhúng dạng data URI, CSS @page để in/lưu PDF
+// từ trình duyệt, mỗi chương bắt đầu ở trang giấy mới.
+var printTemplate = template.Must(template.New("print").Parse(`<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.Title}}</title>
+<style>
+@page { size: A4; margin: 20mm 18mm; }
+body { font-family: Georgia, "Times New Roman", serif; line-height: 1.6; color: #111; max-width: 720px; margin: 0 auto; padding: 1rem; }
+h1, h2, h3, h4 { font-family: Helvetica, Arial, sans-serif; line-height: 1.3; page-break-after: avoid; }
+img { max-width: 100%; height: auto; page-break-inside: avoid; }
+table { border-collapse: collapse; width: 100%; page-break-inside: avoid; }
+td, th { border: 1px solid #999; padding: 0.3em; }
+pre, code { font-family: "Courier New", monospace; white-space: pre-wrap; }
+pre { background: #f4f4f4; padding: 0.75em; page-break-inside: avoid; }
+.cover { height: 240mm; display: flex; flex-direction: column; justify-content: center; align-items: center; text-align: center; page-break-after: always; }
+.cover img { max-height: 200mm; }
+.cover-color { width: 100%; height: 100%; display: flex; flex-direction: column; justify-content: center; border-radius: 8px; }
+.toc { page-break-after: always; }
+.toc ol { list-style: none; padding-left: 1.25rem; }
+.toc > ol { padding-left: 0; }
+.toc a { color: inherit; text-decoration: none; }
+.chapter-title { page-break-before: always; }
+.print-hint { background: #fef3c7; border: 1px solid #f59e0b; padding: 0.5rem 1rem; border-radius: 6px; font-family: sans-serif; }
+@media print { .print-hint { display: none; } body { max-width: none; padding: 0; } }
+</style>
+</head>
+<body>
+<p class="print-hint">Bản in của sách. Dùng Ctrl+P (hoặc ⌘+P) và chọn “Lưu dưới dạng PDF” để tải PDF.</p>
+<section class="cover">
+{{if .CoverImage}}<img src="{{.CoverImage}}" alt="{{.Title}}">
+<h1>{{.Title}}</h1>
+{{else}}<div class="cover-color" style="background: {{.CoverBackground}}; color: {{.CoverText}};">
+<h1>{{.Title}}</h1>
+{{if .Author}}<p>{{.Author}}</p>{{end}}
+</div>{{end}}
+{{if and .CoverImage .Author}}<p>{{.Author}}</p>{{end}}
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+</section>
+<nav class="toc">
+<h2>Mục lục</h2>
+{{template "toc" .TOC}}
+</nav>
+{{range .Pages}}
+<article id="{{.Anchor}}">
+{{range .Headings}}<h{{.Level}}{{if eq .Level 1}} class="chapter-title"{{end}}>{{.Title}}</h{{.Level}}>
+{{end}}{{if .Title}}<h4>{{.Title}}</h4>
+{{end}}{{.Body}}
+</article>
+{{end}}
+</body>
+</html>
+{{define "toc"}}<ol>
+{{range .}}<li><a href="#{{.Anchor}}">{{.Title}}</a>{{if .Children}}{{template "toc" .Children}}{{end}}</li>
+{{end}}</ol>{{end}}
+`))
+
+type printTOCEntry struct {
+	Title    string
+	Anchor   string
+	Children []printTOCEntry
+}
+
+type printPage struct {
+	Anchor   string
+	Title    string
+	Headings []heading
+	Body     template.HTML
+}
+
+func isHTTPURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func pageAnchor(id uint) string {
+	return fmt.Sprintf("page-%d", id)
+}
+
+func printTOC(entries []TOCEntry, pages map[uint]bool) []printTOCEntry {
+	var out []printTOCEntry
+	for _, entry := range entries {
+		if !pages[entry.PageID] {
+			continue
+		}
+		out = append(out, printTOCEntry{
+			Title:    entry.Title,
+			Anchor:   pageAnchor(entry.PageID),
+			Children: printTOC(entry.Children, pages),
+		})
+	}
+	return out
+}
+
+// WritePrintHTML ghi toàn bộ sách thành một file HTML duy nhất, ảnh trong kho được nhúng base64.
+func WritePrintHTML(w io.Writer, book Book, load ImageLoader) error {
+	if book.Language == "" {
+		book.Language = "vi"
+	}
+
+	embedded := map[uint]string{}
+	dataURI := func(src string) string {
+		id := imageID(src, book.BaseURL)
+		if id == 0 {
+			return src // Ảnh ngoài giữ nguyên URL, trình duyệt tự tải khi in
+		}
+		if uri, ok := embedded[id]; ok {
+			return uri
+		}
+		embedded[id] = ""
+		image, err := load(id)
+		if err != nil || image == nil {
+			return ""
+		}
+		embedded[id] = "data:" + image.ContentType + ";base64," + base64.StdEncoding.EncodeToString(image.Data)
+		return embedded[id]
+	}
+
+	starts := map[uint][]heading{}
+	toc := tocOrDefault(book)
+	chapterStarts(toc, 0, starts)
+
+	present := make(map[uint]bool, len(book.Pages))
+	pages := make([]printPage, 0, len(book.Pages))
+	for i, page := range book.Pages {
+		body, err := toXHTML(page.Content, book.BaseURL, dataURI)
+		if err != nil {
+			return fmt.Errorf("trang %d: %w", i+1, err)
+		}
+		present[page.ID] = true
+		pages = append(pages, printPage{
+			Anchor:   pageAnchor(page.ID),
+			Title:    page.Title,
+			Headings: starts[page.ID],
+			Body:     template.HTML(body),
+		})
+	}
+
+	background, text := coverColors(book.CoverColor)
+	var coverImage template.URL
+	if book.CoverURL != "" {
+		// CoverURL không đi qua sanitizer nên chỉ nhận ảnh đã nhúng hoặc link http(s).
+		if uri := dataURI(book.CoverURL); strings.HasPrefix(uri, "data:image/") || isHTTPURL(uri) {
+			coverImage = template.URL(uri)
+		}
+	}
+
+	return printTemplate.Execute(w, map[string]interface{}{
+		"Language":        book.Language,
+		"Title":           book.Title,
+		"Author":          book.Author,
+		"Description":     book.Description,
+		"CoverImage":      coverImage,
+		"CoverBackground": template.CSS(background),
+		"CoverText":       template.CSS(text),
+		"TOC":             printTOC(toc, present),
+		"Pages":           pages,
+	})
+}
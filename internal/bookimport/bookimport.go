@@ -0,0 +1,348 @@
+// Package bookimport đọc file ZIP gồm các trang Markdown để tạo sách. Mỗi file .md là một trang,
+// có thể kèm front matter (title, order, chapter); book.yml ở gốc chứa thông tin chung của sách.
+package bookimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Giới hạn để một file ZIP nhỏ không giải nén thành hàng GB (zip bomb) hay tạo sách quá lớn.
+const (
+	MaxArchiveSize = 20 << 20 // Dung lượng file ZIP tải lên
+	MaxTotalSize   = 60 << 20 // Tổng dung lượng sau giải nén
+	MaxFileSize    = 5 << 20  // Mỗi file Markdown hoặc ảnh
+	MaxFiles       = 2000
+	MaxPages       = 500
+)
+
+// Error là lỗi do nội dung file ZIP, thông báo được hiển thị thẳng cho người dùng.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Metadata là thông tin sách đọc từ book.yml (hoặc book.yaml) ở thư mục gốc.
+type Metadata struct {
+	Title       string
+	Description string
+	Category    string
+	Tag         string
+	CoverColor  string
+	Cover       string // Đường dẫn ảnh trong ZIP hoặc URL http(s)
+}
+
+// Page là một trang Markdown theo thứ tự đọc.
+type Page struct {
+	Path    string // Đường dẫn trong ZIP, dùng để tìm ảnh tương đối
+	Title   string
+	Order   int
+	Chapter []string // Chương từ ngoài vào trong; rỗng nếu trang không thuộc chương nào
+	Body    string   // Markdown đã bỏ front matter
+}
+
+// Archive là nội dung đã đọc của file ZIP.
+type Archive struct {
+	Meta  Metadata
+	Pages []Page
+	Files map[string][]byte // Các file không phải Markdown (ảnh...) theo đường dẫn
+}
+
+var markdownExts = map[string]bool{".md": true, ".markdown": true}
+
+// Read giải nén và phân tích file ZIP. Nếu mọi file nằm chung một thư mục (ZIP tạo từ thư mục
+// sách hoặc tải về từ GitHub) thì thư mục đó được coi là gốc.
+func Read(data []byte) (*Archive, error) {
+	if len(data) > MaxArchiveSize {
+		return nil, Error(fmt.Sprintf("File ZIP không được vượt quá %dMB", MaxArchiveSize>>20))
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, Error("File không phải ZIP hợp lệ")
+	}
+	if len(reader.File) > MaxFiles {
+		return nil, Error(fmt.Sprintf("File ZIP chứa quá %d file", MaxFiles))
+	}
+
+	files := map[string][]byte{}
+	var total int64
+	for _, file := range reader.File {
+		name, ok := cleanName(file.Name)
+		if !ok || file.FileInfo().IsDir() {
+			continue
+		}
+		if file.UncompressedSize64 > MaxFileSize {
+			return nil, Error(fmt.Sprintf("%s vượt quá %dMB", name, MaxFileSize>>20))
+		}
+		content, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if total += int64(len(content)); total > MaxTotalSize {
+			return nil, Error(fmt.Sprintf("Nội dung giải nén vượt quá %dMB", MaxTotalSize>>20))
+		}
+		files[name] = content
+	}
+	files = stripCommonRoot(files)
+
+	archive := &Archive{Files: map[string][]byte{}}
+	for name, content := range files {
+		switch {
+		case name == "book.yml" || name == "book.yaml":
+			archive.Meta = parseMetadata(parseFields(normalizeText(content)))
+		case markdownExts[strings.ToLower(path.Ext(name))]:
+			if strings.EqualFold(path.Base(name), "SUMMARY.md") {
+				continue // Mục lục kiểu GitBook, sách tự dựng mục lục từ chương
+			}
+			page, err := parsePage(name, content)
+			if err != nil {
+				return nil, err
+			}
+			archive.Pages = append(archive.Pages, page)
+		default:
+			archive.Files[name] = content
+		}
+	}
+
+	if len(archive.Pages) == 0 {
+		return nil, Error("File ZIP không có trang Markdown (.md) nào")
+	}
+	if len(archive.Pages) > MaxPages {
+		return nil, Error(fmt.Sprintf("Sách không được vượt quá %d trang", MaxPages))
+	}
+	sort.SliceStable(archive.Pages, func(i, j int) bool {
+		a, b := archive.Pages[i], archive.Pages[j]
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return sortKey(a.Path) < sortKey(b.Path)
+	})
+	return archive, nil
+}
+
+// cleanName chuẩn hóa đường dẫn trong ZIP và bỏ file ẩn, metadata của macOS hay đường dẫn thoát ra ngoài.
+func cleanName(name string) (string, bool) {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))[1:]
+	if name == "" {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// readFile đọc một file, không tin vào kích thước khai báo trong header của ZIP.
+func readFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, Error(fmt.Sprintf("Không đọc được %s trong file ZIP", file.Name))
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(io.LimitReader(rc, MaxFileSize+1))
+	if err != nil {
+		return nil, Error(fmt.Sprintf("Không đọc được %s trong file ZIP", file.Name))
+	}
+	if len(content) > MaxFileSize {
+		return nil, Error(fmt.Sprintf("%s vượt quá %dMB", file.Name, MaxFileSize>>20))
+	}
+	return content, nil
+}
+
+func stripCommonRoot(files map[string][]byte) map[string][]byte {
+	root := ""
+	for name := range files {
+		first, _, nested := strings.Cut(name, "/")
+		if !nested || (root != "" && first != root) {
+			return files
+		}
+		root = first
+	}
+	if root == "" {
+		return files
+	}
+	stripped := make(map[string][]byte, len(files))
+	for name, content := range files {
+		stripped[strings.TrimPrefix(name, root+"/")] = content
+	}
+	return stripped
+}
+
+func normalizeText(content []byte) string {
+	text := strings.TrimPrefix(string(content), "\ufeff")
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+// splitFrontMatter tách khối "---" ở đầu file khỏi phần Markdown.
+func splitFrontMatter(text string) (fields map[string]string, body string) {
+	if !strings.HasPrefix(text, "---\n") {
+		return nil, text
+	}
+	rest := text[len("---\n"):]
+	for offset := 0; offset <= len(rest); {
+		end := strings.IndexByte(rest[offset:], '\n')
+		line := rest[offset:]
+		if end >= 0 {
+			line = rest[offset : offset+end]
+		}
+		if trimmed := strings.TrimSpace(line); trimmed == "---" || trimmed == "..." {
+			next := len(rest)
+			if end >= 0 {
+				next = offset + end + 1
+			}
+			return parseFields(rest[:offset]), rest[next:]
+		}
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+	return nil, text // Không có dòng đóng: coi như Markdown thường
+}
+
+// parseFields đọc các dòng "key: value" đơn giản; đủ cho front matter và book.yml mà không cần thư viện YAML.
+func parseFields(text string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		fields[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return fields
+}
+
+func parseMetadata(fields map[string]string) Metadata {
+	return Metadata{
+		Title:       fields["title"],
+		Description: fields["description"],
+		Category:    fields["category"],
+		Tag:         fields["tag"],
+		CoverColor:  fields["cover_color"],
+		Cover:       fields["cover"],
+	}
+}
+
+func parsePage(name string, content []byte) (Page, error) {
+	if !utf8.Valid(content) {
+		return Page{}, Error(fmt.Sprintf("%s không phải văn bản UTF-8", name))
+	}
+	fields, body := splitFrontMatter(normalizeText(content))
+	page := Page{Path: name, Title: strings.TrimSpace(fields["title"]), Body: body}
+
+	if order := strings.TrimSpace(fields["order"]); order != "" {
+		n, err := strconv.Atoi(order)
+		if err != nil {
+			return Page{}, Error(fmt.Sprintf("%s: order phải là số nguyên", name))
+		}
+		page.Order = n
+	}
+
+	if chapter, ok := fields["chapter"]; ok {
+		for _, part := range strings.Split(chapter, "/") {
+			if part = strings.TrimSpace(part); part != "" {
+				page.Chapter = append(page.Chapter, part)
+			}
+		}
+	} else if dir := path.Dir(name); dir != "." {
+		for _, part := range strings.Split(dir, "/") {
+			page.Chapter = append(page.Chapter, humanize(part))
+		}
+	}
+
+	if page.Title == "" {
+		page.Title, page.Body = leadingHeading(page.Body)
+	}
+	if page.Title == "" {
+		switch {
+		case !isIndexPage(name):
+			page.Title = humanize(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+		case len(page.Chapter) > 0:
+			page.Title = page.Chapter[len(page.Chapter)-1]
+		default:
+			page.Title = "Giới thiệu"
+		}
+	}
+	return page, nil
+}
+
+// leadingHeading lấy tiêu đề "# ..." ở đầu trang làm tiêu đề trang và bỏ nó khỏi nội dung để không lặp lại.
+func leadingHeading(body string) (title, rest string) {
+	trimmed := strings.TrimLeft(body, "\n")
+	line, after, _ := strings.Cut(trimmed, "\n")
+	if !strings.HasPrefix(line, "# ") {
+		return "", body
+	}
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[2:]), "#")), after
+}
+
+// sortKey xếp README.md/index.md lên trước mọi trang khác trong cùng thư mục và thư mục con.
+func sortKey(name string) string {
+	if !isIndexPage(name) {
+		return name
+	}
+	if dir := path.Dir(name); dir != "." {
+		return dir + "/"
+	}
+	return ""
+}
+
+func isIndexPage(name string) bool {
+	base := strings.ToLower(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+	return base == "index" || base == "readme"
+}
+
+// humanize đổi tên file/thư mục như "02-cai-dat_docker" thành "Cai dat docker".
+func humanize(name string) string {
+	trimmed := strings.TrimLeftFunc(name, func(r rune) bool {
+		return unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' || r == ' '
+	})
+	if trimmed == "" {
+		trimmed = name
+	}
+	words := strings.Fields(strings.NewReplacer("-", " ", "_", " ").Replace(trimmed))
+	result := strings.Join(words, " ")
+	if first, size := utf8.DecodeRuneInString(result); size > 0 {
+		result = string(unicode.ToUpper(first)) + result[size:]
+	}
+	return result
+}
+
+// ResolvePath trả về đường dẫn trong ZIP mà src (trong trang pagePath) trỏ tới.
+// URL tuyệt đối, data URI hay đường dẫn thoát ra ngoài ZIP trả về false.
+func ResolvePath(pagePath, src string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(src))
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || parsed.Path == "" {
+		return "", false
+	}
+	target := parsed.Path
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/") // "/images/a.png" tính từ gốc sách
+	} else {
+		target = path.Join(path.Dir(pagePath), target)
+	}
+	target = path.Clean(target)
+	if target == "." || target == ".." || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	return target, true
+}
@@ -96,8 +96,11 @@ func Init() *gorm.DB {
 		if err = safeMigrate(db); err != nil {
 			log.Printf("⚠️  Migration warning: %v", err)
 		}
+		setupFullTextSearch(db)
 
 		seedDemoUser()
+		seedTagAliases()
+		promoteAdmins()
 	})
 
 	return db
@@ -120,6 +123,35 @@ func Get() *gorm.DB {
 
 // safeMigrate performs safe database migration that doesn't fail on existing tables
 func safeMigrate(db *gorm.DB) error {
+	// Tài khoản tạo trước khi có bước xác minh email được coi là đã xác minh
+	grandfatherVerified := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
+	// Sách cũ có thể có trang trùng số; đánh lại số liên tục trước khi tạo unique index (book_id, page_number)
+	if db.Migrator().HasTable(&models.BookPage{}) && !db.Migrator().HasIndex(&models.BookPage{}, "idx_book_pages_book_number") {
+		if err := db.Exec(`
+			UPDATE book_pages SET page_number = ordered.position
+			FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY book_id ORDER BY page_number, id) AS position
+				FROM book_pages WHERE deleted_at IS NULL
+			) AS ordered
+			WHERE book_pages.id = ordered.id AND book_pages.page_number <> ordered.position`).Error; err != nil {
+			log.Printf("⚠️  Failed to renumber book pages: %v", err)
+		}
+	}
+
+	// Hai lần lưu đồng thời trước đây có thể tạo phiên bản trùng số; đánh lại số trước khi tạo unique index (post_id, number)
+	if db.Migrator().HasTable(&models.PostRevision{}) && !db.Migrator().HasIndex(&models.PostRevision{}, "idx_post_revisions_post_number") {
+		if err := db.Exec(`
+			UPDATE post_revisions SET number = ordered.position
+			FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY post_id ORDER BY number, id) AS position
+				FROM post_revisions WHERE deleted_at IS NULL
+			) AS ordered
+			WHERE post_revisions.id = ordered.id AND post_revisions.number <> ordered.position`).Error; err != nil {
+			log.Printf("⚠️  Failed to renumber post revisions: %v", err)
+		}
+	}
+
 	// Simply run AutoMigrate, it's designed to be safe with existing tables
 	if err := db.AutoMigrate(
 		&models.User{},
@@ -128,7 +160,20 @@ func safeMigrate(db *gorm.DB) error {
 		&models.Annotation{},
 		&models.Book{},
 		&models.BookPage{},
+		&models.BookChapter{},
 		&models.Highlight{},
+		&models.ReadingProgress{},
+		&models.PostRevision{},
+		&models.SlugHistory{},
+		&models.Tag{},
+		&models.TagAlias{},
+		&models.SessionRecord{},
+		&models.UserSession{},
+		&models.UserIdentity{},
+		&models.APIToken{},
+		&models.RateLimitCounter{},
+		&models.RecoveryCode{},
+		&models.Setting{},
 	); err != nil {
 		// If error contains "already exists", it's not fatal
 		if strings.Contains(err.Error(), "already exists") {
@@ -138,6 +183,12 @@ func safeMigrate(db *gorm.DB) error {
 		return err
 	}
 
+	if grandfatherVerified {
+		if err := db.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+			log.Printf("⚠️  Failed to mark existing users as verified: %v", err)
+		}
+	}
+
 	log.Println("✅ Database migrations completed")
 	return nil
 }
@@ -159,10 +210,11 @@ func seedDemoUser() {
 		return
 	}
 
-	// Use raw SQL to insert user
+	// Use raw SQL to insert user. Tài khoản mẫu dùng mật khẩu công khai nên giữ vai trò mặc định;
+	// quyền admin chỉ cấp qua ADMIN_EMAILS (promoteAdmins).
 	if err := db.Exec(`
-		INSERT INTO users (name, email, password_hash, created_at, updated_at) 
-		VALUES (?, ?, ?, NOW(), NOW())`,
+		INSERT INTO users (name, email, password_hash, email_verified_at, created_at, updated_at) 
+		VALUES (?, ?, ?, NOW(), NOW(), NOW())`,
 		"DevOps Maintainer",
 		"admin@hocdevops.community",
 		string(hash),
@@ -170,3 +222,53 @@ func seedDemoUser() {
 		log.Printf("failed to create demo user: %v", err)
 	}
 }
+
+// defaultTagAliases gom các tên gọi phổ biến về tag chuẩn.
+var defaultTagAliases = map[string]string{
+	"k8s":        "kubernetes",
+	"golang":     "go",
+	"js":         "javascript",
+	"ts":         "typescript",
+	"tf":         "terraform",
+	"postgresql": "postgres",
+	"cicd":       "ci-cd",
+	"ci/cd":      "ci-cd",
+}
+
+func seedTagAliases() {
+	for alias, name := range defaultTagAliases {
+		tag := models.Tag{Name: name}
+		if err := db.Where(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			log.Printf("failed to seed tag %s: %v", name, err)
+			continue
+		}
+		entry := models.TagAlias{Alias: alias, TagID: tag.ID}
+		if err := db.Where(models.TagAlias{Alias: alias}).FirstOrCreate(&entry).Error; err != nil {
+			log.Printf("failed to seed tag alias %s: %v", alias, err)
+		}
+	}
+}
+
+// promoteAdmins cấp vai trò admin cho các email trong ADMIN_EMAILS (phân tách bằng dấu phẩy),
+// để có tài khoản quản trị đầu tiên mà không phải sửa database bằng tay.
+func promoteAdmins() {
+	var emails []string
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return
+	}
+	result := db.Model(&models.User{}).
+		Where("LOWER(email) IN ? AND role <> ?", emails, models.RoleAdmin).
+		Update("role", models.RoleAdmin)
+	if result.Error != nil {
+		log.Printf("failed to promote admins: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("👑 Promoted %d user(s) to admin from ADMIN_EMAILS", result.RowsAffected)
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/models"
+)
+
+// SearchConfig là cấu hình full-text search bỏ dấu, phù hợp tiếng Việt.
+const SearchConfig = "vietnamese_unaccent"
+
+var searchMigrations = []string{
+	`CREATE EXTENSION IF NOT EXISTS unaccent`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'vietnamese_unaccent') THEN
+			CREATE TEXT SEARCH CONFIGURATION vietnamese_unaccent (COPY = simple);
+			ALTER TEXT SEARCH CONFIGURATION vietnamese_unaccent
+				ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+		END IF;
+	END $$`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('vietnamese_unaccent', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('vietnamese_unaccent', coalesce(summary, '')), 'B') ||
+		setweight(to_tsvector('vietnamese_unaccent', replace(coalesce(tags, ''), ',', ' ')), 'B') ||
+		setweight(to_tsvector('vietnamese_unaccent', regexp_replace(coalesce(content, ''), '<[^>]+>', ' ', 'g')), 'C')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector)`,
+	`ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('vietnamese_unaccent', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('vietnamese_unaccent', coalesce(description, '')), 'B') ||
+		setweight(to_tsvector('vietnamese_unaccent', coalesce(book_tag, '') || ' ' || coalesce(book_category, '')), 'B')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING GIN (search_vector)`,
+	`ALTER TABLE book_pages ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('vietnamese_unaccent', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('vietnamese_unaccent', regexp_replace(coalesce(content, ''), '<[^>]+>', ' ', 'g')), 'C')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_book_pages_search_vector ON book_pages USING GIN (search_vector)`,
+}
+
+// fullTextSearch cho biết cấu hình và các cột search_vector đã sẵn sàng.
+var fullTextSearch bool
+
+// FullTextSearchReady trả về false khi không tạo được extension unaccent hay cột tsvector
+// (vd. user Postgres không có quyền CREATE EXTENSION); khi đó tìm kiếm dùng LIKE.
+func FullTextSearchReady() bool {
+	return fullTextSearch
+}
+
+// setupFullTextSearch tạo cấu hình unaccent và các cột tsvector phục vụ /search.
+func setupFullTextSearch(db *gorm.DB) {
+	for _, stmt := range searchMigrations {
+		if err := db.Exec(stmt).Error; err != nil {
+			log.Printf("⚠️  Full-text search migration warning: %v", err)
+			break
+		}
+	}
+
+	// Lần chạy trước có thể đã tạo xong dù lần này lỗi, nên kiểm tra trạng thái thật của schema
+	var configs int64
+	db.Raw("SELECT COUNT(*) FROM pg_ts_config WHERE cfgname = ?", SearchConfig).Scan(&configs)
+	fullTextSearch = configs > 0 &&
+		db.Migrator().HasColumn(&models.Post{}, "search_vector") &&
+		db.Migrator().HasColumn(&models.Book{}, "search_vector") &&
+		db.Migrator().HasColumn(&models.BookPage{}, "search_vector")
+	if fullTextSearch {
+		log.Println("✅ Full-text search ready")
+	} else {
+		log.Println("⚠️  Full-text search unavailable, falling back to LIKE search")
+	}
+}
@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+)
+
+const (
+	adminPageSize = 20
+	// adminStatsWeeks là số tuần gần nhất hiển thị trên bảng thống kê.
+	adminStatsWeeks = 8
+)
+
+const suspendedAccountMessage = "Tài khoản của bạn đã bị khóa, hãy liên hệ quản trị viên"
+
+// RequireAdmin chặn khu vực /admin với mọi người trừ admin đăng nhập bằng phiên.
+// Token API không dùng được ở đây để token bị lộ không mở được back office.
+func RequireAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if requestAPIToken(c) != nil {
+			return fiber.NewError(fiber.StatusForbidden, "Không thể truy cập trang quản trị bằng token")
+		}
+		user := currentUser(c)
+		if user == nil {
+			if c.Method() == fiber.MethodGet {
+				return c.Redirect("/auth/login?next=" + c.OriginalURL())
+			}
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login")
+		}
+		if !policy.IsAdmin(user) {
+			return respondError(c, fiber.StatusForbidden, "Chỉ quản trị viên mới được truy cập trang này", "/")
+		}
+		return c.Next()
+	}
+}
+
+// adminPaging đọc ?page= và trả về dữ liệu điều hướng trang cho template,
+// kèm các tham số lọc hiện tại để link trang trước/sau giữ nguyên bộ lọc.
+func adminPaging(c *fiber.Ctx, total int64) (offset int, nav fiber.Map) {
+	params := url.Values{}
+	for key, value := range c.Queries() {
+		if key != "page" && value != "" {
+			params.Set(key, value)
+		}
+	}
+	prefix := "?"
+	if encoded := params.Encode(); encoded != "" {
+		prefix += encoded + "&"
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	totalPages := int(math.Ceil(float64(total) / float64(adminPageSize)))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	return (page - 1) * adminPageSize, fiber.Map{
+		"Page":       page,
+		"TotalPages": totalPages,
+		"HasPrev":    page > 1,
+		"HasNext":    page < totalPages,
+		"PrevURL":    fmt.Sprintf("%spage=%d", prefix, page-1),
+		"NextURL":    fmt.Sprintf("%spage=%d", prefix, page+1),
+		"Total":      total,
+	}
+}
+
+// weekStart trả về 0h thứ Hai (UTC) của tuần chứa t, khớp với date_trunc('week') của Postgres.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// weeklyCounts đếm số bản ghi của bảng theo tuần, kể từ tuần since.
+func weeklyCounts(db *gorm.DB, model interface{}, since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Week  time.Time
+		Total int64
+	}
+	err := db.Model(model).
+		Select("date_trunc('week', created_at AT TIME ZONE 'UTC') AS week, COUNT(*) AS total").
+		Where("created_at >= ?", since).
+		Group("week").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Week.Format("2006-01-02")] = row.Total
+	}
+	return counts, nil
+}
+
+// AdminDashboard hiển thị số liệu tổng quan và số đăng ký, bài viết, sách theo tuần.
+func AdminDashboard() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+
+		first := weekStart(time.Now()).AddDate(0, 0, -7*(adminStatsWeeks-1))
+		signups, errUsers := weeklyCounts(db, &models.User{}, first)
+		posts, errPosts := weeklyCounts(db, &models.Post{}, first)
+		books, errBooks := weeklyCounts(db, &models.Book{}, first)
+		if err := errors.Join(errUsers, errPosts, errBooks); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải thống kê", "/")
+		}
+
+		weeks := make([]fiber.Map, 0, adminStatsWeeks)
+		for i := adminStatsWeeks - 1; i >= 0; i-- {
+			start := first.AddDate(0, 0, 7*i)
+			key := start.Format("2006-01-02")
+			weeks = append(weeks, fiber.Map{
+				"Label":   fmt.Sprintf("%s – %s", start.Format("02/01"), start.AddDate(0, 0, 6).Format("02/01/2006")),
+				"Week":    key,
+				"Signups": signups[key],
+				"Posts":   posts[key],
+				"Books":   books[key],
+			})
+		}
+
+		var totals struct {
+			Users, Suspended, Posts, HiddenPosts, Books, HiddenBooks, Comments, HiddenComments, Images int64
+		}
+		db.Model(&models.User{}).Count(&totals.Users)
+		db.Model(&models.User{}).Where("suspended_at IS NOT NULL").Count(&totals.Suspended)
+		db.Model(&models.Post{}).Count(&totals.Posts)
+		db.Model(&models.Post{}).Where("hidden = ?", true).Count(&totals.HiddenPosts)
+		db.Model(&models.Book{}).Count(&totals.Books)
+		db.Model(&models.Book{}).Where("hidden = ?", true).Count(&totals.HiddenBooks)
+		db.Model(&models.Comment{}).Count(&totals.Comments)
+		db.Model(&models.Comment{}).Where("hidden = ?", true).Count(&totals.HiddenComments)
+		db.Model(&models.Image{}).Count(&totals.Images)
+
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"totals": totals, "weeks": weeks})
+		}
+		return render(c, "pages/admin_dashboard", fiber.Map{
+			"Title":  "Quản trị",
+			"Totals": totals,
+			"Weeks":  weeks,
+		}, "main")
+	}
+}
+
+func adminUserView(user models.User) fiber.Map {
+	suspendedLabel := ""
+	if user.SuspendedAt != nil {
+		suspendedLabel = formatTimeVN(*user.SuspendedAt)
+	}
+	return fiber.Map{
+		"ID":              user.ID,
+		"Name":            user.Name,
+		"Email":           user.Email,
+		"Role":            user.Role,
+		"EmailVerified":   user.EmailVerified(),
+		"CreatedLabel":    formatTimeVN(user.CreatedAt),
+		"Suspended":       user.Suspended(),
+		"SuspendedLabel":  suspendedLabel,
+		"SuspendedReason": user.SuspendedReason,
+		"TwoFactor":       user.TwoFactorEnabled(),
+	}
+}
+
+// AdminUsersPage liệt kê người dùng, tìm theo tên/email và lọc theo vai trò hoặc trạng thái khóa.
+func AdminUsersPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+		q := strings.TrimSpace(c.Query("q"))
+		role := c.Query("role")
+		status := c.Query("status")
+
+		query := db.Model(&models.User{})
+		if q != "" {
+			term := "%" + strings.ToLower(q) + "%"
+			query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", term, term)
+		}
+		if validRole(role) {
+			query = query.Where("role = ?", role)
+		}
+		if status == "suspended" {
+			query = query.Where("suspended_at IS NOT NULL")
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải danh sách người dùng", "/admin")
+		}
+		offset, nav := adminPaging(c, total)
+		var users []models.User
+		if err := query.Order("created_at DESC").Offset(offset).Limit(adminPageSize).Find(&users).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải danh sách người dùng", "/admin")
+		}
+
+		items := make([]fiber.Map, 0, len(users))
+		for _, user := range users {
+			items = append(items, adminUserView(user))
+		}
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"users": items, "total": total, "page": nav["Page"]})
+		}
+		return render(c, "pages/admin_users", fiber.Map{
+			"Title":          "Quản lý người dùng",
+			"Users":          items,
+			"Roles":          models.Roles,
+			"TwoFactorRoles": twoFactorRequiredRoles(),
+			"Query":          q,
+			"Role":           role,
+			"Status":         status,
+			"Nav":            nav,
+		}, "main")
+	}
+}
+
+func validRole(role string) bool {
+	for _, r := range models.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// loadAdminTarget tải người dùng bị tác động; admin không được tự khóa hay tự hạ quyền mình.
+func loadAdminTarget(c *fiber.Ctx) (*models.User, error) {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, respondError(c, fiber.StatusBadRequest, "ID người dùng không hợp lệ", "/admin/users")
+	}
+	var user models.User
+	if err := database.Get().First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, respondError(c, fiber.StatusNotFound, "Người dùng không tồn tại", "/admin/users")
+		}
+		return nil, respondError(c, fiber.StatusInternalServerError, "Không thể tải người dùng", "/admin/users")
+	}
+	if admin := currentUser(c); admin != nil && admin.ID == user.ID {
+		return nil, respondError(c, fiber.StatusBadRequest, "Không thể thay đổi vai trò hoặc khóa chính tài khoản của bạn", "/admin/users")
+	}
+	return &user, nil
+}
+
+func adminFormValue(c *fiber.Ctx, key string) string {
+	if isJSONRequest(c) {
+		var body map[string]interface{}
+		if err := c.BodyParser(&body); err == nil {
+			if value, ok := body[key].(string); ok {
+				return value
+			}
+		}
+		return ""
+	}
+	return c.FormValue(key)
+}
+
+func adminUserDone(c *fiber.Ctx, user *models.User, message string) error {
+	if isJSONRequest(c) {
+		return c.JSON(fiber.Map{"success": true, "user": adminUserView(*user)})
+	}
+	setFlash(c, "success", message)
+	return c.Status(fiber.StatusSeeOther).Redirect(localRedirect(c.FormValue("next"), "/admin/users"))
+}
+
+// UpdateUserRole đổi vai trò của người dùng.
+func UpdateUserRole() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadAdminTarget(c)
+		if user == nil {
+			return err
+		}
+		role := adminFormValue(c, "role")
+		if !validRole(role) {
+			return respondError(c, fiber.StatusBadRequest, "Vai trò không hợp lệ", "/admin/users")
+		}
+		if err := database.Get().Model(user).Update("role", role).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể cập nhật vai trò", "/admin/users")
+		}
+		return adminUserDone(c, user, fmt.Sprintf("Đã chuyển %s sang vai trò %s", user.Name, role))
+	}
+}
+
+// SuspendUser khóa tài khoản và đăng xuất mọi phiên của người đó.
+func SuspendUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadAdminTarget(c)
+		if user == nil {
+			return err
+		}
+		reason := truncate(strings.TrimSpace(adminFormValue(c, "reason")), 255)
+		now := time.Now()
+		db := database.Get()
+		if err := db.Model(user).Updates(map[string]interface{}{
+			"suspended_at":     now,
+			"suspended_reason": reason,
+		}).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể khóa tài khoản", "/admin/users")
+		}
+		if _, err := revokeUserSessions(db, user.ID, ""); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Đã khóa tài khoản nhưng không thể đăng xuất các phiên", "/admin/users")
+		}
+		return adminUserDone(c, user, "Đã khóa tài khoản "+user.Name)
+	}
+}
+
+// UnsuspendUser mở khóa tài khoản.
+func UnsuspendUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadAdminTarget(c)
+		if user == nil {
+			return err
+		}
+		if err := database.Get().Model(user).Updates(map[string]interface{}{
+			"suspended_at":     nil,
+			"suspended_reason": "",
+		}).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể mở khóa tài khoản", "/admin/users")
+		}
+		return adminUserDone(c, user, "Đã mở khóa tài khoản "+user.Name)
+	}
+}
+
+// adminQueueFilter áp dụng bộ lọc ?filter=hidden (mặc định: mới nhất) cho hàng đợi kiểm duyệt.
+func adminQueueFilter(c *fiber.Ctx, query *gorm.DB, table string) (*gorm.DB, string) {
+	filter := c.Query("filter")
+	if filter == "hidden" {
+		return query.Where(table+".hidden = ?", true), filter
+	}
+	return query, "recent"
+}
+
+// AdminPostsPage là hàng đợi kiểm duyệt bài viết (kể cả nháp và bài đã lên lịch).
+func AdminPostsPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+		query, filter := adminQueueFilter(c, db.Model(&models.Post{}), "posts")
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/admin")
+		}
+		offset, nav := adminPaging(c, total)
+		var posts []models.Post
+		if err := query.Preload("Author").Order("created_at DESC").Offset(offset).Limit(adminPageSize).Find(&posts).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/admin")
+		}
+
+		items := make([]fiber.Map, 0, len(posts))
+		for _, post := range posts {
+			items = append(items, fiber.Map{
+				"ID":           post.ID,
+				"Title":        post.Title,
+				"URL":          postPath(post),
+				"AuthorName":   post.Author.Name,
+				"CreatedLabel": formatTimeVN(post.CreatedAt),
+				"StatusLabel":  postStatusLabel(post.Status),
+				"Hidden":       post.Hidden,
+			})
+		}
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"posts": items, "total": total, "page": nav["Page"]})
+		}
+		return render(c, "pages/admin_posts", fiber.Map{
+			"Title":  "Kiểm duyệt bài viết",
+			"Posts":  items,
+			"Filter": filter,
+			"Nav":    nav,
+		}, "main")
+	}
+}
+
+// AdminBooksPage là hàng đợi kiểm duyệt sách.
+func AdminBooksPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+		query, filter := adminQueueFilter(c, db.Model(&models.Book{}), "books")
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải sách", "/admin")
+		}
+		offset, nav := adminPaging(c, total)
+		var books []models.Book
+		if err := query.Order("created_at DESC").Offset(offset).Limit(adminPageSize).Find(&books).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải sách", "/admin")
+		}
+
+		authors := make(map[uint]string)
+		items := make([]fiber.Map, 0, len(books))
+		for _, book := range books {
+			if _, ok := authors[book.AuthorID]; !ok {
+				var author models.User
+				if err := db.Select("id", "name").First(&author, book.AuthorID).Error; err == nil {
+					authors[book.AuthorID] = author.Name
+				}
+			}
+			items = append(items, fiber.Map{
+				"ID":           book.ID,
+				"Title":        book.Title,
+				"URL":          bookPath(book),
+				"AuthorName":   authors[book.AuthorID],
+				"CreatedLabel": formatTimeVN(book.CreatedAt),
+				"Published":    book.Published,
+				"Hidden":       book.Hidden,
+			})
+		}
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"books": items, "total": total, "page": nav["Page"]})
+		}
+		return render(c, "pages/admin_books", fiber.Map{
+			"Title":  "Kiểm duyệt sách",
+			"Books":  items,
+			"Filter": filter,
+			"Nav":    nav,
+		}, "main")
+	}
+}
+
+// AdminCommentsPage là hàng đợi kiểm duyệt bình luận.
+func AdminCommentsPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+		query, filter := adminQueueFilter(c, db.Model(&models.Comment{}).Where("removed = ?", false), "comments")
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bình luận", "/admin")
+		}
+		offset, nav := adminPaging(c, total)
+		var comments []models.Comment
+		if err := query.Preload("Author").Preload("Post").Order("created_at DESC").Offset(offset).Limit(adminPageSize).Find(&comments).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bình luận", "/admin")
+		}
+
+		items := make([]fiber.Map, 0, len(comments))
+		for _, cm := range comments {
+			items = append(items, fiber.Map{
+				"ID":           cm.ID,
+				"PostID":       cm.PostID,
+				"PostTitle":    cm.Post.Title,
+				"URL":          commentAnchor(cm.PostID, cm.ID),
+				"Content":      truncate(cm.Content, 280),
+				"AuthorName":   cm.Author.Name,
+				"CreatedLabel": formatTimeVN(cm.CreatedAt),
+				"Hidden":       cm.Hidden,
+			})
+		}
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"comments": items, "total": total, "page": nav["Page"]})
+		}
+		return render(c, "pages/admin_comments", fiber.Map{
+			"Title":    "Kiểm duyệt bình luận",
+			"Comments": items,
+			"Filter":   filter,
+			"Nav":      nav,
+		}, "main")
+	}
+}
+
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// AdminImagesPage duyệt ảnh đã upload kèm kích thước và người upload; không tải dữ liệu ảnh.
+func AdminImagesPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+		query := db.Model(&models.Image{})
+		if uploader, err := strconv.Atoi(c.Query("uploader")); err == nil && uploader > 0 {
+			query = query.Where("uploader_id = ?", uploader)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải ảnh", "/admin")
+		}
+		var totalSize int64
+		query.Session(&gorm.Session{}).Select("COALESCE(SUM(size), 0)").Scan(&totalSize)
+
+		offset, nav := adminPaging(c, total)
+		var images []models.Image
+		if err := query.Select("id", "created_at", "filename", "content_type", "size", "uploader_id").
+			Preload("Uploader", func(db *gorm.DB) *gorm.DB { return db.Select("id", "name", "email") }).
+			Order("created_at DESC").Offset(offset).Limit(adminPageSize).Find(&images).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải ảnh", "/admin")
+		}
+
+		items := make([]fiber.Map, 0, len(images))
+		for _, image := range images {
+			items = append(items, fiber.Map{
+				"ID":           image.ID,
+				"URL":          fmt.Sprintf("/images/%d", image.ID),
+				"Filename":     image.Filename,
+				"ContentType":  image.ContentType,
+				"Size":         image.Size,
+				"SizeLabel":    formatBytes(image.Size),
+				"UploaderID":   image.UploaderID,
+				"UploaderName": image.Uploader.Name,
+				"CreatedLabel": formatTimeVN(image.CreatedAt),
+			})
+		}
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"images": items, "total": total, "total_size": totalSize, "page": nav["Page"]})
+		}
+		return render(c, "pages/admin_images", fiber.Map{
+			"Title":          "Ảnh đã upload",
+			"Images":         items,
+			"TotalSizeLabel": formatBytes(totalSize),
+			"Uploader":       c.Query("uploader"),
+			"Nav":            nav,
+		}, "main")
+	}
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/anchor"
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+)
+
+// Loại ghi chú có thể ghim lại vào dòng khác.
+const (
+	noteKindAnnotation = "annotation"
+	noteKindComment    = "comment"
+)
+
+func lineAnchorFrom(sel anchor.Selector) models.LineAnchor {
+	return models.LineAnchor{
+		AnchorHash:   sel.Hash,
+		AnchorQuote:  sel.Exact,
+		AnchorPrefix: sel.Prefix,
+		AnchorSuffix: sel.Suffix,
+	}
+}
+
+func selectorOf(a models.LineAnchor) anchor.Selector {
+	return anchor.Selector{
+		Hash:   a.AnchorHash,
+		Exact:  a.AnchorQuote,
+		Prefix: a.AnchorPrefix,
+		Suffix: a.AnchorSuffix,
+	}
+}
+
+// anchorNewNote tính vị trí và selector cho ghi chú mới tạo trên nội dung hiện tại.
+func anchorNewNote(blocks []anchor.Block, line int, quote string) (int, models.LineAnchor) {
+	located, sel, ok := anchor.Locate(blocks, line, quote)
+	if !ok {
+		return line, models.LineAnchor{}
+	}
+	return located, lineAnchorFrom(sel)
+}
+
+// parseLineMap giải mã JSON dạng {"<số dòng>": "<văn bản>"} từ form/editor.
+func parseLineMap(raw string) (map[int]string, error) {
+	result := make(map[int]string)
+	if strings.TrimSpace(raw) == "" {
+		return result, nil
+	}
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+		return nil, err
+	}
+	for key, value := range decoded {
+		if line, err := strconv.Atoi(key); err == nil && line > 0 {
+			result[line] = value
+		}
+	}
+	return result, nil
+}
+
+// resolveNote tìm lại dòng cho ghi chú trong nội dung mới. Ghi chú cũ chưa có selector
+// được tính selector từ nội dung trước khi sửa.
+func resolveNote(oldBlocks, newBlocks []anchor.Block, line int, current models.LineAnchor) (int, models.LineAnchor, bool, bool) {
+	sel := selectorOf(current)
+	if sel.IsZero() {
+		var ok bool
+		if sel, ok = anchor.At(oldBlocks, line); !ok {
+			return line, current, false, false
+		}
+	}
+
+	newLine, exact, ok := anchor.Resolve(newBlocks, sel, line)
+	if !ok {
+		next := lineAnchorFrom(sel)
+		next.Orphaned = true
+		return line, next, false, false
+	}
+	fresh, _ := anchor.At(newBlocks, newLine)
+	return newLine, lineAnchorFrom(fresh), exact, true
+}
+
+// reanchorComments gắn lại bình luận theo dòng sau khi nội dung bài viết thay đổi.
+func reanchorComments(db *gorm.DB, postID uint, oldBlocks, newBlocks []anchor.Block) error {
+	var comments []models.Comment
+	if err := db.Where("post_id = ? AND line_number IS NOT NULL", postID).Find(&comments).Error; err != nil {
+		return err
+	}
+	for _, cm := range comments {
+		line, next, _, _ := resolveNote(oldBlocks, newBlocks, *cm.LineNumber, cm.LineAnchor)
+		if err := db.Model(&models.Comment{}).Where("id = ?", cm.ID).UpdateColumns(map[string]interface{}{
+			"line_number":   line,
+			"anchor_hash":   next.AnchorHash,
+			"anchor_quote":  next.AnchorQuote,
+			"anchor_prefix": next.AnchorPrefix,
+			"anchor_suffix": next.AnchorSuffix,
+			"orphaned":      next.Orphaned,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveAnnotationAnchor(db *gorm.DB, ann *models.Annotation) error {
+	return db.Model(&models.Annotation{}).Where("id = ?", ann.ID).UpdateColumns(map[string]interface{}{
+		"content":       ann.Content,
+		"line_number":   ann.LineNumber,
+		"anchor_hash":   ann.AnchorHash,
+		"anchor_quote":  ann.AnchorQuote,
+		"anchor_prefix": ann.AnchorPrefix,
+		"anchor_suffix": ann.AnchorSuffix,
+		"orphaned":      ann.Orphaned,
+	}).Error
+}
+
+// syncLineAnnotations gắn lại chú thích sau khi sửa bài và áp dụng chú thích gửi từ editor.
+//
+// submitted == nil nghĩa là client không gửi chú thích, chỉ gắn lại theo nội dung.
+// Editor chỉ hiển thị được chú thích có đoạn gốc còn nguyên văn; với các chú thích đó,
+// dữ liệu editor gửi lên là quyết định cuối (kể cả xóa). Chú thích có đoạn gốc bị sửa câu chữ
+// được gắn theo độ tương đồng, không tìm thấy thì chuyển sang trạng thái mồ côi để tác giả ghim lại.
+func syncLineAnnotations(db *gorm.DB, post *models.Post, oldContent string, submitted, quotes map[int]string) error {
+	oldBlocks := anchor.Blocks(oldContent)
+	newBlocks := anchor.Blocks(post.Content)
+
+	var existing []models.Annotation
+	if err := db.Where("post_id = ?", post.ID).Order("id ASC").Find(&existing).Error; err != nil {
+		return err
+	}
+
+	// Dòng -> nội dung chú thích editor gửi lên, đã quy về cách đánh số phía server
+	wanted := make(map[int]string)
+	wantedAnchors := make(map[int]models.LineAnchor)
+	for line, text := range submitted {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		located, la := anchorNewNote(newBlocks, line, quotes[line])
+		wanted[located] = text
+		wantedAnchors[located] = la
+	}
+
+	taken := make(map[int]*models.Annotation)
+	for i := range existing {
+		ann := &existing[i]
+		if ann.Orphaned {
+			// Chú thích mồ côi có thể khớp lại nếu đoạn gốc được khôi phục
+			if line, exact, ok := anchor.Resolve(newBlocks, selectorOf(ann.LineAnchor), ann.LineNumber); ok && exact && taken[line] == nil {
+				if _, claimed := wanted[line]; !claimed {
+					fresh, _ := anchor.At(newBlocks, line)
+					ann.LineNumber, ann.LineAnchor = line, lineAnchorFrom(fresh)
+					taken[line] = ann
+					if err := saveAnnotationAnchor(db, ann); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+
+		line, next, exact, ok := resolveNote(oldBlocks, newBlocks, ann.LineNumber, ann.LineAnchor)
+		ann.LineNumber, ann.LineAnchor = line, next
+
+		switch {
+		case ok && submitted != nil && exact:
+			text, keep := wanted[line]
+			if !keep || taken[line] != nil {
+				if err := db.Delete(ann).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			ann.Content = text
+			delete(wanted, line)
+		case ok && (wanted[line] != "" || taken[line] != nil):
+			// Dòng đã có chú thích khác, giữ lại để tác giả ghim sang chỗ khác
+			ann.Orphaned = true
+		}
+
+		if !ann.Orphaned {
+			taken[line] = ann
+		}
+		if err := saveAnnotationAnchor(db, ann); err != nil {
+			return err
+		}
+	}
+
+	for line, text := range wanted {
+		if taken[line] != nil {
+			continue
+		}
+		annotation := models.Annotation{
+			PostID:     &[]uint{post.ID}[0],
+			LineNumber: line,
+			Content:    text,
+			LineAnchor: wantedAnchors[line],
+		}
+		if err := db.Create(&annotation).Error; err != nil {
+			return err
+		}
+	}
+
+	return reanchorComments(db, post.ID, oldBlocks, newBlocks)
+}
+
+// orphanedNotes liệt kê chú thích và luồng bình luận không còn khớp với nội dung hiện tại.
+func orphanedNotes(db *gorm.DB, postID uint) []fiber.Map {
+	notes := make([]fiber.Map, 0)
+
+	var annotations []models.Annotation
+	db.Where("post_id = ? AND orphaned = ?", postID, true).Order("id ASC").Find(&annotations)
+	for _, ann := range annotations {
+		notes = append(notes, fiber.Map{
+			"Kind":      noteKindAnnotation,
+			"KindLabel": "Chú thích",
+			"ID":        ann.ID,
+			"Content":   ann.Content,
+			"Quote":     ann.AnchorQuote,
+			"Line":      ann.LineNumber,
+		})
+	}
+
+	var comments []models.Comment
+	db.Preload("Author").
+		Where("post_id = ? AND orphaned = ? AND parent_id IS NULL", postID, true).
+		Order("id ASC").Find(&comments)
+	for _, cm := range comments {
+		content := cm.Content
+		if cm.Removed {
+			content = removedCommentText
+		}
+		notes = append(notes, fiber.Map{
+			"Kind":      noteKindComment,
+			"KindLabel": "Bình luận của " + cm.Author.Name,
+			"ID":        cm.ID,
+			"Content":   content,
+			"Quote":     cm.AnchorQuote,
+			"Line":      derefLine(cm.LineNumber),
+		})
+	}
+	return notes
+}
+
+func derefLine(line *int) int {
+	if line == nil {
+		return 0
+	}
+	return *line
+}
+
+// postLineOptions liệt kê các dòng hiện có để tác giả chọn khi ghim lại ghi chú.
+func postLineOptions(content string) []fiber.Map {
+	blocks := anchor.Blocks(content)
+	options := make([]fiber.Map, 0, len(blocks))
+	for _, b := range blocks {
+		preview := b.Text
+		if runes := []rune(preview); len(runes) > 60 {
+			preview = string(runes[:60]) + "…"
+		}
+		if preview == "" {
+			preview = "(ảnh)"
+		}
+		options = append(options, fiber.Map{"Number": b.Line, "Preview": preview})
+	}
+	return options
+}
+
+// lineQuotes trả về map số dòng -> nội dung dòng để script phía client đối chiếu vị trí.
+func lineQuotes(content string) map[int]string {
+	quotes := make(map[int]string)
+	for _, b := range anchor.Blocks(content) {
+		quotes[b.Line] = b.Text
+	}
+	return quotes
+}
+
+// RepinNote cho phép tác giả (hoặc ban quản trị) ghim lại chú thích hoặc luồng bình luận mồ côi vào một dòng.
+func RepinNote() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, err := currentUserID(c); err != nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login")
+		}
+
+		postID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "ID bài viết không hợp lệ", "/posts")
+		}
+
+		var body struct {
+			Kind       string `json:"kind"`
+			NoteID     uint   `json:"note_id"`
+			LineNumber int    `json:"line_number"`
+		}
+		if isJSONRequest(c) {
+			if err := c.BodyParser(&body); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "payload không hợp lệ")
+			}
+		} else {
+			body.Kind = c.FormValue("kind")
+			noteID, _ := strconv.Atoi(c.FormValue("note_id"))
+			body.NoteID = uint(noteID)
+			body.LineNumber, _ = strconv.Atoi(c.FormValue("line_number"))
+		}
+
+		db := database.Get()
+		var post models.Post
+		if err := db.First(&post, postID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return respondError(c, fiber.StatusNotFound, "Bài viết không tồn tại", "/posts")
+			}
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/posts")
+		}
+		backURL := postPath(post) + "#orphaned-notes"
+		if !can(currentUser(c), policy.Edit, post) {
+			return respondError(c, fiber.StatusForbidden, "Bạn không có quyền ghim lại ghi chú của bài viết này", backURL)
+		}
+
+		sel, ok := anchor.At(anchor.Blocks(post.Content), body.LineNumber)
+		if !ok {
+			return respondError(c, fiber.StatusBadRequest, "Dòng không hợp lệ", backURL)
+		}
+		columns := map[string]interface{}{
+			"line_number":   body.LineNumber,
+			"anchor_hash":   sel.Hash,
+			"anchor_quote":  sel.Exact,
+			"anchor_prefix": sel.Prefix,
+			"anchor_suffix": sel.Suffix,
+			"orphaned":      false,
+		}
+
+		switch body.Kind {
+		case noteKindAnnotation:
+			var note models.Annotation
+			if err := db.Where("post_id = ?", post.ID).First(&note, body.NoteID).Error; err != nil {
+				return respondError(c, fiber.StatusNotFound, "Chú thích không tồn tại", backURL)
+			}
+			if !note.Orphaned {
+				return respondError(c, fiber.StatusConflict, "Chú thích này vẫn đang gắn với một dòng", backURL)
+			}
+			var conflict int64
+			db.Model(&models.Annotation{}).
+				Where("post_id = ? AND line_number = ? AND orphaned = ? AND id <> ?", post.ID, body.LineNumber, false, body.NoteID).
+				Count(&conflict)
+			if conflict > 0 {
+				return respondError(c, fiber.StatusConflict, "Dòng này đã có chú thích", backURL)
+			}
+			result := db.Model(&models.Annotation{}).Where("id = ? AND orphaned = ?", note.ID, true).UpdateColumns(columns)
+			if result.Error != nil {
+				return respondError(c, fiber.StatusInternalServerError, "Không thể ghim lại chú thích", backURL)
+			}
+			if result.RowsAffected == 0 {
+				return respondError(c, fiber.StatusConflict, "Chú thích này vừa được ghim lại", backURL)
+			}
+		case noteKindComment:
+			var root models.Comment
+			if err := db.Where("post_id = ? AND parent_id IS NULL AND line_number IS NOT NULL", post.ID).First(&root, body.NoteID).Error; err != nil {
+				return respondError(c, fiber.StatusNotFound, "Bình luận không tồn tại", backURL)
+			}
+			if !root.Orphaned {
+				return respondError(c, fiber.StatusConflict, "Luồng bình luận này vẫn đang gắn với một dòng", backURL)
+			}
+			// Cả luồng trả lời đi theo bình luận gốc
+			ids := []uint{root.ID}
+			for frontier := []uint{root.ID}; len(frontier) > 0; {
+				var children []uint
+				db.Model(&models.Comment{}).Where("parent_id IN ?", frontier).Pluck("id", &children)
+				ids = append(ids, children...)
+				frontier = children
+			}
+			if err := db.Model(&models.Comment{}).Where("id IN ?", ids).UpdateColumns(columns).Error; err != nil {
+				return respondError(c, fiber.StatusInternalServerError, "Không thể ghim lại bình luận", backURL)
+			}
+		default:
+			return respondError(c, fiber.StatusBadRequest, "Loại ghi chú không hợp lệ", backURL)
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "line_number": body.LineNumber})
+		}
+
+		setFlash(c, "success", fmt.Sprintf("Đã ghim ghi chú vào dòng %d", body.LineNumber))
+		return c.Status(fiber.StatusSeeOther).Redirect(postPath(post))
+	}
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+)
+
+// Quyền của personal access token.
+const (
+	ScopeRead        = "read"
+	ScopePostsWrite  = "posts:write"
+	ScopeBooksWrite  = "books:write"
+	ScopeImagesWrite = "images:write"
+)
+
+// apiTokenPrefix giúp nhận ra token khi bị lộ (secret scanning, log).
+const apiTokenPrefix = "fdc_"
+
+// apiTokenTouchInterval giới hạn tần suất ghi "lần dùng cuối", giống sessionTouchInterval.
+const apiTokenTouchInterval = time.Minute
+
+const localsAPIToken = "apiToken"
+
+var apiTokenScopes = []fiber.Map{
+	{"Name": ScopeRead, "Label": "Đọc nội dung cần đăng nhập (bản nháp, sách chưa xuất bản...)"},
+	{"Name": ScopePostsWrite, "Label": "Tạo, sửa và xóa bài viết"},
+	{"Name": ScopeBooksWrite, "Label": "Tạo, sửa và xóa sách, trang sách"},
+	{"Name": ScopeImagesWrite, "Label": "Upload ảnh"},
+}
+
+var apiTokenExpiryDays = []int{30, 90, 365, 0}
+
+func validScope(scope string) bool {
+	for _, s := range apiTokenScopes {
+		if s["Name"] == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// apiTokenAuth là thông tin xác thực bằng token của request hiện tại.
+type apiTokenAuth struct {
+	token models.APIToken
+	// scoped được RequireScope bật khi route đã kiểm tra quyền của token
+	scoped bool
+}
+
+func hashAPIToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return apiTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func requestAPIToken(c *fiber.Ctx) *apiTokenAuth {
+	auth, _ := c.Locals(localsAPIToken).(*apiTokenAuth)
+	return auth
+}
+
+// sessionOnlyPrefixes là các khu vực chỉ dùng được khi đăng nhập bằng phiên, token nào cũng bị từ chối.
+var sessionOnlyPrefixes = []string{"/admin", "/account"}
+
+func isSessionOnlyPath(path string) bool {
+	for _, prefix := range sessionOnlyPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenUserID trả người dùng của token nếu request được phép dùng token: route đã kiểm tra quyền
+// qua RequireScope, hoặc request chỉ đọc và token có quyền read. Endpoint khác vẫn đòi đăng nhập bằng phiên.
+func tokenUserID(c *fiber.Ctx) (uint, bool) {
+	auth := requestAPIToken(c)
+	if auth == nil {
+		return 0, false
+	}
+	if !auth.scoped && !(isSafeMethod(c.Method()) && auth.token.HasScope(ScopeRead)) {
+		return 0, false
+	}
+	return auth.token.UserID, true
+}
+
+func bearerError(c *fiber.Ctx, status int, code, message string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="`+code+`"`)
+	return c.Status(status).JSON(fiber.Map{"error": message})
+}
+
+// BearerAuth xác thực header "Authorization: Bearer <token>". Token sai hoặc hết hạn bị từ chối ngay,
+// không rơi về phiên cookie, để request script không vô tình chạy dưới danh tính khác.
+func BearerAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		if header == "" {
+			return c.Next()
+		}
+		scheme, raw, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(raw) == "" {
+			return bearerError(c, fiber.StatusUnauthorized, "invalid_request", "Header Authorization không hợp lệ")
+		}
+		if isSessionOnlyPath(c.Path()) {
+			return bearerError(c, fiber.StatusForbidden, "insufficient_scope", "Trang này không dùng được bằng token")
+		}
+
+		db := database.Get()
+		var token models.APIToken
+		if err := db.Where("token_hash = ?", hashAPIToken(strings.TrimSpace(raw))).First(&token).Error; err != nil {
+			return bearerError(c, fiber.StatusUnauthorized, "invalid_token", "Token không hợp lệ")
+		}
+		now := time.Now()
+		if token.Expired(now) {
+			return bearerError(c, fiber.StatusUnauthorized, "invalid_token", "Token đã hết hạn")
+		}
+		var suspended int64
+		if err := db.Model(&models.User{}).Where("id = ? AND suspended_at IS NOT NULL", token.UserID).Count(&suspended).Error; err != nil || suspended > 0 {
+			return bearerError(c, fiber.StatusUnauthorized, "invalid_token", "Tài khoản của token đã bị khóa")
+		}
+
+		if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenTouchInterval {
+			if err := db.Model(&token).UpdateColumns(map[string]interface{}{
+				"last_used_at": now,
+				"last_used_ip": c.IP(),
+			}).Error; err != nil {
+				log.Printf("failed to record token usage: %v", err)
+			}
+		}
+
+		c.Locals(localsAPIToken, &apiTokenAuth{token: token})
+		return c.Next()
+	}
+}
+
+// RequireScope cho phép token có quyền scope dùng route. Request đăng nhập bằng phiên đi qua nguyên vẹn.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		auth := requestAPIToken(c)
+		if auth == nil {
+			return c.Next()
+		}
+		if !auth.token.HasScope(scope) {
+			return bearerError(c, fiber.StatusForbidden, "insufficient_scope", "Token thiếu quyền "+scope)
+		}
+		auth.scoped = true
+		return c.Next()
+	}
+}
+
+// sessionOnlyUserID dùng cho trang quản lý token: token không được tự tạo hay thu hồi token khác.
+func sessionOnlyUserID(c *fiber.Ctx) (uint, error) {
+	if requestAPIToken(c) != nil {
+		return 0, fiber.NewError(fiber.StatusForbidden, "Không thể quản lý token bằng token")
+	}
+	return currentUserID(c)
+}
+
+func apiTokenView(token models.APIToken, now time.Time) fiber.Map {
+	expiresLabel := "Không hết hạn"
+	if token.ExpiresAt != nil {
+		expiresLabel = formatTimeVN(*token.ExpiresAt)
+	}
+	lastUsedLabel := "Chưa dùng"
+	if token.LastUsedAt != nil {
+		lastUsedLabel = formatTimeVN(*token.LastUsedAt)
+	}
+	return fiber.Map{
+		"ID":            token.ID,
+		"Name":          token.Name,
+		"Prefix":        token.Prefix,
+		"Scopes":        token.ScopeList(),
+		"CreatedLabel":  formatTimeVN(token.CreatedAt),
+		"ExpiresAt":     token.ExpiresAt,
+		"ExpiresLabel":  expiresLabel,
+		"LastUsedAt":    token.LastUsedAt,
+		"LastUsedLabel": lastUsedLabel,
+		"LastUsedIP":    token.LastUsedIP,
+		"Expired":       token.Expired(now),
+	}
+}
+
+func renderAPITokens(c *fiber.Ctx, userID uint, newToken string) error {
+	var tokens []models.APIToken
+	if err := database.Get().Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return respondError(c, fiber.StatusInternalServerError, "Không thể tải danh sách token", "/account")
+	}
+	now := time.Now()
+	items := make([]fiber.Map, 0, len(tokens))
+	for _, token := range tokens {
+		items = append(items, apiTokenView(token, now))
+	}
+
+	if wantsJSON(c) {
+		return c.JSON(fiber.Map{"tokens": items})
+	}
+	return render(c, "pages/account_tokens", fiber.Map{
+		"Title":      "Token truy cập API",
+		"Tokens":     items,
+		"Scopes":     apiTokenScopes,
+		"ExpiryDays": apiTokenExpiryDays,
+		"NewToken":   newToken,
+	}, "main")
+}
+
+// APITokensPage liệt kê personal access token của người dùng.
+func APITokensPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := sessionOnlyUserID(c)
+		if err != nil {
+			if requestAPIToken(c) != nil {
+				return err
+			}
+			return c.Redirect("/auth/login?next=/account/tokens")
+		}
+		return renderAPITokens(c, userID, "")
+	}
+}
+
+// CreateAPIToken tạo token mới; chuỗi token chỉ trả về một lần trong phản hồi này.
+func CreateAPIToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := sessionOnlyUserID(c)
+		if err != nil {
+			if requestAPIToken(c) != nil {
+				return err
+			}
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login?next=/account/tokens")
+		}
+
+		var body struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"`
+		}
+		if isJSONRequest(c) {
+			if err := c.BodyParser(&body); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "payload không hợp lệ")
+			}
+		} else {
+			body.Name = c.FormValue("name")
+			for _, scope := range c.Context().PostArgs().PeekMulti("scopes") {
+				body.Scopes = append(body.Scopes, string(scope))
+			}
+			body.ExpiresInDays, _ = strconv.Atoi(c.FormValue("expires_in_days"))
+		}
+
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" || len(body.Name) > 120 {
+			return respondError(c, fiber.StatusBadRequest, "Tên token phải từ 1 đến 120 ký tự", "/account/tokens")
+		}
+		seen := make(map[string]bool)
+		scopes := make([]string, 0, len(body.Scopes))
+		for _, scope := range body.Scopes {
+			if !validScope(scope) {
+				return respondError(c, fiber.StatusBadRequest, "Quyền không hợp lệ: "+scope, "/account/tokens")
+			}
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+		if len(scopes) == 0 {
+			return respondError(c, fiber.StatusBadRequest, "Hãy chọn ít nhất một quyền", "/account/tokens")
+		}
+		if body.ExpiresInDays < 0 || body.ExpiresInDays > 365 {
+			return respondError(c, fiber.StatusBadRequest, "Thời hạn token tối đa 365 ngày", "/account/tokens")
+		}
+
+		raw := newAPIToken()
+		token := models.APIToken{
+			UserID:    userID,
+			Name:      body.Name,
+			Prefix:    raw[:len(apiTokenPrefix)+6],
+			TokenHash: hashAPIToken(raw),
+			Scopes:    strings.Join(scopes, " "),
+		}
+		if body.ExpiresInDays > 0 {
+			expires := time.Now().AddDate(0, 0, body.ExpiresInDays)
+			token.ExpiresAt = &expires
+		}
+		if err := database.Get().Create(&token).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tạo token", "/account/tokens")
+		}
+
+		if isJSONRequest(c) {
+			view := apiTokenView(token, time.Now())
+			view["Token"] = raw
+			return c.Status(fiber.StatusCreated).JSON(view)
+		}
+		setFlash(c, "success", "Đã tạo token, hãy sao chép ngay vì token sẽ không hiển thị lại")
+		return renderAPITokens(c, userID, raw)
+	}
+}
+
+// RevokeAPIToken xóa một token của người dùng hiện tại.
+func RevokeAPIToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := sessionOnlyUserID(c)
+		if err != nil {
+			if requestAPIToken(c) != nil {
+				return err
+			}
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login?next=/account/tokens")
+		}
+		tokenID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "ID token không hợp lệ", "/account/tokens")
+		}
+		result := database.Get().Where("id = ? AND user_id = ?", tokenID, userID).Delete(&models.APIToken{})
+		if result.Error != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể thu hồi token", "/account/tokens")
+		}
+		if result.RowsAffected == 0 {
+			return respondError(c, fiber.StatusNotFound, "Token không tồn tại", "/account/tokens")
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true})
+		}
+		setFlash(c, "success", "Đã thu hồi token")
+		return c.Status(fiber.StatusSeeOther).Redirect("/account/tokens")
+	}
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/authtoken"
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/mailer"
+	"fiber-learning-community/internal/models"
+)
+
+const (
+	verifyEmailTTL   = 48 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+// Dấu vân tay đổi khi email đổi (link xác minh cũ vô hiệu) hoặc khi mật khẩu đổi (link đặt lại chỉ dùng được một lần).
+func verifyFingerprint(user models.User) string {
+	return authtoken.Fingerprint(user.Email)
+}
+
+func resetFingerprint(user models.User) string {
+	return authtoken.Fingerprint(user.Email, user.PasswordHash)
+}
+
+// errMailBaseURL: link trong email chỉ được dựng từ SITE_URL. Header Host do client gửi lên, dùng nó
+// thì kẻ tấn công có thể khiến email đặt lại mật khẩu chứa link tới domain của họ.
+var errMailBaseURL = errors.New("SITE_URL chưa được cấu hình, không thể gửi email chứa link")
+
+// mailBaseURL trả về địa chỉ công khai của site để dựng link trong email.
+func mailBaseURL() (string, error) {
+	base := strings.TrimRight(strings.TrimSpace(os.Getenv("SITE_URL")), "/")
+	if base == "" {
+		return "", errMailBaseURL
+	}
+	return base, nil
+}
+
+func sendVerificationEmail(user models.User) error {
+	base, err := mailBaseURL()
+	if err != nil {
+		return err
+	}
+	token := authtoken.Sign(authtoken.PurposeVerifyEmail, user.ID, verifyFingerprint(user), verifyEmailTTL)
+	link := base + "/auth/verify?token=" + url.QueryEscape(token)
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Xác minh email của bạn",
+		Body: fmt.Sprintf("Chào %s,\n\nHãy mở link dưới đây để xác minh email và bắt đầu đăng bài:\n%s\n\nLink có hiệu lực trong %d giờ. Nếu bạn không đăng ký tài khoản, hãy bỏ qua email này.\n",
+			user.Name, link, int(verifyEmailTTL.Hours())),
+	})
+}
+
+func sendPasswordResetEmail(user models.User) error {
+	base, err := mailBaseURL()
+	if err != nil {
+		return err
+	}
+	token := authtoken.Sign(authtoken.PurposeResetPassword, user.ID, resetFingerprint(user), resetPasswordTTL)
+	link := base + "/auth/reset?token=" + url.QueryEscape(token)
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Đặt lại mật khẩu",
+		Body: fmt.Sprintf("Chào %s,\n\nChúng tôi nhận được yêu cầu đặt lại mật khẩu cho tài khoản của bạn. Mở link dưới đây để đặt mật khẩu mới:\n%s\n\nLink có hiệu lực trong %d phút và chỉ dùng được một lần. Nếu bạn không yêu cầu, hãy bỏ qua email này.\n",
+			user.Name, link, int(resetPasswordTTL.Minutes())),
+	})
+}
+
+// loadTokenUser kiểm tra token và trả về người dùng tương ứng; fingerprint phải khớp trạng thái hiện tại.
+func loadTokenUser(token, purpose string, fingerprint func(models.User) string) (*models.User, error) {
+	userID, print, err := authtoken.Parse(token, purpose)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := database.Get().First(&user, userID).Error; err != nil {
+		return nil, authtoken.ErrInvalid
+	}
+	if fingerprint(user) != print {
+		return nil, authtoken.ErrInvalid
+	}
+	return &user, nil
+}
+
+func tokenErrorMessage(err error, expired, invalid string) string {
+	if errors.Is(err, authtoken.ErrExpired) {
+		return expired
+	}
+	return invalid
+}
+
+// requireVerifiedEmail chặn tài khoản chưa xác minh email đăng nội dung.
+// Handler chỉ phục vụ API (không có trang để quay về) truyền redirect rỗng để nhận lỗi dạng JSON.
+func requireVerifiedEmail(c *fiber.Ctx, userID uint, redirect string) (bool, error) {
+	var user models.User
+	status, message := fiber.StatusForbidden, "Vui lòng xác minh email trước khi đăng nội dung"
+	if err := database.Get().Select("id", "email_verified_at").First(&user, userID).Error; err != nil {
+		status, message = fiber.StatusUnauthorized, "Bạn cần đăng nhập"
+	} else if user.EmailVerified() {
+		return true, nil
+	}
+	if redirect == "" {
+		return false, c.Status(status).JSON(fiber.Map{"error": message})
+	}
+	if status == fiber.StatusUnauthorized {
+		redirect = "/auth/login"
+	}
+	return false, respondError(c, status, message, redirect)
+}
+
+// markSessionVerified cập nhật cờ xác minh trong phiên hiện tại nếu phiên thuộc về userID.
+func markSessionVerified(c *fiber.Ctx, userID uint) {
+	sess, err := sessionStore.Get(c)
+	if err != nil {
+		return
+	}
+	if id, ok := sessionUserID(sess); ok && id == userID {
+		sess.Delete("emailUnverified")
+		_ = sess.Save()
+	}
+}
+
+// VerifyEmail xác nhận email qua link trong thư.
+func VerifyEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadTokenUser(c.Query("token"), authtoken.PurposeVerifyEmail, verifyFingerprint)
+		if err != nil {
+			message := tokenErrorMessage(err, "Link xác minh đã hết hạn, hãy gửi lại email xác minh", "Link xác minh không hợp lệ")
+			if wantsJSON(c) {
+				return fiber.NewError(fiber.StatusBadRequest, message)
+			}
+			return respondError(c, fiber.StatusBadRequest, message, "/")
+		}
+
+		if !user.EmailVerified() {
+			now := time.Now()
+			if err := database.Get().Model(user).Update("email_verified_at", now).Error; err != nil {
+				return respondError(c, fiber.StatusInternalServerError, "Không thể xác minh email", "/")
+			}
+		}
+		markSessionVerified(c, user.ID)
+
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"message": "Email đã được xác minh"})
+		}
+		setFlash(c, "success", "Email đã được xác minh, bạn có thể bắt đầu đăng bài!")
+		return c.Status(fiber.StatusSeeOther).Redirect("/")
+	}
+}
+
+// ResendVerification gửi lại email xác minh cho người dùng hiện tại.
+func ResendVerification() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login")
+		}
+		var user models.User
+		if err := database.Get().First(&user, userID).Error; err != nil {
+			return respondError(c, fiber.StatusNotFound, "Tài khoản không tồn tại", "/")
+		}
+
+		redirect := sameOriginReferer(c)
+		if user.EmailVerified() {
+			markSessionVerified(c, user.ID)
+			if isJSONRequest(c) {
+				return c.JSON(fiber.Map{"message": "Email đã được xác minh"})
+			}
+			setFlash(c, "success", "Email của bạn đã được xác minh")
+			return c.Status(fiber.StatusSeeOther).Redirect(redirect)
+		}
+
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("failed to send verification email: %v", err)
+			return respondError(c, fiber.StatusInternalServerError, "Không thể gửi email xác minh", redirect)
+		}
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"message": "Đã gửi lại email xác minh"})
+		}
+		setFlash(c, "success", fmt.Sprintf("Đã gửi email xác minh tới %s", user.Email))
+		return c.Status(fiber.StatusSeeOther).Redirect(redirect)
+	}
+}
+
+func ForgotPasswordPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return render(c, "pages/auth_forgot", fiber.Map{
+			"Title": "Quên mật khẩu",
+		}, "main")
+	}
+}
+
+// ForgotPassword gửi link đặt lại mật khẩu. Phản hồi luôn giống nhau để không lộ email nào đã đăng ký.
+func ForgotPassword() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Email string `json:"email"`
+		}
+		if isJSONRequest(c) {
+			if err := c.BodyParser(&body); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "payload không hợp lệ")
+			}
+		} else {
+			body.Email = c.FormValue("email")
+		}
+		email := strings.ToLower(strings.TrimSpace(body.Email))
+		if _, err := mail.ParseAddress(email); err != nil {
+			return respondError(c, fiber.StatusBadRequest, "Email không hợp lệ", "/auth/forgot")
+		}
+
+		var user models.User
+		err := database.Get().Where("email = ?", email).First(&user).Error
+		switch {
+		case err == nil:
+			if err := sendPasswordResetEmail(user); err != nil {
+				log.Printf("failed to send password reset email: %v", err)
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return respondError(c, fiber.StatusInternalServerError, "Không thể xử lý yêu cầu", "/auth/forgot")
+		}
+
+		message := "Nếu email đã được đăng ký, bạn sẽ nhận được link đặt lại mật khẩu trong vài phút"
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"message": message})
+		}
+		setFlash(c, "success", message)
+		return c.Status(fiber.StatusSeeOther).Redirect("/auth/login")
+	}
+}
+
+func ResetPasswordPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Query("token")
+		if _, err := loadTokenUser(token, authtoken.PurposeResetPassword, resetFingerprint); err != nil {
+			message := tokenErrorMessage(err, "Link đặt lại mật khẩu đã hết hạn, hãy yêu cầu link mới", "Link đặt lại mật khẩu không hợp lệ hoặc đã được sử dụng")
+			return respondError(c, fiber.StatusBadRequest, message, "/auth/forgot")
+		}
+		return render(c, "pages/auth_reset", fiber.Map{
+			"Title": "Đặt lại mật khẩu",
+			"Token": token,
+		}, "main")
+	}
+}
+
+// ResetPassword đặt mật khẩu mới và đăng xuất mọi phiên cũ của tài khoản.
+func ResetPassword() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Token           string `json:"token"`
+			Password        string `json:"password"`
+			PasswordConfirm string `json:"password_confirm"`
+		}
+		if isJSONRequest(c) {
+			if err := c.BodyParser(&body); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "payload không hợp lệ")
+			}
+		} else {
+			body.Token = c.FormValue("token")
+			body.Password = c.FormValue("password")
+			body.PasswordConfirm = c.FormValue("password_confirm")
+		}
+
+		user, err := loadTokenUser(body.Token, authtoken.PurposeResetPassword, resetFingerprint)
+		if err != nil {
+			message := tokenErrorMessage(err, "Link đặt lại mật khẩu đã hết hạn, hãy yêu cầu link mới", "Link đặt lại mật khẩu không hợp lệ hoặc đã được sử dụng")
+			return respondError(c, fiber.StatusBadRequest, message, "/auth/forgot")
+		}
+
+		retry := "/auth/reset?token=" + url.QueryEscape(body.Token)
+		password := strings.TrimSpace(body.Password)
+		if len(password) < 6 {
+			return respondError(c, fiber.StatusBadRequest, "Mật khẩu phải từ 6 ký tự", retry)
+		}
+		if !isJSONRequest(c) && password != strings.TrimSpace(body.PasswordConfirm) {
+			return respondError(c, fiber.StatusBadRequest, "Mật khẩu nhập lại không khớp", retry)
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể mã hóa mật khẩu", retry)
+		}
+		db := database.Get()
+		updates := map[string]interface{}{"password_hash": string(hash)}
+		// Nhận được link qua email cũng chứng minh quyền sở hữu email
+		if !user.EmailVerified() {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := db.Model(user).Updates(updates).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể đặt lại mật khẩu", retry)
+		}
+		if _, err := revokeUserSessions(db, user.ID, ""); err != nil {
+			log.Printf("failed to revoke sessions after password reset: %v", err)
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"message": "Đã đặt lại mật khẩu"})
+		}
+		setFlash(c, "success", "Đã đặt lại mật khẩu, hãy đăng nhập bằng mật khẩu mới")
+		return c.Status(fiber.StatusSeeOther).Redirect("/auth/login")
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+)
+
+const localsCurrentUser = "currentUser"
+
+// currentUser trả người dùng đang đăng nhập (kèm vai trò), nil với khách.
+// Kết quả được giữ trong request để nhiều lần kiểm tra quyền chỉ tốn một truy vấn.
+func currentUser(c *fiber.Ctx) *models.User {
+	if user, ok := c.Locals(localsCurrentUser).(*models.User); ok {
+		return user
+	}
+	userID, err := currentUserID(c)
+	if err != nil || userID == 0 {
+		return nil
+	}
+	var user models.User
+	if err := database.Get().First(&user, userID).Error; err != nil {
+		return nil
+	}
+	c.Locals(localsCurrentUser, &user)
+	return &user
+}
+
+// can là điểm kiểm tra quyền duy nhất của handlers, xem policy.Can.
+func can(user *models.User, action policy.Action, resource interface{}) bool {
+	return policy.Can(user, action, resource)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fiber-learning-community/internal/bookexport"
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Định dạng xuất sách.
+const (
+	exportFormatEPUB  = "epub"
+	exportFormatPrint = "html"
+)
+
+// exportCacheLimit giới hạn số file xuất giữ trong bộ nhớ; vượt quá thì bỏ bản cũ nhất.
+const exportCacheLimit = 32
+
+type exportCacheEntry struct {
+	version string
+	data    []byte
+	builtAt time.Time
+}
+
+// exportCache giữ file xuất theo sách và định dạng. Mỗi bản kèm version tính từ dữ liệu sách nên
+// replica khác sửa trang cũng làm bản cũ hết hiệu lực; sửa trang trên replica này thì xóa ngay.
+var exportCache = struct {
+	sync.Mutex
+	entries map[string]exportCacheEntry
+}{entries: map[string]exportCacheEntry{}}
+
+func exportCacheKey(bookID uint, format string) string {
+	return fmt.Sprintf("%d/%s", bookID, format)
+}
+
+// invalidateBookExports xóa các file xuất đã cache của sách, gọi sau mỗi thay đổi trang.
+func invalidateBookExports(bookID uint) {
+	exportCache.Lock()
+	defer exportCache.Unlock()
+	for _, format := range []string{exportFormatEPUB, exportFormatPrint} {
+		delete(exportCache.entries, exportCacheKey(bookID, format))
+	}
+}
+
+func cachedExport(key, version string) ([]byte, bool) {
+	exportCache.Lock()
+	defer exportCache.Unlock()
+	entry, ok := exportCache.entries[key]
+	if !ok || entry.version != version {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+func storeExport(key, version string, data []byte) {
+	exportCache.Lock()
+	defer exportCache.Unlock()
+	if _, ok := exportCache.entries[key]; !ok && len(exportCache.entries) >= exportCacheLimit {
+		var oldestKey string
+		var oldest time.Time
+		for k, entry := range exportCache.entries {
+			if oldestKey == "" || entry.builtAt.Before(oldest) {
+				oldestKey, oldest = k, entry.builtAt
+			}
+		}
+		delete(exportCache.entries, oldestKey)
+	}
+	exportCache.entries[key] = exportCacheEntry{version: version, data: data, builtAt: time.Now()}
+}
+
+// bookExportVersion tóm tắt mọi thứ ảnh hưởng tới file xuất: thông tin sách, số trang/chương
+// và lần sửa gần nhất. Thêm, xóa, sửa hay sắp xếp trang đều làm version thay đổi.
+func bookExportVersion(db *gorm.DB, book models.Book) (string, error) {
+	type stats struct {
+		Count  int64
+		Latest *time.Time
+	}
+	var pages, chapters stats
+	if err := db.Model(&models.BookPage{}).Where("book_id = ?", book.ID).
+		Select("COUNT(*) AS count, MAX(updated_at) AS latest").Scan(&pages).Error; err != nil {
+		return "", err
+	}
+	if err := db.Model(&models.BookChapter{}).Where("book_id = ?", book.ID).
+		Select("COUNT(*) AS count, MAX(updated_at) AS latest").Scan(&chapters).Error; err != nil {
+		return "", err
+	}
+	stamp := func(t *time.Time) int64 {
+		if t == nil {
+			return 0
+		}
+		return t.UnixNano()
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d|%d|%d|%d|%d|%s",
+		book.UpdatedAt.UnixNano(), pages.Count, stamp(pages.Latest), chapters.Count, stamp(chapters.Latest), book.AuthorName)))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// exportTOC chuyển mục lục của trang đọc sang dạng của gói bookexport.
+func exportTOC(entries []tocEntry) []bookexport.TOCEntry {
+	out := make([]bookexport.TOCEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.PageID == 0 {
+			continue
+		}
+		out = append(out, bookexport.TOCEntry{Title: entry.Title, PageID: entry.PageID, Children: exportTOC(entry.Children)})
+	}
+	return out
+}
+
+func loadExportImage(id uint) (*bookexport.Image, error) {
+	var image models.Image
+	if err := database.Get().Select("content_type", "data").First(&image, id).Error; err != nil {
+		return nil, err
+	}
+	return &bookexport.Image{ContentType: image.ContentType, Data: image.Data}, nil
+}
+
+// buildBookExport dựng file xuất từ dữ liệu sách hiện tại.
+func buildBookExport(c *fiber.Ctx, db *gorm.DB, book models.Book, format string) ([]byte, error) {
+	var pages []models.BookPage
+	if err := db.Where("book_id = ?", book.ID).Order("page_number ASC").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	chapters, err := loadBookChapters(db, book.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	input := bookexport.Book{
+		ID:          book.ID,
+		Title:       book.Title,
+		Author:      book.AuthorName,
+		Description: book.Description,
+		Modified:    book.UpdatedAt,
+		CoverURL:    book.CoverURL,
+		CoverColor:  book.CoverColor,
+		BaseURL:     siteBaseURL(c),
+		TOC:         exportTOC(buildTableOfContents(chapters, pages)),
+	}
+	for _, page := range pages {
+		input.Pages = append(input.Pages, bookexport.Page{ID: page.ID, Title: page.Title, Content: page.Content})
+		if page.UpdatedAt.After(input.Modified) {
+			input.Modified = page.UpdatedAt
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == exportFormatEPUB {
+		err = bookexport.WriteEPUB(&buf, input, loadExportImage)
+	} else {
+		err = bookexport.WritePrintHTML(&buf, input, loadExportImage)
+	}
+	return buf.Bytes(), err
+}
+
+// BookExport trả sách dạng EPUB (/books/:slug/export.epub) hoặc một trang HTML để in/lưu PDF
+// (/books/:slug/export.html). File được cache cho tới khi sách hoặc trang thay đổi.
+func BookExport(format string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+		user := currentUser(c)
+
+		bookID, _, err := resolveSlugParam(db, models.SlugResourceBook, c.Params("slug"))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString("Không tìm thấy sách")
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("Lỗi tải sách")
+		}
+		var book models.Book
+		if err := db.Preload("Author").First(&book, bookID).Error; err != nil || !can(user, policy.View, book) {
+			return c.Status(fiber.StatusNotFound).SendString("Không tìm thấy sách")
+		}
+		book.AuthorName = book.Author.Name
+
+		version, err := bookExportVersion(db, book)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Lỗi tải sách")
+		}
+		etag := `"` + format + "-" + version + `"`
+		c.Set(fiber.HeaderETag, etag)
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+		if c.Get(fiber.HeaderIfNoneMatch) == etag {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+
+		key := exportCacheKey(book.ID, format)
+		data, ok := cachedExport(key, version)
+		if !ok {
+			data, err = buildBookExport(c, db, book, format)
+			if err != nil {
+				log.Printf("Error exporting book %d as %s: %v", book.ID, format, err)
+				return c.Status(fiber.StatusInternalServerError).SendString("Lỗi xuất sách")
+			}
+			storeExport(key, version, data)
+		}
+
+		filename := book.Slug
+		if filename == "" {
+			filename = fmt.Sprintf("book-%d", book.ID)
+		}
+		if format == exportFormatEPUB {
+			c.Set(fiber.HeaderContentType, "application/epub+zip")
+			c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.epub"`, filename))
+		} else {
+			c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+			c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s.html"`, filename))
+		}
+		return c.Send(data)
+	}
+}
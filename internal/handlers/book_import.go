@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"fiber-learning-community/internal/bookimport"
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"gorm.io/gorm"
+)
+
+// maxImportUploadSize là dung lượng file ZIP tải lên qua web. Body của request vẫn theo giới hạn
+// mặc định của Fiber (4MB) cho mọi route; file lớn hơn nhập bằng lệnh import-book (tối đa bookimport.MaxArchiveSize).
+const maxImportUploadSize = fiber.DefaultBodyLimit - 512<<10
+
+// BookImportOptions ghi đè thông tin trong book.yml của file ZIP; trường rỗng thì giữ nguyên.
+type BookImportOptions struct {
+	Title       string
+	Description string
+	Category    string
+	Tag         string
+}
+
+// BookImportResult tóm tắt sách vừa nhập.
+type BookImportResult struct {
+	Book     models.Book
+	Pages    int
+	Chapters int
+	Images   int
+	Warnings []string // Ảnh không tìm thấy hoặc không hợp lệ, chương sâu quá mức cho phép...
+}
+
+// importImage kiểm tra ảnh trong file ZIP theo cùng quy tắc với ảnh tải lên trực tiếp.
+func importImage(name string, data []byte, uploaderID uint) (*models.Image, error) {
+	contentType, ok := imageUploadTypes[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return nil, bookimport.Error(fmt.Sprintf("%s: chỉ hỗ trợ file ảnh jpg, jpeg, png, gif, webp", name))
+	}
+	if len(data) > maxImageUploadSize {
+		return nil, bookimport.Error(fmt.Sprintf("%s: kích thước ảnh không được vượt quá 5MB", name))
+	}
+	return &models.Image{
+		Filename:    path.Base(name),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Data:        data,
+		UploaderID:  uploaderID,
+	}, nil
+}
+
+// bookImporter lưu ảnh của file ZIP vào kho ảnh, mỗi file một lần dù được nhiều trang dùng.
+type bookImporter struct {
+	tx       *gorm.DB
+	archive  *bookimport.Archive
+	author   *models.User
+	images   map[string]uint
+	warnings []string
+}
+
+func (im *bookImporter) warn(format string, args ...interface{}) {
+	im.warnings = append(im.warnings, fmt.Sprintf(format, args...))
+}
+
+// uploadImage trả về ID ảnh trong kho cho file name, hoặc 0 nếu file không có hay không phải ảnh hợp lệ.
+func (im *bookImporter) uploadImage(name string) (uint, error) {
+	if id, ok := im.images[name]; ok {
+		return id, nil
+	}
+	data, ok := im.archive.Files[name]
+	if !ok {
+		return 0, nil
+	}
+	image, err := importImage(name, data, im.author.ID)
+	var importErr bookimport.Error
+	if errors.As(err, &importErr) {
+		im.warn("%s", importErr)
+		im.images[name] = 0
+		return 0, nil
+	}
+	if err := im.tx.Create(image).Error; err != nil {
+		return 0, err
+	}
+	im.images[name] = image.ID
+	return image.ID, nil
+}
+
+// rewriteImages đổi src ảnh tương đối trong HTML của trang thành /images/:id sau khi lưu ảnh vào kho.
+func (im *bookImporter) rewriteImages(page bookimport.Page, content string) (string, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), context)
+	if err != nil {
+		return "", err
+	}
+
+	changed := false
+	var walk func(node *html.Node) error
+	walk = func(node *html.Node) error {
+		if node.Type == html.ElementNode && node.DataAtom == atom.Img {
+			for i, a := range node.Attr {
+				if a.Key != "src" {
+					continue
+				}
+				name, local := bookimport.ResolvePath(page.Path, a.Val)
+				if !local {
+					break
+				}
+				id, err := im.uploadImage(name)
+				if err != nil {
+					return err
+				}
+				if id != 0 {
+					node.Attr[i].Val = fmt.Sprintf("/images/%d", id)
+					changed = true
+				} else if !strings.HasPrefix(a.Val, "/") {
+					im.warn("%s: không tìm thấy ảnh %s", page.Path, a.Val)
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, node := range nodes {
+		if err := walk(node); err != nil {
+			return "", err
+		}
+	}
+	if !changed {
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		if err := html.Render(&buf, node); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// chapterID tạo (nếu chưa có) chuỗi chương lồng nhau của trang và trả về ID chương trong cùng.
+func (im *bookImporter) chapterID(bookID uint, titles []string, created map[string]uint, positions map[uint]int) (*uint, error) {
+	if len(titles) > maxChapterDepth {
+		im.warn("Chương \"%s\" sâu quá %d cấp, các cấp sau được gộp vào cấp %d", strings.Join(titles, " / "), maxChapterDepth, maxChapterDepth)
+		titles = titles[:maxChapterDepth]
+	}
+	var parentID *uint
+	for i := range titles {
+		key := strings.Join(titles[:i+1], "\x00")
+		if id, ok := created[key]; ok {
+			id := id
+			parentID = &id
+			continue
+		}
+		var parentKey uint
+		if parentID != nil {
+			parentKey = *parentID
+		}
+		chapter := models.BookChapter{
+			BookID:   bookID,
+			ParentID: parentID,
+			Position: positions[parentKey],
+			Title:    truncate(titles[i], 200),
+		}
+		if err := im.tx.Create(&chapter).Error; err != nil {
+			return nil, err
+		}
+		positions[parentKey]++
+		created[key] = chapter.ID
+		parentID = &chapter.ID
+	}
+	return parentID, nil
+}
+
+// ImportBookArchive tạo sách từ file ZIP Markdown cho author trong một transaction: sách, chương,
+// ảnh và trang được tạo cùng nhau hoặc không gì cả. Lỗi bookimport.Error là lỗi dữ liệu của người dùng.
+// Dùng chung cho endpoint POST /books/import và lệnh import-book.
+func ImportBookArchive(db *gorm.DB, author *models.User, data []byte, opts BookImportOptions) (*BookImportResult, error) {
+	archive, err := bookimport.Read(data)
+	if err != nil {
+		return nil, err
+	}
+	meta := archive.Meta
+	pick := func(override, value string) string {
+		if override = strings.TrimSpace(override); override != "" {
+			return override
+		}
+		return strings.TrimSpace(value)
+	}
+
+	book := models.Book{
+		Title:        pick(opts.Title, meta.Title),
+		Description:  pick(opts.Description, meta.Description),
+		CoverColor:   strings.TrimSpace(meta.CoverColor),
+		AuthorID:     author.ID,
+		Published:    true, // Giống sách tạo bằng form: publish ngay
+		BookTag:      pick(opts.Tag, meta.Tag),
+		BookCategory: pick(opts.Category, meta.Category),
+	}
+	if book.Title == "" {
+		return nil, bookimport.Error("Thiếu tiêu đề sách: nhập tiêu đề hoặc thêm title vào book.yml")
+	}
+	if book.CoverColor == "" {
+		book.CoverColor = "#1e293b"
+	}
+
+	result := &BookImportResult{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		im := &bookImporter{tx: tx, archive: archive, author: author, images: map[string]uint{}}
+
+		if cover := strings.TrimSpace(meta.Cover); cover != "" {
+			if name, local := bookimport.ResolvePath("book.yml", cover); local {
+				id, err := im.uploadImage(name)
+				if err != nil {
+					return err
+				}
+				if id == 0 {
+					im.warn("book.yml: không tìm thấy ảnh bìa %s", cover)
+				} else {
+					book.CoverURL = fmt.Sprintf("/images/%d", id)
+				}
+			} else if strings.HasPrefix(cover, "http://") || strings.HasPrefix(cover, "https://") {
+				book.CoverURL = cover
+			}
+		}
+
+		if err := assignBookSlug(tx, &book); err != nil {
+			return err
+		}
+		if err := tx.Create(&book).Error; err != nil {
+			return err
+		}
+		if err := syncBookTags(tx, &book); err != nil {
+			return err
+		}
+
+		chapters := map[string]uint{}
+		positions := map[uint]int{}
+		for i, page := range archive.Pages {
+			content, err := im.rewriteImages(page, string(renderMarkdown(page.Body)))
+			if err != nil {
+				return err
+			}
+			chapterID, err := im.chapterID(book.ID, page.Chapter, chapters, positions)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&models.BookPage{
+				BookID:     book.ID,
+				PageNumber: i + 1,
+				ChapterID:  chapterID,
+				Title:      page.Title,
+				Content:    content,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		result.Chapters = len(chapters)
+		for _, id := range im.images {
+			if id != 0 {
+				result.Images++
+			}
+		}
+		result.Warnings = im.warnings
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Book = book
+	result.Pages = len(archive.Pages)
+	invalidateBookExports(book.ID)
+	return result, nil
+}
+
+// BookImportPage hiển thị form nhập sách từ file ZIP Markdown.
+func BookImportPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := getUserForBooks(c)
+		if user == nil {
+			return c.Redirect("/auth/login?next=/books/import")
+		}
+		return render(c, "pages/book_import", fiber.Map{
+			"Title":          "Nhập sách từ ZIP",
+			"MaxArchiveSize": float64(maxImportUploadSize>>10) / 1024,
+			"MaxPages":       bookimport.MaxPages,
+		}, "main")
+	}
+}
+
+// ImportBook nhận file ZIP (trường "archive") và tạo sách mới kèm toàn bộ trang.
+func ImportBook() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		const redirect = "/books/import"
+		user := getUserForBooks(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Chưa đăng nhập", "/auth/login?next=/books/import")
+		}
+		if !can(user, policy.Create, policy.Books) {
+			return respondError(c, fiber.StatusForbidden, "Tài khoản của bạn không có quyền tạo sách", redirect)
+		}
+		if ok, err := requireVerifiedEmail(c, user.ID, redirect); !ok {
+			return err
+		}
+
+		fileHeader, err := c.FormFile("archive")
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "Vui lòng chọn file ZIP", redirect)
+		}
+		if fileHeader.Size > maxImportUploadSize {
+			return respondError(c, fiber.StatusBadRequest, fmt.Sprintf("File ZIP tải lên không được vượt quá %.1fMB, file lớn hơn hãy nhập bằng lệnh import-book", float64(maxImportUploadSize>>10)/1024), redirect)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể đọc file", redirect)
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxImportUploadSize+1))
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể đọc file", redirect)
+		}
+
+		result, err := ImportBookArchive(database.Get(), user, data, BookImportOptions{
+			Title:       c.FormValue("title"),
+			Description: c.FormValue("description"),
+			Category:    c.FormValue("book_category"),
+			Tag:         c.FormValue("book_tag"),
+		})
+		var importErr bookimport.Error
+		if errors.As(err, &importErr) {
+			return respondError(c, fiber.StatusBadRequest, importErr.Error(), redirect)
+		}
+		if err != nil {
+			log.Printf("Error importing book for user %d: %v", user.ID, err)
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi nhập sách", redirect)
+		}
+
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{
+				"success":  true,
+				"book_id":  result.Book.ID,
+				"slug":     result.Book.Slug,
+				"pages":    result.Pages,
+				"chapters": result.Chapters,
+				"images":   result.Images,
+				"warnings": result.Warnings,
+			})
+		}
+		message := fmt.Sprintf("Đã nhập sách với %d trang", result.Pages)
+		if warnings := result.Warnings; len(warnings) > 0 {
+			if len(warnings) > 3 {
+				warnings = append(warnings[:3:3], "...")
+			}
+			message += fmt.Sprintf(" (%d cảnh báo: %s)", len(result.Warnings), strings.Join(warnings, "; "))
+		}
+		setFlash(c, "success", message)
+		return c.Redirect(bookPath(result.Book), fiber.StatusSeeOther)
+	}
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"strconv"
 	"strings"
 
+	"fiber-learning-community/internal/anchor"
 	"fiber-learning-community/internal/database"
 	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -14,17 +17,7 @@ import (
 
 // Helper function to get current user from session
 func getUserForBooks(c *fiber.Ctx) *models.User {
-	userID, err := currentUserID(c)
-	if err != nil || userID == 0 {
-		return nil
-	}
-
-	db := database.Get()
-	var user models.User
-	if err := db.First(&user, userID).Error; err != nil {
-		return nil
-	}
-	return &user
+	return currentUser(c)
 }
 
 // BooksPage hiển thị danh sách sách
@@ -40,12 +33,8 @@ func BooksPage() fiber.Handler {
 		var books []models.Book
 		query := db.Order("created_at DESC")
 
-		// Chỉ hiển thị sách published hoặc sách của mình
-		if user != nil {
-			query = query.Where("published = ? OR author_id = ?", true, user.ID)
-		} else {
-			query = query.Where("published = ?", true)
-		}
+		// Chỉ hiển thị sách người dùng được xem (published, của mình, hoặc tất cả với ban quản trị)
+		query = policy.VisibleBooks(query, user)
 
 		if err := query.Find(&books).Error; err != nil {
 			return c.Status(500).SendString("Lỗi tải danh sách sách")
@@ -76,9 +65,12 @@ func BookDetailPage() fiber.Handler {
 		db := database.Get()
 		user := getUserForBooks(c)
 
-		bookID, err := strconv.Atoi(c.Params("id"))
+		bookID, canonical, err := resolveSlugParam(db, models.SlugResourceBook, c.Params("slug"))
 		if err != nil {
-			return c.Status(400).SendString("ID không hợp lệ")
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(404).SendString("Không tìm thấy sách")
+			}
+			return c.Status(500).SendString("Lỗi tải sách")
 		}
 
 		var book models.Book
@@ -88,20 +80,82 @@ func BookDetailPage() fiber.Handler {
 			return c.Status(404).SendString("Không tìm thấy sách")
 		}
 
+		if !can(user, policy.View, book) {
+			return c.Status(404).SendString("Không tìm thấy sách")
+		}
+
+		if !canonical && book.Slug != "" {
+			return redirectCanonical(c, bookPath(book))
+		}
+
 		// Load author
 		var author models.User
 		if err := db.First(&author, book.AuthorID).Error; err == nil {
 			book.AuthorName = author.Name
 		}
 
-		isAuthor := user != nil && user.ID == book.AuthorID
+		isAuthor := can(user, policy.Edit, book)
 
-		return render(c, "pages/book_detail", fiber.Map{
-			"Title":    book.Title,
-			"Book":     book,
-			"User":     user,
-			"IsAuthor": isAuthor,
-		}, "main")
+		chapters, err := loadBookChapters(db, book.ID)
+		if err != nil {
+			return c.Status(500).SendString("Lỗi tải mục lục")
+		}
+		toc := buildTableOfContents(chapters, book.Pages)
+
+		data := fiber.Map{
+			"Title":       book.Title,
+			"Book":        book,
+			"User":        user,
+			"IsAuthor":    isAuthor,
+			"CanModerate": can(user, policy.Hide, book),
+			"FeedURL":     bookPath(book) + "/feed.xml",
+			"ReadURL":     bookPath(book) + "/read",
+			"ExportURL":   bookPath(book) + "/export",
+			"TOC":         flattenTableOfContents(toc, bookPath(book)+"/read"),
+		}
+		if isAuthor {
+			pageRows := make([]fiber.Map, 0, len(book.Pages))
+			for i, page := range book.Pages {
+				var chapterID, prevID, nextID uint
+				if page.ChapterID != nil {
+					chapterID = *page.ChapterID
+				}
+				if i > 0 {
+					prevID = book.Pages[i-1].ID
+				}
+				if i+1 < len(book.Pages) {
+					nextID = book.Pages[i+1].ID
+				}
+				pageRows = append(pageRows, fiber.Map{
+					"ID":         page.ID,
+					"Title":      pageTitle(page),
+					"PageNumber": page.PageNumber,
+					"ChapterID":  chapterID,
+					"PrevID":     prevID,
+					"NextID":     nextID,
+				})
+			}
+			data["Chapters"] = chapterOptions(toc, chapters)
+			data["PageRows"] = pageRows
+
+			funnel, readers, err := readingFunnel(db, book, book.Pages)
+			if err != nil {
+				log.Printf("Warning: Failed to load reading funnel for book %d: %v", book.ID, err)
+			}
+			data["ReadingFunnel"] = funnel
+			data["ReaderCount"] = readers
+		}
+		if progress := readingProgressFor(db, user, book.ID); progress != nil {
+			for _, page := range book.Pages {
+				if page.ID == progress.PageID {
+					data["ResumeURL"] = bookPath(book) + "/read?page=" + strconv.FormatUint(uint64(page.ID), 10)
+					data["ResumePage"] = page.PageNumber
+					break
+				}
+			}
+		}
+
+		return render(c, "pages/book_detail", data, "main")
 	}
 }
 
@@ -111,9 +165,12 @@ func BookReadPage() fiber.Handler {
 		db := database.Get()
 		user := getUserForBooks(c)
 
-		bookID, err := strconv.Atoi(c.Params("id"))
+		bookID, canonical, err := resolveSlugParam(db, models.SlugResourceBook, c.Params("slug"))
 		if err != nil {
-			return c.Status(400).SendString("ID không hợp lệ")
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(404).SendString("Không tìm thấy sách")
+			}
+			return c.Status(500).SendString("Lỗi tải sách")
 		}
 
 		var book models.Book
@@ -123,16 +180,37 @@ func BookReadPage() fiber.Handler {
 			return c.Status(404).SendString("Không tìm thấy sách")
 		}
 
+		if !can(user, policy.View, book) {
+			if c.Get("Accept") == "application/json" {
+				return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
+			}
+			return c.Status(404).SendString("Không tìm thấy sách")
+		}
+
+		// Reader JS vẫn gọi bằng ID số, chỉ redirect khi mở trang HTML
+		if !canonical && book.Slug != "" && c.Get("Accept") != "application/json" {
+			return redirectCanonical(c, bookPath(book)+"/read")
+		}
+
 		// Load author
 		var author models.User
 		if err := db.First(&author, book.AuthorID).Error; err == nil {
 			book.AuthorName = author.Name
 		}
 
-		isAuthor := user != nil && user.ID == book.AuthorID
+		isAuthor := can(user, policy.Edit, book)
 
 		isAuthenticated := user != nil
 
+		chapters, err := loadBookChapters(db, book.ID)
+		if err != nil {
+			if c.Get("Accept") == "application/json" {
+				return c.Status(500).JSON(fiber.Map{"error": "Lỗi tải mục lục"})
+			}
+			return c.Status(500).SendString("Lỗi tải mục lục")
+		}
+		toc := buildTableOfContents(chapters, book.Pages)
+
 		// Check if request accepts JSON (for AJAX/fetch calls)
 		if c.Get("Accept") == "application/json" {
 			var currentUserID uint
@@ -142,6 +220,7 @@ func BookReadPage() fiber.Handler {
 			
 			return c.JSON(fiber.Map{
 				"id":               book.ID,
+				"slug":             book.Slug,
 				"title":            book.Title,
 				"description":      book.Description,
 				"cover_url":        book.CoverURL,
@@ -151,17 +230,24 @@ func BookReadPage() fiber.Handler {
 				"author_id":        book.AuthorID,
 				"author_name":      book.AuthorName,
 				"published":        book.Published,
+				"hidden":           book.Hidden,
 				"pages":            book.Pages,
+				"chapters":         chapters,
+				"toc":              toc,
+				"progress":         readingProgressFor(db, user, book.ID),
 				"is_author":        isAuthor,
+				"can_moderate":     can(user, policy.Hide, book),
 				"is_authenticated": isAuthenticated,
 				"current_user_id":  currentUserID,
 			})
 		}
 
 		return render(c, "pages/book_read", fiber.Map{
-			"Title":    book.Title,
-			"Book":     book,
-			"IsAuthor": isAuthor,
+			"Title":       book.Title,
+			"Book":        book,
+			"IsAuthor":    isAuthor,
+			"CanModerate": can(user, policy.Hide, book),
+			"TOC":         flattenTableOfContents(toc, bookPath(book)+"/read"),
 		}, "empty")
 	}
 }
@@ -173,6 +259,12 @@ func CreateBook() fiber.Handler {
 		if user == nil {
 			return c.Status(401).JSON(fiber.Map{"error": "Chưa đăng nhập"})
 		}
+		if !can(user, policy.Create, policy.Books) {
+			return c.Status(403).JSON(fiber.Map{"error": "Tài khoản của bạn không có quyền tạo sách"})
+		}
+		if ok, err := requireVerifiedEmail(c, user.ID, ""); !ok {
+			return err
+		}
 
 		db := database.Get()
 
@@ -209,10 +301,18 @@ func CreateBook() fiber.Handler {
 			BookCategory: strings.TrimSpace(req.BookCategory),
 		}
 
+		if err := assignBookSlug(db, &book); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Lỗi tạo đường dẫn sách"})
+		}
+
 		if err := db.Create(&book).Error; err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Lỗi tạo sách"})
 		}
 
+		if err := syncBookTags(db, &book); err != nil {
+			log.Printf("Warning: Failed to sync tags for book %d: %v", book.ID, err)
+		}
+
 		// Tạo trang đầu tiên tự động
 		firstPage := models.BookPage{
 			BookID:     book.ID,
@@ -226,7 +326,7 @@ func CreateBook() fiber.Handler {
 			println("Warning: Failed to create first page:", err.Error())
 		}
 
-		return c.JSON(fiber.Map{"success": true, "book_id": book.ID})
+		return c.JSON(fiber.Map{"success": true, "book_id": book.ID, "slug": book.Slug})
 	}
 }
 
@@ -249,9 +349,12 @@ func UpdateBook() fiber.Handler {
 			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
 		}
 
-		if book.AuthorID != user.ID {
+		if !can(user, policy.Edit, book) {
 			return c.Status(403).JSON(fiber.Map{"error": "Không có quyền"})
 		}
+		if ok, err := requireVerifiedEmail(c, user.ID, ""); !ok {
+			return err
+		}
 
 		var req struct {
 			Title        string `json:"title"`
@@ -277,11 +380,19 @@ func UpdateBook() fiber.Handler {
 		book.BookTag = strings.TrimSpace(req.BookTag)
 		book.BookCategory = strings.TrimSpace(req.BookCategory)
 
+		if err := assignBookSlug(db, &book); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Lỗi cập nhật đường dẫn sách"})
+		}
+
 		if err := db.Save(&book).Error; err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Lỗi cập nhật sách"})
 		}
 
-		return c.JSON(fiber.Map{"success": true})
+		if err := syncBookTags(db, &book); err != nil {
+			log.Printf("Warning: Failed to sync tags for book %d: %v", book.ID, err)
+		}
+
+		return c.JSON(fiber.Map{"success": true, "slug": book.Slug})
 	}
 }
 
@@ -304,9 +415,12 @@ func CreateBookPage() fiber.Handler {
 			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
 		}
 
-		if book.AuthorID != user.ID {
+		if !can(user, policy.Edit, book) {
 			return c.Status(403).JSON(fiber.Map{"error": "Không có quyền"})
 		}
+		if ok, err := requireVerifiedEmail(c, user.ID, ""); !ok {
+			return err
+		}
 
 		var req struct {
 			Title      string `json:"title"`
@@ -318,25 +432,37 @@ func CreateBookPage() fiber.Handler {
 			return c.Status(400).JSON(fiber.Map{"error": "Dữ liệu không hợp lệ"})
 		}
 
-		// Determine page number
-		pageNumber := req.PageNumber
-		if pageNumber <= 0 {
-			// Get next page number
-			var maxPage int
-			db.Model(&models.BookPage{}).Where("book_id = ?", bookID).Select("COALESCE(MAX(page_number), 0)").Scan(&maxPage)
-			pageNumber = maxPage + 1
-		}
-
 		page := models.BookPage{
-			BookID:     uint(bookID),
-			PageNumber: pageNumber,
-			Title:      strings.TrimSpace(req.Title),
-			Content:    req.Content,
+			BookID:  uint(bookID),
+			Title:   strings.TrimSpace(req.Title),
+			Content: req.Content,
 		}
 
-		if err := db.Create(&page).Error; err != nil {
+		// Trang mới luôn thêm vào cuối; nếu client chỉ định page_number thì chèn vào vị trí đó
+		// và đẩy các trang phía sau lùi một số.
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			ids, err := lockBookPages(tx, book.ID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.BookPage{}).Where("book_id = ?", book.ID).
+				Select("COALESCE(MAX(page_number), 0) + 1").Scan(&page.PageNumber).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&page).Error; err != nil {
+				return err
+			}
+			if req.PageNumber <= 0 || req.PageNumber > len(ids) {
+				return nil
+			}
+			position := req.PageNumber - 1
+			order := append(append(append([]uint{}, ids[:position]...), page.ID), ids[position:]...)
+			page.PageNumber = req.PageNumber
+			return renumberBookPages(tx, book.ID, append(ids, page.ID), order)
+		}); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Lỗi tạo trang"})
 		}
+		invalidateBookExports(book.ID)
 
 		return c.JSON(page)
 	}
@@ -359,12 +485,15 @@ func UpdateBookPage() fiber.Handler {
 			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
 		}
 
-		if book.AuthorID != user.ID {
+		if !can(user, policy.Edit, book) {
 			return c.Status(403).JSON(fiber.Map{"error": "Không có quyền"})
 		}
+		if ok, err := requireVerifiedEmail(c, user.ID, ""); !ok {
+			return err
+		}
 
 		var page models.BookPage
-		if err := db.First(&page, pageID).Error; err != nil {
+		if err := db.Where("book_id = ?", book.ID).First(&page, pageID).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy trang"})
 		}
 
@@ -377,12 +506,22 @@ func UpdateBookPage() fiber.Handler {
 			return c.Status(400).JSON(fiber.Map{"error": "Dữ liệu không hợp lệ"})
 		}
 
+		oldContent := page.Content
 		page.Title = strings.TrimSpace(req.Title)
 		page.Content = req.Content
 
-		if err := db.Save(&page).Error; err != nil {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&page).Error; err != nil {
+				return err
+			}
+			if oldContent == page.Content {
+				return nil
+			}
+			return reanchorHighlights(tx, page.ID, oldContent, page.Content)
+		}); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Lỗi cập nhật trang"})
 		}
+		invalidateBookExports(book.ID)
 
 		return c.JSON(fiber.Map{"success": true})
 	}
@@ -405,8 +544,8 @@ func DeleteBook() fiber.Handler {
 			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
 		}
 
-		// Kiểm tra quyền - chỉ author mới được xóa
-		if book.AuthorID != user.ID {
+		// Kiểm tra quyền - tác giả hoặc ban quản trị
+		if !can(user, policy.Delete, book) {
 			return c.Status(403).JSON(fiber.Map{"error": "Bạn không có quyền xóa sách này"})
 		}
 
@@ -431,6 +570,14 @@ func DeleteBook() fiber.Handler {
 				return err
 			}
 
+			if err := tx.Where("book_id = ?", bookID).Delete(&models.BookChapter{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Where("book_id = ?", bookID).Delete(&models.ReadingProgress{}).Error; err != nil {
+				return err
+			}
+
 			// 4. Xóa sách
 			if err := tx.Unscoped().Delete(&book).Error; err != nil {
 				return err
@@ -443,6 +590,7 @@ func DeleteBook() fiber.Handler {
 			log.Printf("Error deleting book %d: %v", bookID, err)
 			return c.Status(500).JSON(fiber.Map{"error": "Lỗi khi xóa sách"})
 		}
+		invalidateBookExports(book.ID)
 
 		return c.JSON(fiber.Map{
 			"success": true,
@@ -468,18 +616,91 @@ func DeleteBookPage() fiber.Handler {
 			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
 		}
 
-		if book.AuthorID != user.ID {
+		if !can(user, policy.Edit, book) {
 			return c.Status(403).JSON(fiber.Map{"error": "Không có quyền"})
 		}
 
-		if err := db.Delete(&models.BookPage{}, pageID).Error; err != nil {
+		// Xóa và đánh lại số các trang còn lại trong cùng transaction để dãy số không bị hổng
+		var deleted int64
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			ids, err := lockBookPages(tx, book.ID)
+			if err != nil {
+				return err
+			}
+			result := tx.Where("book_id = ?", book.ID).Delete(&models.BookPage{}, pageID)
+			deleted = result.RowsAffected
+			if result.Error != nil || deleted == 0 {
+				return result.Error
+			}
+			remaining := make([]uint, 0, len(ids))
+			for _, id := range ids {
+				if id != uint(pageID) {
+					remaining = append(remaining, id)
+				}
+			}
+			return renumberBookPages(tx, book.ID, nil, remaining)
+		}); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Lỗi xóa trang"})
 		}
+		if deleted == 0 {
+			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy trang"})
+		}
+		invalidateBookExports(book.ID)
 
 		return c.JSON(fiber.Map{"success": true})
 	}
 }
 
+// highlightQuote trả về selector của highlight. Highlight cũ chỉ có offset thì lấy
+// đoạn đã tô làm exact và lấy ngữ cảnh từ nội dung trang trước khi sửa.
+func highlightQuote(h models.Highlight, oldText string) anchor.TextQuote {
+	if h.QuoteExact != "" {
+		return anchor.TextQuote{Exact: h.QuoteExact, Prefix: h.QuotePrefix, Suffix: h.QuoteSuffix}
+	}
+	if q, ok := anchor.QuoteAt(oldText, h.StartOffset, h.EndOffset); ok && q.Exact == h.HighlightedText {
+		return q
+	}
+	return anchor.TextQuote{Exact: h.HighlightedText}
+}
+
+// reanchorHighlights gắn lại mọi highlight của trang sau khi nội dung thay đổi.
+// Highlight không còn tìm thấy đoạn trích được đánh dấu detached thay vì giữ offset cũ;
+// highlight đã detached sẽ tự gắn lại nếu đoạn trích xuất hiện trở lại.
+func reanchorHighlights(tx *gorm.DB, pageID uint, oldContent, newContent string) error {
+	var highlights []models.Highlight
+	if err := tx.Where("book_page_id = ?", pageID).Find(&highlights).Error; err != nil {
+		return err
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+
+	oldText := anchor.PlainText(oldContent)
+	newText := anchor.PlainText(newContent)
+	for _, h := range highlights {
+		q := highlightQuote(h, oldText)
+		if q.Exact == "" {
+			continue
+		}
+		updates := map[string]interface{}{
+			"quote_exact":  q.Exact,
+			"quote_prefix": q.Prefix,
+			"quote_suffix": q.Suffix,
+		}
+		if start, end, ok := anchor.FindQuote(newText, q, h.StartOffset); ok {
+			updates["start_offset"] = start
+			updates["end_offset"] = end
+			updates["detached"] = false
+		} else {
+			updates["detached"] = true
+		}
+		if err := tx.Model(&models.Highlight{}).Where("id = ?", h.ID).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SaveHighlight lưu highlight mới
 func SaveHighlight() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -498,6 +719,12 @@ func SaveHighlight() fiber.Handler {
 			log.Printf("Book not found: %v", err)
 			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
 		}
+		if !can(user, policy.View, book) {
+			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
+		}
+		if !can(user, policy.Create, policy.Highlights) {
+			return c.Status(403).JSON(fiber.Map{"error": "Không có quyền"})
+		}
 
 		// Verify page belongs to book
 		var page models.BookPage
@@ -512,6 +739,8 @@ func SaveHighlight() fiber.Handler {
 			Note            string `json:"note"`
 			StartOffset     int    `json:"start_offset"`
 			EndOffset       int    `json:"end_offset"`
+			Prefix          string `json:"prefix"`
+			Suffix          string `json:"suffix"`
 		}
 
 		if err := c.BodyParser(&payload); err != nil {
@@ -527,6 +756,16 @@ func SaveHighlight() fiber.Handler {
 			StartOffset:     payload.StartOffset,
 			EndOffset:       payload.EndOffset,
 		}
+		// Offset phía client có thể tính trên nội dung chưa kịp lưu; chỉ chỉnh lại
+		// theo nội dung đã lưu khi tìm thấy đoạn trích, nếu không thì giữ nguyên dữ liệu client gửi.
+		quote := anchor.TextQuote{Exact: payload.HighlightedText, Prefix: payload.Prefix, Suffix: payload.Suffix}.Trimmed()
+		highlight.QuoteExact = quote.Exact
+		highlight.QuotePrefix = quote.Prefix
+		highlight.QuoteSuffix = quote.Suffix
+		if start, end, ok := anchor.FindQuote(anchor.PlainText(page.Content), quote, highlight.StartOffset); ok {
+			highlight.StartOffset = start
+			highlight.EndOffset = end
+		}
 
 		if err := db.Create(&highlight).Error; err != nil {
 			log.Printf("Error creating highlight: %v, PageID: %d, UserID: %d", err, page.ID, user.ID)
@@ -552,28 +791,24 @@ func GetHighlights() fiber.Handler {
 
 		// Get current user (may be nil if not logged in)
 		user := getUserForBooks(c)
+		if !can(user, policy.View, book) {
+			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
+		}
 
-		// Load highlights based on user role:
-		// - Not logged in: see only author's highlights
-		// - Author: see only their own highlights
-		// - Other users: see author's highlights + their own highlights
+		// Trang phải thuộc sách vừa kiểm tra quyền xem, nếu không có thể đọc highlight của trang trong sách ẩn
+		var page models.BookPage
+		if err := db.Select("id").Where("id = ? AND book_id = ?", pageID, book.ID).First(&page).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy trang"})
+		}
+
+		// Highlight của tác giả hiển thị cho mọi người đọc; người đã đăng nhập thấy thêm highlight của mình
+		userIDs := []uint{book.AuthorID}
+		if user != nil {
+			userIDs = append(userIDs, user.ID)
+		}
 		var highlights []models.Highlight
-		
-		if user == nil {
-			// Not logged in: load only author's highlights
-			if err := db.Where("book_page_id = ? AND user_id = ?", pageID, book.AuthorID).Find(&highlights).Error; err != nil {
-				return c.Status(500).JSON(fiber.Map{"error": "Lỗi tải highlights"})
-			}
-		} else if book.AuthorID == user.ID {
-			// Author: load only their own highlights
-			if err := db.Where("book_page_id = ? AND user_id = ?", pageID, user.ID).Find(&highlights).Error; err != nil {
-				return c.Status(500).JSON(fiber.Map{"error": "Lỗi tải highlights"})
-			}
-		} else {
-			// Non-author logged in: load author's highlights + own highlights
-			if err := db.Where("book_page_id = ? AND (user_id = ? OR user_id = ?)", pageID, book.AuthorID, user.ID).Find(&highlights).Error; err != nil {
-				return c.Status(500).JSON(fiber.Map{"error": "Lỗi tải highlights"})
-			}
+		if err := db.Where("book_page_id = ? AND user_id IN ?", page.ID, userIDs).Find(&highlights).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Lỗi tải highlights"})
 		}
 
 		// Return highlights
@@ -584,6 +819,10 @@ func GetHighlights() fiber.Handler {
 			Note            string `json:"note"`
 			StartOffset     int    `json:"start_offset"`
 			EndOffset       int    `json:"end_offset"`
+			QuoteExact      string `json:"quote_exact"`
+			QuotePrefix     string `json:"quote_prefix"`
+			QuoteSuffix     string `json:"quote_suffix"`
+			Detached        bool   `json:"detached"`
 		}
 
 		// Initialize as empty slice instead of nil to ensure JSON returns [] not null
@@ -596,6 +835,10 @@ func GetHighlights() fiber.Handler {
 				Note:            h.Note,
 				StartOffset:     h.StartOffset,
 				EndOffset:       h.EndOffset,
+				QuoteExact:      h.QuoteExact,
+				QuotePrefix:     h.QuotePrefix,
+				QuoteSuffix:     h.QuoteSuffix,
+				Detached:        h.Detached,
 			})
 		}
 
@@ -619,7 +862,7 @@ func DeleteHighlight() fiber.Handler {
 			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy highlight"})
 		}
 
-		if highlight.UserID != user.ID {
+		if !can(user, policy.Delete, highlight) {
 			return c.Status(403).JSON(fiber.Map{"error": "Không có quyền"})
 		}
 
@@ -660,12 +903,8 @@ func SearchBooks() fiber.Handler {
 
 		baseQuery := db.Model(&models.Book{})
 
-		// Chỉ tìm sách published hoặc sách của mình
-		if user != nil {
-			baseQuery = baseQuery.Where("(published = ? OR author_id = ?)", true, user.ID)
-		} else {
-			baseQuery = baseQuery.Where("published = ?", true)
-		}
+		// Chỉ tìm sách người dùng được xem
+		baseQuery = policy.VisibleBooks(baseQuery, user)
 
 		// Search trong title, description, và author name
 		searchQuery := baseQuery.
@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxChapterDepth giới hạn số cấp lồng nhau: chương > mục > tiểu mục.
+const maxChapterDepth = 3
+
+// tocEntry là một mục trong mục lục: chương (có thể chứa mục con) hoặc trang không thuộc chương con nào.
+type tocEntry struct {
+	Type       string     `json:"type"` // "chapter" hoặc "page"
+	ID         uint       `json:"id"`
+	Title      string     `json:"title"`
+	PageID     uint       `json:"page_id,omitempty"` // Trang mở khi bấm vào mục; với chương là trang đầu tiên của chương
+	PageNumber int        `json:"page_number,omitempty"`
+	Position   int        `json:"-"`
+	Children   []tocEntry `json:"children,omitempty"`
+}
+
+func pageTitle(page models.BookPage) string {
+	if title := strings.TrimSpace(page.Title); title != "" {
+		return title
+	}
+	return fmt.Sprintf("Trang %d", page.PageNumber)
+}
+
+// buildTableOfContents dựng cây mục lục từ chương và trang của một sách.
+// Các mục cùng cấp xếp theo trang đầu tiên để khớp thứ tự đọc; chương chưa có trang
+// xếp cuối theo Position. Trang trỏ tới chương không tồn tại được coi như trang lẻ.
+func buildTableOfContents(chapters []models.BookChapter, pages []models.BookPage) []tocEntry {
+	known := make(map[uint]bool, len(chapters))
+	for _, chapter := range chapters {
+		known[chapter.ID] = true
+	}
+
+	children := make(map[uint][]models.BookChapter)
+	for _, chapter := range chapters {
+		var parent uint
+		if chapter.ParentID != nil && known[*chapter.ParentID] {
+			parent = *chapter.ParentID
+		}
+		children[parent] = append(children[parent], chapter)
+	}
+
+	sorted := append([]models.BookPage(nil), pages...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].PageNumber < sorted[j].PageNumber })
+	pagesOf := make(map[uint][]models.BookPage)
+	for _, page := range sorted {
+		var chapter uint
+		if page.ChapterID != nil && known[*page.ChapterID] {
+			chapter = *page.ChapterID
+		}
+		pagesOf[chapter] = append(pagesOf[chapter], page)
+	}
+
+	visited := make(map[uint]bool, len(chapters))
+	var build func(parent uint) []tocEntry
+	build = func(parent uint) []tocEntry {
+		var entries []tocEntry
+		for _, page := range pagesOf[parent] {
+			entries = append(entries, tocEntry{
+				Type:       "page",
+				ID:         page.ID,
+				Title:      pageTitle(page),
+				PageID:     page.ID,
+				PageNumber: page.PageNumber,
+			})
+		}
+		for _, chapter := range children[parent] {
+			if visited[chapter.ID] {
+				continue
+			}
+			visited[chapter.ID] = true
+			entry := tocEntry{Type: "chapter", ID: chapter.ID, Title: chapter.Title, Position: chapter.Position}
+			entry.Children = build(chapter.ID)
+			for _, child := range entry.Children {
+				if child.PageID != 0 && (entry.PageID == 0 || child.PageNumber < entry.PageNumber) {
+					entry.PageID, entry.PageNumber = child.PageID, child.PageNumber
+				}
+			}
+			entries = append(entries, entry)
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			if (a.PageID == 0) != (b.PageID == 0) {
+				return b.PageID == 0
+			}
+			if a.PageNumber != b.PageNumber {
+				return a.PageNumber < b.PageNumber
+			}
+			if a.Type != b.Type {
+				return a.Type == "chapter"
+			}
+			if a.Position != b.Position {
+				return a.Position < b.Position
+			}
+			return a.ID < b.ID
+		})
+		return entries
+	}
+	return build(0)
+}
+
+// flattenTableOfContents trải cây mục lục thành danh sách có Depth để template hiển thị thụt lề.
+func flattenTableOfContents(entries []tocEntry, readURL string) []fiber.Map {
+	var items []fiber.Map
+	var walk func(entries []tocEntry, depth int)
+	walk = func(entries []tocEntry, depth int) {
+		for _, entry := range entries {
+			item := fiber.Map{
+				"Type":       entry.Type,
+				"ID":         entry.ID,
+				"Title":      entry.Title,
+				"PageID":     entry.PageID,
+				"PageNumber": entry.PageNumber,
+				"Depth":      depth,
+			}
+			if entry.PageID != 0 {
+				item["URL"] = fmt.Sprintf("%s?page=%d", readURL, entry.PageID)
+			}
+			items = append(items, item)
+			walk(entry.Children, depth+1)
+		}
+	}
+	walk(entries, 0)
+	return items
+}
+
+// chapterOptions trả về danh sách chương theo thứ tự mục lục kèm độ sâu, dùng cho ô chọn chương cha.
+func chapterOptions(entries []tocEntry, chapters []models.BookChapter) []fiber.Map {
+	byID := make(map[uint]models.BookChapter, len(chapters))
+	for _, chapter := range chapters {
+		byID[chapter.ID] = chapter
+	}
+	var options []fiber.Map
+	var walk func(entries []tocEntry, depth int)
+	walk = func(entries []tocEntry, depth int) {
+		for _, entry := range entries {
+			if entry.Type != "chapter" {
+				continue
+			}
+			chapter := byID[entry.ID]
+			var parentID uint
+			if chapter.ParentID != nil {
+				parentID = *chapter.ParentID
+			}
+			options = append(options, fiber.Map{
+				"ID":       chapter.ID,
+				"Title":    chapter.Title,
+				"Label":    strings.Repeat("— ", depth) + chapter.Title,
+				"ParentID": parentID,
+				"Position": chapter.Position,
+				"Depth":    depth,
+			})
+			walk(entry.Children, depth+1)
+		}
+	}
+	walk(entries, 0)
+	return options
+}
+
+func loadBookChapters(db *gorm.DB, bookID uint) ([]models.BookChapter, error) {
+	var chapters []models.BookChapter
+	err := db.Where("book_id = ?", bookID).Order("position ASC, id ASC").Find(&chapters).Error
+	return chapters, err
+}
+
+// loadEditableBook tải sách theo :id và kiểm tra người dùng có quyền sửa.
+func loadEditableBook(c *fiber.Ctx) (*models.Book, error) {
+	user := currentUser(c)
+	if user == nil {
+		return nil, respondError(c, fiber.StatusUnauthorized, "Chưa đăng nhập", "/auth/login")
+	}
+	bookID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, respondError(c, fiber.StatusBadRequest, "ID sách không hợp lệ", "/books")
+	}
+	var book models.Book
+	if err := database.Get().First(&book, bookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, respondError(c, fiber.StatusNotFound, "Không tìm thấy sách", "/books")
+		}
+		return nil, respondError(c, fiber.StatusInternalServerError, "Lỗi tải sách", "/books")
+	}
+	if !can(user, policy.Edit, book) {
+		return nil, respondError(c, fiber.StatusForbidden, "Không có quyền", bookPath(book))
+	}
+	return &book, nil
+}
+
+// chapterParentError kiểm tra chương chapterID (0 khi tạo mới) có thể đặt dưới parentID mà không
+// tạo vòng lặp hay vượt quá maxChapterDepth. Trả về thông báo lỗi, rỗng nếu hợp lệ.
+func chapterParentError(chapters []models.BookChapter, chapterID uint, parentID *uint) string {
+	if parentID == nil {
+		return ""
+	}
+	parentOf := make(map[uint]*uint, len(chapters))
+	for _, chapter := range chapters {
+		parentOf[chapter.ID] = chapter.ParentID
+	}
+	if _, ok := parentOf[*parentID]; !ok {
+		return "Chương cha không thuộc sách này"
+	}
+
+	depth := 1
+	for id := parentID; id != nil; id = parentOf[*id] {
+		if *id == chapterID {
+			return "Không thể đặt chương vào bên trong chính nó"
+		}
+		depth++
+		if depth > len(chapters)+1 {
+			break
+		}
+	}
+
+	// Chiều cao cây con của chương đang di chuyển cũng tính vào độ sâu.
+	height := 0
+	if chapterID != 0 {
+		var measure func(id uint, level int)
+		measure = func(id uint, level int) {
+			if level > height {
+				height = level
+			}
+			for _, chapter := range chapters {
+				if chapter.ParentID != nil && *chapter.ParentID == id && level < maxChapterDepth+1 {
+					measure(chapter.ID, level+1)
+				}
+			}
+		}
+		measure(chapterID, 0)
+	}
+	if depth+height > maxChapterDepth {
+		return fmt.Sprintf("Mục lục chỉ hỗ trợ tối đa %d cấp", maxChapterDepth)
+	}
+	return ""
+}
+
+// chapterForm đọc title, parent_id, position từ JSON hoặc form; parent_id rỗng/0 là chương gốc.
+type chapterForm struct {
+	Title    string `json:"title"`
+	ParentID *uint  `json:"parent_id"`
+	Position *int   `json:"position"`
+}
+
+func parseChapterForm(c *fiber.Ctx) (chapterForm, error) {
+	var form chapterForm
+	if isJSONRequest(c) {
+		if err := c.BodyParser(&form); err != nil {
+			return form, err
+		}
+	} else {
+		form.Title = c.FormValue("title")
+		if value := strings.TrimSpace(c.FormValue("parent_id")); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return form, err
+			}
+			parent := uint(id)
+			form.ParentID = &parent
+		}
+		if value := strings.TrimSpace(c.FormValue("position")); value != "" {
+			position, err := strconv.Atoi(value)
+			if err != nil {
+				return form, err
+			}
+			form.Position = &position
+		}
+	}
+	form.Title = strings.TrimSpace(form.Title)
+	if form.ParentID != nil && *form.ParentID == 0 {
+		form.ParentID = nil
+	}
+	return form, nil
+}
+
+// bookEditDone trả JSON cho client fetch, còn form thì flash và quay về mục lục của sách.
+func bookEditDone(c *fiber.Ctx, book *models.Book, message string, data fiber.Map) error {
+	if wantsJSON(c) {
+		data["success"] = true
+		return c.JSON(data)
+	}
+	setFlash(c, "success", message)
+	return c.Status(fiber.StatusSeeOther).Redirect(bookPath(*book) + "#toc")
+}
+
+// CreateBookChapter thêm chương mới vào cuối cấp được chọn.
+func CreateBookChapter() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		book, err := loadEditableBook(c)
+		if book == nil {
+			return err
+		}
+		redirect := bookPath(*book) + "#toc"
+		if ok, err := requireVerifiedEmail(c, currentUser(c).ID, redirect); !ok {
+			return err
+		}
+
+		form, err := parseChapterForm(c)
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "Dữ liệu không hợp lệ", redirect)
+		}
+		if form.Title == "" || len([]rune(form.Title)) > 200 {
+			return respondError(c, fiber.StatusBadRequest, "Tên chương cần từ 1 đến 200 ký tự", redirect)
+		}
+
+		db := database.Get()
+		chapters, err := loadBookChapters(db, book.ID)
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi tải mục lục", redirect)
+		}
+		if msg := chapterParentError(chapters, 0, form.ParentID); msg != "" {
+			return respondError(c, fiber.StatusBadRequest, msg, redirect)
+		}
+
+		chapter := models.BookChapter{BookID: book.ID, ParentID: form.ParentID, Title: form.Title}
+		if form.Position != nil {
+			chapter.Position = *form.Position
+		} else {
+			for _, sibling := range chapters {
+				sameParent := (sibling.ParentID == nil && form.ParentID == nil) ||
+					(sibling.ParentID != nil && form.ParentID != nil && *sibling.ParentID == *form.ParentID)
+				if sameParent && sibling.Position >= chapter.Position {
+					chapter.Position = sibling.Position + 1
+				}
+			}
+		}
+		if err := db.Create(&chapter).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi tạo chương", redirect)
+		}
+		return bookEditDone(c, book, "Đã thêm chương", fiber.Map{"chapter": chapter})
+	}
+}
+
+func loadBookChapter(c *fiber.Ctx, book *models.Book) (*models.BookChapter, error) {
+	redirect := bookPath(*book) + "#toc"
+	chapterID, err := strconv.Atoi(c.Params("chapterId"))
+	if err != nil {
+		return nil, respondError(c, fiber.StatusBadRequest, "ID chương không hợp lệ", redirect)
+	}
+	var chapter models.BookChapter
+	if err := database.Get().Where("book_id = ?", book.ID).First(&chapter, chapterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, respondError(c, fiber.StatusNotFound, "Không tìm thấy chương", redirect)
+		}
+		return nil, respondError(c, fiber.StatusInternalServerError, "Lỗi tải chương", redirect)
+	}
+	return &chapter, nil
+}
+
+// UpdateBookChapter đổi tên, chuyển chương cha hoặc thứ tự của chương.
+func UpdateBookChapter() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		book, err := loadEditableBook(c)
+		if book == nil {
+			return err
+		}
+		chapter, err := loadBookChapter(c, book)
+		if chapter == nil {
+			return err
+		}
+		redirect := bookPath(*book) + "#toc"
+
+		form, err := parseChapterForm(c)
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "Dữ liệu không hợp lệ", redirect)
+		}
+		if form.Title == "" || len([]rune(form.Title)) > 200 {
+			return respondError(c, fiber.StatusBadRequest, "Tên chương cần từ 1 đến 200 ký tự", redirect)
+		}
+
+		db := database.Get()
+		chapters, err := loadBookChapters(db, book.ID)
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi tải mục lục", redirect)
+		}
+		if msg := chapterParentError(chapters, chapter.ID, form.ParentID); msg != "" {
+			return respondError(c, fiber.StatusBadRequest, msg, redirect)
+		}
+
+		chapter.Title = form.Title
+		chapter.ParentID = form.ParentID
+		if form.Position != nil {
+			chapter.Position = *form.Position
+		}
+		if err := db.Save(chapter).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi cập nhật chương", redirect)
+		}
+		return bookEditDone(c, book, "Đã cập nhật chương", fiber.Map{"chapter": chapter})
+	}
+}
+
+// DeleteBookChapter xóa chương; trang và chương con được chuyển lên chương cha để không mất nội dung.
+func DeleteBookChapter() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		book, err := loadEditableBook(c)
+		if book == nil {
+			return err
+		}
+		chapter, err := loadBookChapter(c, book)
+		if chapter == nil {
+			return err
+		}
+
+		if err := database.Get().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.BookPage{}).Where("chapter_id = ?", chapter.ID).
+				Update("chapter_id", chapter.ParentID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.BookChapter{}).Where("parent_id = ?", chapter.ID).
+				Update("parent_id", chapter.ParentID).Error; err != nil {
+				return err
+			}
+			return tx.Delete(chapter).Error
+		}); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi xóa chương", bookPath(*book)+"#toc")
+		}
+		return bookEditDone(c, book, "Đã xóa chương", fiber.Map{})
+	}
+}
+
+// bookChapterID kiểm tra chapter_id gửi lên thuộc sách; 0 nghĩa là không thuộc chương nào.
+func bookChapterID(db *gorm.DB, bookID uint, chapterID uint) (*uint, error) {
+	if chapterID == 0 {
+		return nil, nil
+	}
+	var chapter models.BookChapter
+	if err := db.Select("id").Where("book_id = ?", bookID).First(&chapter, chapterID).Error; err != nil {
+		return nil, err
+	}
+	return &chapter.ID, nil
+}
+
+// AssignPageChapter chuyển một trang vào chương (hoặc ra ngoài mọi chương khi chapter_id rỗng).
+func AssignPageChapter() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		book, err := loadEditableBook(c)
+		if book == nil {
+			return err
+		}
+		redirect := bookPath(*book) + "#toc"
+
+		var body struct {
+			ChapterID uint `json:"chapter_id"`
+		}
+		if isJSONRequest(c) {
+			if err := c.BodyParser(&body); err != nil {
+				return respondError(c, fiber.StatusBadRequest, "Dữ liệu không hợp lệ", redirect)
+			}
+		} else if value := strings.TrimSpace(c.FormValue("chapter_id")); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return respondError(c, fiber.StatusBadRequest, "Dữ liệu không hợp lệ", redirect)
+			}
+			body.ChapterID = uint(id)
+		}
+
+		pageID, err := strconv.Atoi(c.Params("pageId"))
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "ID trang không hợp lệ", redirect)
+		}
+		db := database.Get()
+		chapterID, err := bookChapterID(db, book.ID, body.ChapterID)
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "Chương không thuộc sách này", redirect)
+		}
+		result := db.Model(&models.BookPage{}).
+			Where("id = ? AND book_id = ?", pageID, book.ID).
+			Update("chapter_id", chapterID)
+		if result.Error != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi cập nhật trang", redirect)
+		}
+		if result.RowsAffected == 0 {
+			return respondError(c, fiber.StatusNotFound, "Không tìm thấy trang", redirect)
+		}
+		invalidateBookExports(book.ID)
+		return bookEditDone(c, book, "Đã chuyển trang", fiber.Map{"chapter_id": chapterID})
+	}
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+)
+
+const (
+	removedCommentText = "Bình luận đã bị xóa"
+	hiddenCommentText  = "Bình luận đã bị ban quản trị ẩn"
+)
+
+type commentNode struct {
+	comment models.Comment
+	replies []*commentNode
+}
+
+func commentAnchor(postID, commentID uint) string {
+	return fmt.Sprintf("/posts/%d#comment-%d", postID, commentID)
+}
+
+// resolveCommentParent kiểm tra bình luận cha và tính độ sâu cho trả lời mới.
+// Khi cha đã ở độ sâu tối đa, trả lời được gắn vào cùng cấp với cha để luồng không sâu thêm.
+func resolveCommentParent(db *gorm.DB, postID, parentID uint) (*models.Comment, error) {
+	var parent models.Comment
+	if err := db.Where("post_id = ?", postID).First(&parent, parentID).Error; err != nil {
+		return nil, err
+	}
+	if parent.Removed {
+		return nil, errors.New("không thể trả lời bình luận đã bị xóa")
+	}
+	for parent.Depth >= models.MaxCommentDepth && parent.ParentID != nil {
+		// Nạp vào biến mới: First với struct còn ID sẽ thêm điều kiện id cũ và không bao giờ tìm thấy
+		var next models.Comment
+		if err := db.Where("post_id = ?", postID).First(&next, *parent.ParentID).Error; err != nil {
+			return nil, err
+		}
+		parent = next
+	}
+	return &parent, nil
+}
+
+// buildCommentThreads dựng cây bình luận, tách bình luận chung và bình luận theo dòng.
+func buildCommentThreads(comments []models.Comment, viewer *models.User) ([]fiber.Map, map[int][]fiber.Map) {
+	nodes := make(map[uint]*commentNode, len(comments))
+	for _, cm := range comments {
+		nodes[cm.ID] = &commentNode{comment: cm}
+	}
+
+	roots := make([]*commentNode, 0)
+	for _, cm := range comments {
+		node := nodes[cm.ID]
+		if cm.ParentID != nil {
+			if parent, ok := nodes[*cm.ParentID]; ok {
+				parent.replies = append(parent.replies, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	general := make([]fiber.Map, 0)
+	byLine := make(map[int][]fiber.Map)
+	for _, root := range roots {
+		item := commentNodeMap(root, viewer)
+		// Bình luận mồ côi (dòng gốc đã bị sửa/xóa) được đưa xuống phần bình luận chung
+		if root.comment.LineNumber != nil && !root.comment.Orphaned {
+			byLine[*root.comment.LineNumber] = append(byLine[*root.comment.LineNumber], item)
+		} else {
+			general = append(general, item)
+		}
+	}
+	return general, byLine
+}
+
+// Bình luận bị ẩn vẫn giữ chỗ trong cây để trả lời không mất ngữ cảnh,
+// nhưng nội dung chỉ hiện với người viết và ban quản trị.
+func commentNodeMap(node *commentNode, viewer *models.User) fiber.Map {
+	cm := node.comment
+	replies := make([]fiber.Map, 0, len(node.replies))
+	for _, reply := range node.replies {
+		replies = append(replies, commentNodeMap(reply, viewer))
+	}
+
+	content := cm.Content
+	authorName := cm.Author.Name
+	masked := cm.Removed || !can(viewer, policy.View, cm)
+	switch {
+	case cm.Removed:
+		content = removedCommentText
+		authorName = ""
+	case masked:
+		content = hiddenCommentText
+		authorName = ""
+	}
+	editedLabel := ""
+	if cm.EditedAt != nil && !masked {
+		editedLabel = formatTimeVN(*cm.EditedAt)
+	}
+
+	return fiber.Map{
+		"ID":          cm.ID,
+		"PostID":      cm.PostID,
+		"Content":     content,
+		"AuthorName":  authorName,
+		"CreatedAt":   formatTimeVN(cm.CreatedAt),
+		"Edited":      editedLabel != "",
+		"EditedLabel": editedLabel,
+		"Removed":     masked,
+		"Hidden":      cm.Hidden,
+		"IsOwner":     !cm.Removed && can(viewer, policy.Edit, cm),
+		"CanHide":     !cm.Removed && can(viewer, policy.Hide, cm),
+		"CanReply":    !masked && can(viewer, policy.Create, policy.Comments),
+		"Depth":       cm.Depth,
+		"Orphaned":    cm.Orphaned && cm.ParentID == nil,
+		"Quote":       cm.AnchorQuote,
+		"Replies":     replies,
+		"ReplyCount":  countCommentReplies(node),
+	}
+}
+
+func countCommentReplies(node *commentNode) int {
+	total := len(node.replies)
+	for _, reply := range node.replies {
+		total += countCommentReplies(reply)
+	}
+	return total
+}
+
+// loadComment tìm bình luận thuộc bài viết trong URL và kiểm tra quyền action của người dùng.
+func loadComment(c *fiber.Ctx, user *models.User, action policy.Action) (*models.Comment, error) {
+	postID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, respondError(c, fiber.StatusBadRequest, "Bài viết không hợp lệ", "/posts")
+	}
+	commentID, err := strconv.Atoi(c.Params("commentId"))
+	if err != nil {
+		return nil, respondError(c, fiber.StatusBadRequest, "Bình luận không hợp lệ", fmt.Sprintf("/posts/%d", postID))
+	}
+
+	var comment models.Comment
+	if err := database.Get().Where("post_id = ?", postID).First(&comment, commentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, respondError(c, fiber.StatusNotFound, "Bình luận không tồn tại", fmt.Sprintf("/posts/%d", postID))
+		}
+		return nil, respondError(c, fiber.StatusInternalServerError, "Không thể tải bình luận", fmt.Sprintf("/posts/%d", postID))
+	}
+	if !can(user, action, comment) {
+		return nil, respondError(c, fiber.StatusForbidden, "Bạn không có quyền thay đổi bình luận này", commentAnchor(comment.PostID, comment.ID))
+	}
+	if comment.Removed {
+		return nil, respondError(c, fiber.StatusGone, "Bình luận đã bị xóa", commentAnchor(comment.PostID, comment.ID))
+	}
+	return &comment, nil
+}
+
+// UpdateComment cho phép người viết (hoặc ban quản trị) sửa nội dung bình luận.
+func UpdateComment() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để sửa bình luận", "/auth/login")
+		}
+
+		comment, err := loadComment(c, user, policy.Edit)
+		if comment == nil {
+			return err
+		}
+
+		var body struct {
+			Content string `json:"content"`
+		}
+		if isJSONRequest(c) {
+			if err := c.BodyParser(&body); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "payload không hợp lệ")
+			}
+		} else {
+			body.Content = c.FormValue("content")
+		}
+
+		content := strings.TrimSpace(body.Content)
+		if len(content) < 3 {
+			return respondError(c, fiber.StatusBadRequest, "Bình luận phải từ 3 ký tự", commentAnchor(comment.PostID, comment.ID))
+		}
+
+		if content != comment.Content {
+			now := time.Now()
+			if err := database.Get().Model(comment).Updates(map[string]interface{}{
+				"content":   content,
+				"edited_at": now,
+			}).Error; err != nil {
+				return respondError(c, fiber.StatusInternalServerError, "Không thể cập nhật bình luận", commentAnchor(comment.PostID, comment.ID))
+			}
+			comment.Content = content
+			comment.EditedAt = &now
+		}
+
+		if isJSONRequest(c) {
+			editedAt := ""
+			if comment.EditedAt != nil {
+				editedAt = formatTimeVN(*comment.EditedAt)
+			}
+			return c.JSON(fiber.Map{
+				"message": "Đã cập nhật bình luận",
+				"comment": fiber.Map{
+					"id":        comment.ID,
+					"content":   comment.Content,
+					"edited":    comment.EditedAt != nil,
+					"edited_at": editedAt,
+				},
+			})
+		}
+
+		setFlash(c, "success", "Đã cập nhật bình luận")
+		return c.Status(fiber.StatusSeeOther).Redirect(commentAnchor(comment.PostID, comment.ID))
+	}
+}
+
+// removeComment xóa bình luận; nếu còn trả lời thì chỉ để lại dấu "đã xóa".
+// Sau khi xóa, các bình luận cha đã bị xóa trước đó mà không còn trả lời nào cũng được dọn luôn.
+func removeComment(tx *gorm.DB, comment models.Comment) (tombstoned bool, err error) {
+	for {
+		var replies int64
+		if err := tx.Model(&models.Comment{}).Where("parent_id = ?", comment.ID).Count(&replies).Error; err != nil {
+			return false, err
+		}
+		if replies > 0 {
+			if comment.Removed {
+				return false, nil
+			}
+			return true, tx.Model(&comment).UpdateColumns(map[string]interface{}{
+				"removed": true,
+				"content": "",
+			}).Error
+		}
+
+		if err := tx.Delete(&comment).Error; err != nil {
+			return false, err
+		}
+		if comment.ParentID == nil {
+			return false, nil
+		}
+
+		var parent models.Comment
+		if err := tx.First(&parent, *comment.ParentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		if !parent.Removed {
+			return false, nil
+		}
+		comment = parent
+	}
+}
+
+// DeleteComment cho phép người viết (hoặc ban quản trị) xóa bình luận.
+func DeleteComment() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để xóa bình luận", "/auth/login")
+		}
+
+		comment, err := loadComment(c, user, policy.Delete)
+		if comment == nil {
+			return err
+		}
+
+		var tombstoned bool
+		if err := database.Get().Transaction(func(tx *gorm.DB) error {
+			var err error
+			tombstoned, err = removeComment(tx, *comment)
+			return err
+		}); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể xóa bình luận", commentAnchor(comment.PostID, comment.ID))
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "tombstone": tombstoned})
+		}
+
+		setFlash(c, "success", "Đã xóa bình luận")
+		redirect := fmt.Sprintf("/posts/%d#comments", comment.PostID)
+		if tombstoned {
+			redirect = commentAnchor(comment.PostID, comment.ID)
+		}
+		return c.Status(fiber.StatusSeeOther).Redirect(localRedirect(c.FormValue("next"), redirect))
+	}
+}
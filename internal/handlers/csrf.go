@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+
+	"fiber-learning-community/internal/sessionstore"
+)
+
+// CSRF dùng mô hình synchronizer token: mỗi phiên đăng nhập có một token lưu trong session,
+// form gửi kèm qua trường ẩn _csrf, còn fetch/JSON client gửi qua header X-CSRF-Token.
+// Khách chưa đăng nhập dùng token gắn với cookie riêng (double submit) để mỗi lượt xem trang
+// không phải ghi một phiên rỗng vào session store.
+const (
+	csrfSessionKey = "csrfToken"
+	csrfCookieName = "csrf_token"
+	csrfFormField  = "_csrf"
+	CSRFHeader     = "X-CSRF-Token"
+	csrfTokenBytes = 32
+)
+
+func newCSRFToken() string {
+	buf := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// ensureCSRFToken trả về token của phiên, tạo mới nếu chưa có. Người gọi chịu trách nhiệm Save session.
+func ensureCSRFToken(sess *session.Session) string {
+	if token, _ := sess.Get(csrfSessionKey).(string); token != "" {
+		return token
+	}
+	token := newCSRFToken()
+	sess.Set(csrfSessionKey, token)
+	return token
+}
+
+// csrfToken trả về token CSRF của request, tạo mới nếu chưa có. Token trong session được ưu tiên;
+// phiên đã đăng nhập mà thiếu token thì tạo vào session (dirty = true, người gọi phải Save),
+// còn khách dùng token trong cookie csrf_token.
+func csrfToken(c *fiber.Ctx, sess *session.Session) (token string, dirty bool) {
+	if token, _ := sess.Get(csrfSessionKey).(string); token != "" {
+		return token, false
+	}
+	if sessionUser(sess) != nil {
+		return ensureCSRFToken(sess), true
+	}
+	if token := c.Cookies(csrfCookieName); len(token) == base64.RawURLEncoding.EncodedLen(csrfTokenBytes) {
+		return token, false
+	}
+	token = newCSRFToken()
+	c.Cookie(&fiber.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(sessionstore.Expiration()),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return token, false
+}
+
+// expectedCSRFToken là token request phải gửi kèm: token của phiên nếu có, nếu không thì token trong cookie.
+func expectedCSRFToken(c *fiber.Ctx) string {
+	if sess, err := sessionStore.Get(c); err == nil {
+		if token, _ := sess.Get(csrfSessionKey).(string); token != "" {
+			return token
+		}
+		if sessionUser(sess) != nil {
+			return ""
+		}
+	}
+	return c.Cookies(csrfCookieName)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// sameOriginReferer trả về đường dẫn của Referer nếu cùng origin, dùng để quay lại form khi token sai.
+func sameOriginReferer(c *fiber.Ctx) string {
+	ref, err := url.Parse(c.Get(fiber.HeaderReferer))
+	if err != nil || ref.Host != c.Hostname() || ref.Path == "" {
+		return "/"
+	}
+	if ref.RawQuery != "" {
+		return ref.Path + "?" + ref.RawQuery
+	}
+	return ref.Path
+}
+
+// CSRFProtection từ chối mọi request thay đổi dữ liệu không kèm token khớp với phiên.
+func CSRFProtection() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Request xác thực bằng Bearer token không dựa vào cookie nên không bị CSRF
+		if isSafeMethod(c.Method()) || requestAPIToken(c) != nil {
+			return c.Next()
+		}
+
+		expected := expectedCSRFToken(c)
+		provided := c.Get(CSRFHeader)
+		if provided == "" {
+			provided = c.FormValue(csrfFormField)
+		}
+		if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(provided)) != 1 {
+			if wantsJSON(c) || c.Get(CSRFHeader) != "" {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "CSRF token không hợp lệ hoặc đã hết hạn"})
+			}
+			return respondError(c, fiber.StatusForbidden, "Phiên làm việc đã hết hạn, vui lòng thử lại", sameOriginReferer(c))
+		}
+		return c.Next()
+	}
+}
+
+// CSRFToken trả token của phiên hiện tại cho JSON client, để gửi lại qua header X-CSRF-Token.
+func CSRFToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sess, err := sessionStore.Get(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Không thể tạo phiên")
+		}
+		token, dirty := csrfToken(c, sess)
+		if dirty {
+			if err := sess.Save(); err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "Không thể lưu phiên")
+			}
+		}
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.JSON(fiber.Map{"csrf_token": token, "header": CSRFHeader})
+	}
+}
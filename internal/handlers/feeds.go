@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+)
+
+const feedLimit = 20
+
+// renderMarkdown được main gán bằng hàm Markdown dùng chung cho template.
+var renderMarkdown = func(input string) template.HTML {
+	return template.HTML(html.EscapeString(input))
+}
+
+// SetMarkdownRenderer đăng ký hàm render Markdown (đã sanitize) cho các handler cần HTML phía server.
+func SetMarkdownRenderer(fn func(string) template.HTML) {
+	if fn != nil {
+		renderMarkdown = fn
+	}
+}
+
+// Định dạng feed hỗ trợ.
+const (
+	feedFormatAtom = "atom"
+	feedFormatRSS  = "rss"
+)
+
+type feedEntry struct {
+	ID        string
+	Title     string
+	URL       string
+	Author    string
+	Summary   string
+	Content   string
+	Tags      []string
+	Published time.Time
+	Updated   time.Time
+}
+
+type feedMeta struct {
+	Title       string
+	Description string
+	Path        string // URL trang HTML tương ứng
+	SelfPath    string
+	Entries     []feedEntry
+}
+
+// siteBaseURL ưu tiên SITE_URL để link trong feed ổn định sau reverse proxy.
+func siteBaseURL(c *fiber.Ctx) string {
+	if base := strings.TrimSpace(os.Getenv("SITE_URL")); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	return c.BaseURL()
+}
+
+var relativeLinkPattern = regexp.MustCompile(`(src|href)="/([^/"])`)
+
+// absolutizeLinks đổi các đường dẫn tương đối (/images/1...) trong HTML thành URL tuyệt đối cho feed reader.
+func absolutizeLinks(content, base string) string {
+	return relativeLinkPattern.ReplaceAllString(content, `$1="`+base+`/$2`)
+}
+
+// feedUpdated là thời điểm thay đổi mới nhất của feed.
+func feedUpdated(entries []feedEntry) time.Time {
+	var latest time.Time
+	for _, e := range entries {
+		if e.Updated.After(latest) {
+			latest = e.Updated
+		}
+	}
+	if latest.IsZero() {
+		latest = time.Unix(0, 0)
+	}
+	return latest.UTC().Truncate(time.Second)
+}
+
+// feedETag băm danh sách entry (ID + thời điểm cập nhật) nên thay đổi khi có bài mới, bài bị sửa hoặc bị gỡ.
+func feedETag(format, selfPath string, entries []feedEntry) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s|%s", format, selfPath)
+	for _, e := range entries {
+		fmt.Fprintf(h, "|%s@%d", e.ID, e.Updated.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:20] + `"`
+}
+
+// notModified xử lý conditional GET: If-None-Match được ưu tiên hơn If-Modified-Since.
+func notModified(c *fiber.Ctx, etag string, lastModified time.Time) bool {
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if since := c.Get(fiber.HeaderIfModifiedSince); since != "" {
+		if t, err := http.ParseTime(since); err == nil && !lastModified.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cấu trúc XML cho Atom 1.0.
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string      `xml:"xml:lang,attr"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Links    []atomLink  `xml:"link"`
+	Updated  string      `xml:"updated"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+// Cấu trúc XML cho RSS 2.0.
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssContent struct {
+	Value string `xml:",cdata"`
+}
+
+type rssItem struct {
+	Title      string      `xml:"title"`
+	Link       string      `xml:"link"`
+	GUID       rssGUID     `xml:"guid"`
+	PubDate    string      `xml:"pubDate"`
+	Creator    string      `xml:"dc:creator,omitempty"`
+	Categories []string    `xml:"category"`
+	Summary    string      `xml:"description"`
+	Content    *rssContent `xml:"content:encoded"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+func buildAtom(meta feedMeta, base string, updated time.Time) atomFeed {
+	feed := atomFeed{
+		Lang:     "vi",
+		ID:       base + meta.SelfPath,
+		Title:    meta.Title,
+		Subtitle: meta.Description,
+		Links: []atomLink{
+			{Href: base + meta.SelfPath, Rel: "self", Type: "application/atom+xml"},
+			{Href: base + meta.Path, Rel: "alternate", Type: "text/html"},
+		},
+		Updated: updated.Format(time.RFC3339),
+		Entries: make([]atomEntry, 0, len(meta.Entries)),
+	}
+	for _, e := range meta.Entries {
+		entry := atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Links:     []atomLink{{Href: base + e.URL, Rel: "alternate", Type: "text/html"}},
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Updated:   e.Updated.UTC().Format(time.RFC3339),
+			Content:   atomText{Type: "html", Body: e.Content},
+		}
+		if e.Author != "" {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+		if e.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: e.Summary}
+		}
+		for _, tag := range e.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+func buildRSS(meta feedMeta, base string, updated time.Time) rssFeed {
+	channel := rssChannel{
+		Title:         meta.Title,
+		Link:          base + meta.Path,
+		Description:   meta.Description,
+		Language:      "vi",
+		LastBuildDate: updated.Format(time.RFC1123Z),
+		AtomLink:      atomLink{Href: base + meta.SelfPath, Rel: "self", Type: "application/rss+xml"},
+		Items:         make([]rssItem, 0, len(meta.Entries)),
+	}
+	for _, e := range meta.Entries {
+		summary := e.Summary
+		if summary == "" {
+			summary = e.Title
+		}
+		channel.Items = append(channel.Items, rssItem{
+			Title:      e.Title,
+			Link:       base + e.URL,
+			GUID:       rssGUID{IsPermaLink: false, Value: e.ID},
+			PubDate:    e.Published.UTC().Format(time.RFC1123Z),
+			Creator:    e.Author,
+			Categories: e.Tags,
+			Summary:    summary,
+			Content:    &rssContent{Value: e.Content},
+		})
+	}
+	return rssFeed{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		Channel:   channel,
+	}
+}
+
+// writeFeed trả feed theo định dạng yêu cầu, hoặc 304 nếu client đã có bản mới nhất.
+func writeFeed(c *fiber.Ctx, format string, meta feedMeta, render func([]feedEntry)) error {
+	updated := feedUpdated(meta.Entries)
+	etag := feedETag(format, meta.SelfPath, meta.Entries)
+
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderLastModified, updated.Format(http.TimeFormat))
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	if notModified(c, etag, updated) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	// Chỉ render Markdown khi thật sự cần gửi nội dung
+	render(meta.Entries)
+	base := siteBaseURL(c)
+	for i := range meta.Entries {
+		meta.Entries[i].Content = absolutizeLinks(meta.Entries[i].Content, base)
+		meta.Entries[i].ID = feedEntryID(base, meta.Entries[i].ID)
+	}
+
+	var doc interface{}
+	contentType := "application/atom+xml; charset=utf-8"
+	if format == feedFormatRSS {
+		doc = buildRSS(meta, base, updated)
+		contentType = "application/rss+xml; charset=utf-8"
+	} else {
+		doc = buildAtom(meta, base, updated)
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Không thể tạo feed")
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(append([]byte(xml.Header), out...))
+}
+
+// feedEntryID sinh tag URI ổn định, không đổi khi slug đổi.
+func feedEntryID(base, key string) string {
+	host := base
+	if u, err := url.Parse(base); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	return "tag:" + host + ",2024:" + key
+}
+
+func postEntries(posts []models.Post) []feedEntry {
+	entries := make([]feedEntry, 0, len(posts))
+	for _, p := range posts {
+		published := p.CreatedAt
+		if p.PublishAt != nil {
+			published = *p.PublishAt
+		}
+		updated := p.UpdatedAt
+		if published.After(updated) {
+			updated = published
+		}
+		entries = append(entries, feedEntry{
+			ID:        "post/" + strconv.FormatUint(uint64(p.ID), 10),
+			Title:     p.Title,
+			URL:       postPath(p),
+			Author:    p.Author.Name,
+			Summary:   p.Summary,
+			Content:   p.Content,
+			Tags:      splitTagInput(p.Tags),
+			Published: published,
+			Updated:   updated,
+		})
+	}
+	return entries
+}
+
+func renderEntryMarkdown(entries []feedEntry) {
+	for i := range entries {
+		entries[i].Content = string(renderMarkdown(entries[i].Content))
+	}
+}
+
+// latestPosts lấy các bài viết đã xuất bản mới nhất cho feed.
+func latestPosts(db *gorm.DB, scope func(*gorm.DB) *gorm.DB) ([]models.Post, error) {
+	query := publishedPosts(db).Preload("Author").Order(postOrder).Limit(feedLimit)
+	if scope != nil {
+		query = scope(query)
+	}
+	var posts []models.Post
+	err := query.Find(&posts).Error
+	return posts, err
+}
+
+func feedSuffix(format string) string {
+	if format == feedFormatRSS {
+		return "/rss.xml"
+	}
+	return "/feed.xml"
+}
+
+// PostsFeed là feed các bài viết mới nhất (/feed.xml cho Atom, /rss.xml cho RSS).
+func PostsFeed(format string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		posts, err := latestPosts(database.Get(), nil)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Không thể tải bài viết")
+		}
+		return writeFeed(c, format, feedMeta{
+			Title:       "Cộng đồng Học DevOps · Bài viết mới",
+			Description: "Bài viết mới nhất từ cộng đồng",
+			Path:        "/posts",
+			SelfPath:    feedSuffix(format),
+			Entries:     postEntries(posts),
+		}, renderEntryMarkdown)
+	}
+}
+
+// TagFeed là feed bài viết theo tag (/tags/:name/feed.xml, /tags/:name/rss.xml).
+func TagFeed(format string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		raw, _ := url.PathUnescape(c.Params("name"))
+		db := database.Get()
+
+		tag, err := lookupTag(db, raw)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString("Tag không tồn tại")
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("Không thể tải tag")
+		}
+		if raw != tag.Name {
+			return c.Redirect(tagPath(tag.Name)+feedSuffix(format), fiber.StatusMovedPermanently)
+		}
+
+		posts, err := latestPosts(db, func(q *gorm.DB) *gorm.DB { return postsWithTag(q, tag.ID) })
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Không thể tải bài viết")
+		}
+		return writeFeed(c, format, feedMeta{
+			Title:       "#" + tag.Name + " · Cộng đồng Học DevOps",
+			Description: "Bài viết mới nhất gắn tag #" + tag.Name,
+			Path:        tagPath(tag.Name),
+			SelfPath:    tagPath(tag.Name) + feedSuffix(format),
+			Entries:     postEntries(posts),
+		}, renderEntryMarkdown)
+	}
+}
+
+// AuthorFeed là feed bài viết của một tác giả (/authors/:id/feed.xml, /authors/:id/rss.xml).
+func AuthorFeed(format string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authorID, err := strconv.Atoi(c.Params("id"))
+		if err != nil || authorID <= 0 {
+			return c.Status(fiber.StatusBadRequest).SendString("ID tác giả không hợp lệ")
+		}
+
+		db := database.Get()
+		var author models.User
+		if err := db.First(&author, authorID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString("Tác giả không tồn tại")
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("Không thể tải tác giả")
+		}
+
+		posts, err := latestPosts(db, func(q *gorm.DB) *gorm.DB { return q.Where("posts.author_id = ?", author.ID) })
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Không thể tải bài viết")
+		}
+		authorPath := fmt.Sprintf("/authors/%d", author.ID)
+		return writeFeed(c, format, feedMeta{
+			Title:       author.Name + " · Cộng đồng Học DevOps",
+			Description: "Bài viết mới nhất của " + author.Name,
+			Path:        "/posts",
+			SelfPath:    authorPath + feedSuffix(format),
+			Entries:     postEntries(posts),
+		}, renderEntryMarkdown)
+	}
+}
+
+// BookFeed là feed các trang sách mới được thêm hoặc sửa (/books/:slug/feed.xml, /books/:slug/rss.xml).
+func BookFeed(format string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+		bookID, canonical, err := resolveSlugParam(db, models.SlugResourceBook, c.Params("slug"))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString("Sách không tồn tại")
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("Không thể tải sách")
+		}
+
+		var book models.Book
+		if err := policy.VisibleBooks(db, nil).Preload("Author").First(&book, bookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString("Sách không tồn tại")
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("Không thể tải sách")
+		}
+		if !canonical && book.Slug != "" {
+			return c.Redirect(bookPath(book)+feedSuffix(format), fiber.StatusMovedPermanently)
+		}
+
+		var pages []models.BookPage
+		if err := db.Where("book_id = ?", book.ID).Order("updated_at DESC").Limit(feedLimit).Find(&pages).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Không thể tải trang sách")
+		}
+
+		entries := make([]feedEntry, 0, len(pages))
+		for _, p := range pages {
+			title := p.Title
+			if title == "" {
+				title = fmt.Sprintf("Trang %d", p.PageNumber)
+			}
+			entries = append(entries, feedEntry{
+				ID:        "book-page/" + strconv.FormatUint(uint64(p.ID), 10),
+				Title:     book.Title + " · " + title,
+				URL:       bookPath(book) + "/read?page=" + strconv.FormatUint(uint64(p.ID), 10),
+				Author:    book.Author.Name,
+				Content:   p.Content,
+				Tags:      splitTagInput(book.BookTag),
+				Published: p.CreatedAt,
+				Updated:   p.UpdatedAt,
+			})
+		}
+
+		return writeFeed(c, format, feedMeta{
+			Title:       book.Title + " · Cộng đồng Học DevOps",
+			Description: book.Description,
+			Path:        bookPath(book),
+			SelfPath:    bookPath(book) + feedSuffix(format),
+			Entries:     entries,
+		}, renderEntryMarkdown)
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+)
+
+// parseHiddenFlag đọc trạng thái ẩn từ JSON {"hidden": true} hoặc form hidden=1.
+func parseHiddenFlag(c *fiber.Ctx) (bool, error) {
+	if isJSONRequest(c) {
+		var body struct {
+			Hidden *bool `json:"hidden"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Hidden == nil {
+			return false, errors.New("payload không hợp lệ")
+		}
+		return *body.Hidden, nil
+	}
+	return strconv.ParseBool(c.FormValue("hidden"))
+}
+
+func visibilityMessage(hidden bool) string {
+	if hidden {
+		return "Đã ẩn nội dung khỏi trang công khai"
+	}
+	return "Đã hiển thị lại nội dung"
+}
+
+// SetPostVisibility cho ban quản trị ẩn hoặc hiện lại một bài viết.
+func SetPostVisibility() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login")
+		}
+		postID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "ID bài viết không hợp lệ", "/posts")
+		}
+
+		db := database.Get()
+		var post models.Post
+		if err := db.First(&post, postID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return respondError(c, fiber.StatusNotFound, "Bài viết không tồn tại", "/posts")
+			}
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/posts")
+		}
+		if !can(user, policy.Hide, post) {
+			return respondError(c, fiber.StatusForbidden, "Bạn không có quyền ẩn bài viết", postPath(post))
+		}
+		hidden, err := parseHiddenFlag(c)
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "Trạng thái ẩn không hợp lệ", postPath(post))
+		}
+
+		if err := db.Model(&post).UpdateColumn("hidden", hidden).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể cập nhật bài viết", postPath(post))
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "hidden": hidden})
+		}
+		setFlash(c, "success", visibilityMessage(hidden))
+		return c.Status(fiber.StatusSeeOther).Redirect(localRedirect(c.FormValue("next"), postPath(post)))
+	}
+}
+
+// SetCommentVisibility cho ban quản trị ẩn hoặc hiện lại một bình luận.
+func SetCommentVisibility() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login")
+		}
+		comment, err := loadComment(c, user, policy.Hide)
+		if comment == nil {
+			return err
+		}
+		hidden, err := parseHiddenFlag(c)
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "Trạng thái ẩn không hợp lệ", commentAnchor(comment.PostID, comment.ID))
+		}
+
+		if err := database.Get().Model(comment).UpdateColumn("hidden", hidden).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể cập nhật bình luận", commentAnchor(comment.PostID, comment.ID))
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "hidden": hidden})
+		}
+		setFlash(c, "success", visibilityMessage(hidden))
+		return c.Status(fiber.StatusSeeOther).Redirect(localRedirect(c.FormValue("next"), commentAnchor(comment.PostID, comment.ID)))
+	}
+}
+
+// SetBookVisibility cho ban quản trị ẩn hoặc hiện lại một cuốn sách.
+func SetBookVisibility() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return c.Status(401).JSON(fiber.Map{"error": "Chưa đăng nhập"})
+		}
+		bookID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "ID không hợp lệ"})
+		}
+
+		db := database.Get()
+		var book models.Book
+		if err := db.First(&book, bookID).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Không tìm thấy sách"})
+		}
+		if !can(user, policy.Hide, book) {
+			return c.Status(403).JSON(fiber.Map{"error": "Không có quyền"})
+		}
+		hidden, err := parseHiddenFlag(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Trạng thái ẩn không hợp lệ"})
+		}
+
+		if err := db.Model(&book).UpdateColumn("hidden", hidden).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Lỗi cập nhật sách"})
+		}
+		return c.JSON(fiber.Map{"success": true, "hidden": hidden})
+	}
+}
+
+// DeleteImage xóa ảnh đã upload (người upload hoặc ban quản trị).
+func DeleteImage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Bạn cần đăng nhập"})
+		}
+		imageID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ảnh không hợp lệ"})
+		}
+
+		db := database.Get()
+		var image models.Image
+		if err := db.Select("id", "uploader_id").First(&image, imageID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Không tìm thấy ảnh"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Lỗi truy vấn database"})
+		}
+		if !can(user, policy.Delete, image) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Bạn không có quyền xóa ảnh này"})
+		}
+
+		if err := db.Delete(&image).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Không thể xóa ảnh"})
+		}
+		return c.JSON(fiber.Map{"success": true, "message": "Đã xóa ảnh"})
+	}
+}
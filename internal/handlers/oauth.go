@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/oauth"
+)
+
+// oauthFlowTTL là thời gian tối đa từ lúc chuyển sang provider tới lúc callback.
+const oauthFlowTTL = 10 * time.Minute
+
+const (
+	oauthIntentLogin = "login"
+	oauthIntentLink  = "link"
+)
+
+var (
+	oauthProviders     = map[string]*oauth.Provider{}
+	oauthProviderOrder []*oauth.Provider
+)
+
+// SetOAuthProviders đăng ký các provider đăng nhập ngoài đã cấu hình.
+func SetOAuthProviders(providers []*oauth.Provider) {
+	oauthProviders = make(map[string]*oauth.Provider, len(providers))
+	oauthProviderOrder = providers
+	for _, p := range providers {
+		oauthProviders[p.Name] = p
+	}
+}
+
+// oauthProviderLinks trả danh sách provider cho nút "Đăng nhập với ..." trong giao diện.
+func oauthProviderLinks() []fiber.Map {
+	links := make([]fiber.Map, 0, len(oauthProviderOrder))
+	for _, p := range oauthProviderOrder {
+		links = append(links, fiber.Map{"Name": p.Name, "DisplayName": p.DisplayName})
+	}
+	return links
+}
+
+func oauthRedirectURI(c *fiber.Ctx, provider string) string {
+	return siteBaseURL(c) + "/auth/oauth/" + provider + "/callback"
+}
+
+// localRedirect chỉ chấp nhận đường dẫn nội bộ để tránh open redirect sau đăng nhập.
+func localRedirect(next, fallback string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return fallback
+	}
+	if next == "/auth/login" || next == "/auth/register" {
+		return fallback
+	}
+	return next
+}
+
+// startOAuth lưu state, nonce và PKCE verifier vào phiên rồi chuyển người dùng sang provider.
+func startOAuth(c *fiber.Ctx, provider *oauth.Provider, intent string, userID uint, next, failRedirect string) error {
+	sess, err := sessionStore.Get(c)
+	if err != nil {
+		return respondError(c, fiber.StatusInternalServerError, "Không thể khởi tạo phiên", failRedirect)
+	}
+	state, nonce, verifier := oauth.NewVerifier(), oauth.NewVerifier(), oauth.NewVerifier()
+	authURL, err := provider.AuthCodeURL(c.UserContext(), oauthRedirectURI(c, provider.Name), state, nonce, verifier)
+	if err != nil {
+		log.Printf("oauth %s: %v", provider.Name, err)
+		return respondError(c, fiber.StatusBadGateway, fmt.Sprintf("Không kết nối được %s, vui lòng thử lại sau", provider.DisplayName), failRedirect)
+	}
+
+	sess.Set("oauthState", state)
+	sess.Set("oauthNonce", nonce)
+	sess.Set("oauthVerifier", verifier)
+	sess.Set("oauthProvider", provider.Name)
+	sess.Set("oauthIntent", intent)
+	sess.Set("oauthUserID", strconv.FormatUint(uint64(userID), 10))
+	sess.Set("oauthNext", next)
+	sess.Set("oauthStarted", time.Now().Unix())
+	if err := sess.Save(); err != nil {
+		return respondError(c, fiber.StatusInternalServerError, "Không thể lưu phiên", failRedirect)
+	}
+	return c.Redirect(authURL)
+}
+
+// OAuthLogin bắt đầu đăng nhập bằng provider bên ngoài.
+func OAuthLogin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provider, ok := oauthProviders[c.Params("provider")]
+		if !ok {
+			return respondError(c, fiber.StatusNotFound, "Phương thức đăng nhập không tồn tại", "/auth/login")
+		}
+		return startOAuth(c, provider, oauthIntentLogin, 0, localRedirect(c.Query("next"), "/"), "/auth/login")
+	}
+}
+
+// LinkIdentity bắt đầu liên kết tài khoản hiện tại với một provider.
+func LinkIdentity() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login?next=/account")
+		}
+		provider, ok := oauthProviders[c.Params("provider")]
+		if !ok {
+			return respondError(c, fiber.StatusNotFound, "Phương thức đăng nhập không tồn tại", "/account")
+		}
+		return startOAuth(c, provider, oauthIntentLink, userID, "/account", "/account")
+	}
+}
+
+type oauthFlow struct {
+	state, nonce, verifier, provider, intent, next string
+	userID                                         uint
+	started                                        time.Time
+}
+
+// popOAuthFlow đọc rồi xóa dữ liệu luồng OAuth khỏi phiên để state chỉ dùng được một lần.
+func popOAuthFlow(c *fiber.Ctx) (oauthFlow, error) {
+	var flow oauthFlow
+	sess, err := sessionStore.Get(c)
+	if err != nil {
+		return flow, err
+	}
+	flow.state, _ = sess.Get("oauthState").(string)
+	flow.nonce, _ = sess.Get("oauthNonce").(string)
+	flow.verifier, _ = sess.Get("oauthVerifier").(string)
+	flow.provider, _ = sess.Get("oauthProvider").(string)
+	flow.intent, _ = sess.Get("oauthIntent").(string)
+	flow.next, _ = sess.Get("oauthNext").(string)
+	userID, _ := sess.Get("oauthUserID").(string)
+	if id, err := strconv.ParseUint(userID, 10, 64); err == nil {
+		flow.userID = uint(id)
+	}
+	started, _ := sess.Get("oauthStarted").(int64)
+	flow.started = time.Unix(started, 0)
+
+	for _, key := range []string{"oauthState", "oauthNonce", "oauthVerifier", "oauthProvider", "oauthIntent", "oauthUserID", "oauthNext", "oauthStarted"} {
+		sess.Delete(key)
+	}
+	return flow, sess.Save()
+}
+
+// OAuthCallback hoàn tất đăng nhập hoặc liên kết sau khi provider chuyển người dùng về.
+func OAuthCallback() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		flow, err := popOAuthFlow(c)
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể đọc phiên", "/auth/login")
+		}
+		failRedirect := "/auth/login"
+		if flow.intent == oauthIntentLink {
+			failRedirect = "/account"
+		}
+
+		provider, ok := oauthProviders[c.Params("provider")]
+		if !ok || flow.provider != provider.Name || flow.state == "" ||
+			subtle.ConstantTimeCompare([]byte(flow.state), []byte(c.Query("state"))) != 1 {
+			return respondError(c, fiber.StatusBadRequest, "Phiên đăng nhập không hợp lệ, vui lòng thử lại", failRedirect)
+		}
+		if time.Since(flow.started) > oauthFlowTTL {
+			return respondError(c, fiber.StatusBadRequest, "Phiên đăng nhập đã hết hạn, vui lòng thử lại", failRedirect)
+		}
+		if errCode := c.Query("error"); errCode != "" {
+			log.Printf("oauth %s: provider returned error %s: %s", provider.Name, errCode, c.Query("error_description"))
+			return respondError(c, fiber.StatusUnauthorized, fmt.Sprintf("%s từ chối đăng nhập", provider.DisplayName), failRedirect)
+		}
+
+		ctx := c.UserContext()
+		token, err := provider.Exchange(ctx, c.Query("code"), oauthRedirectURI(c, provider.Name), flow.verifier)
+		if err != nil {
+			log.Printf("oauth %s: %v", provider.Name, err)
+			return respondError(c, fiber.StatusBadGateway, fmt.Sprintf("Không thể đăng nhập với %s", provider.DisplayName), failRedirect)
+		}
+		identity, err := provider.Identity(ctx, token, flow.nonce)
+		if err != nil {
+			log.Printf("oauth %s: %v", provider.Name, err)
+			return respondError(c, fiber.StatusUnauthorized, fmt.Sprintf("Không xác thực được tài khoản %s", provider.DisplayName), failRedirect)
+		}
+
+		if flow.intent == oauthIntentLink {
+			return linkIdentity(c, provider, identity, flow.userID)
+		}
+		return loginWithIdentity(c, provider, identity, localRedirect(flow.next, "/"))
+	}
+}
+
+func linkIdentity(c *fiber.Ctx, provider *oauth.Provider, identity *oauth.Identity, flowUserID uint) error {
+	userID, err := currentUserID(c)
+	if err != nil || userID != flowUserID {
+		return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để liên kết tài khoản", "/auth/login?next=/account")
+	}
+
+	db := database.Get()
+	var existing models.UserIdentity
+	err = db.Where("provider = ? AND subject = ?", provider.Name, identity.Subject).First(&existing).Error
+	switch {
+	case err == nil && existing.UserID == userID:
+		setFlash(c, "success", fmt.Sprintf("Tài khoản %s đã được liên kết từ trước", provider.DisplayName))
+		return c.Status(fiber.StatusSeeOther).Redirect("/account")
+	case err == nil:
+		return respondError(c, fiber.StatusConflict, fmt.Sprintf("Tài khoản %s này đã liên kết với người dùng khác", provider.DisplayName), "/account")
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return respondError(c, fiber.StatusInternalServerError, "Không thể liên kết tài khoản", "/account")
+	}
+
+	var count int64
+	db.Model(&models.UserIdentity{}).Where("user_id = ? AND provider = ?", userID, provider.Name).Count(&count)
+	if count > 0 {
+		return respondError(c, fiber.StatusConflict, fmt.Sprintf("Bạn đã liên kết một tài khoản %s khác, hãy hủy liên kết trước", provider.DisplayName), "/account")
+	}
+
+	entry := models.UserIdentity{UserID: userID, Provider: provider.Name, Subject: identity.Subject, Email: identity.Email}
+	if err := db.Create(&entry).Error; err != nil {
+		return respondError(c, fiber.StatusInternalServerError, "Không thể liên kết tài khoản", "/account")
+	}
+	setFlash(c, "success", fmt.Sprintf("Đã liên kết tài khoản %s", provider.DisplayName))
+	return c.Status(fiber.StatusSeeOther).Redirect("/account")
+}
+
+// loginWithIdentity đăng nhập bằng danh tính đã liên kết, hoặc tạo tài khoản mới cho danh tính lần đầu gặp.
+// Không tự gắn vào tài khoản có sẵn cùng email: chủ tài khoản phải đăng nhập rồi liên kết từ trang cá nhân.
+func loginWithIdentity(c *fiber.Ctx, provider *oauth.Provider, identity *oauth.Identity, redirect string) error {
+	db := database.Get()
+	var user models.User
+
+	var existing models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", provider.Name, identity.Subject).First(&existing).Error
+	switch {
+	case err == nil:
+		if err := db.First(&user, existing.UserID).Error; err != nil {
+			return respondError(c, fiber.StatusUnauthorized, "Tài khoản liên kết không còn tồn tại", "/auth/login")
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		created, err := createOAuthUser(c, db, provider, identity)
+		if err != nil || created == nil {
+			return err
+		}
+		user = *created
+	default:
+		return respondError(c, fiber.StatusInternalServerError, "Không thể đăng nhập", "/auth/login")
+	}
+
+	if user.Suspended() {
+		return respondError(c, fiber.StatusForbidden, suspendedAccountMessage, "/auth/login")
+	}
+	if user.TwoFactorEnabled() {
+		return beginTwoFactorLogin(c, user, redirect)
+	}
+	if err := setUserSession(c, user); err != nil {
+		return respondError(c, fiber.StatusInternalServerError, "Không thể đăng nhập", "/auth/login")
+	}
+	setFlash(c, "success", fmt.Sprintf("Chào mừng, %s!", user.Name))
+	return c.Status(fiber.StatusSeeOther).Redirect(redirect)
+}
+
+func createOAuthUser(c *fiber.Ctx, db *gorm.DB, provider *oauth.Provider, identity *oauth.Identity) (*models.User, error) {
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+	if email == "" {
+		return nil, respondError(c, fiber.StatusBadRequest, fmt.Sprintf("%s không chia sẻ email, không thể tạo tài khoản", provider.DisplayName), "/auth/login")
+	}
+
+	var count int64
+	if err := db.Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return nil, respondError(c, fiber.StatusInternalServerError, "Không thể kiểm tra tài khoản", "/auth/login")
+	}
+	if count > 0 {
+		return nil, respondError(c, fiber.StatusConflict,
+			fmt.Sprintf("Email %s đã có tài khoản. Hãy đăng nhập bằng mật khẩu rồi liên kết %s trong trang tài khoản.", email, provider.DisplayName),
+			"/auth/login")
+	}
+
+	name := strings.TrimSpace(identity.Name)
+	if len(name) < 3 {
+		name = strings.SplitN(email, "@", 2)[0]
+	}
+	user := models.User{Name: truncate(name, 120), Email: email}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := assignUserHandle(db, &user); err != nil {
+		return nil, respondError(c, fiber.StatusInternalServerError, "Không thể tạo tài khoản", "/auth/login")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider.Name,
+			Subject:  identity.Subject,
+			Email:    email,
+		}).Error
+	})
+	if err != nil {
+		return nil, respondError(c, fiber.StatusInternalServerError, "Không thể tạo tài khoản", "/auth/login")
+	}
+
+	if !user.EmailVerified() {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("failed to send verification email: %v", err)
+		}
+	}
+	return &user, nil
+}
+
+// AccountPage là trang tài khoản: danh tính đã liên kết, provider có thể liên kết và lối tắt quản lý phiên.
+func AccountPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return c.Redirect("/auth/login?next=/account")
+		}
+		db := database.Get()
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return respondError(c, fiber.StatusNotFound, "Tài khoản không tồn tại", "/")
+		}
+		var identities []models.UserIdentity
+		if err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải tài khoản liên kết", "/")
+		}
+
+		hasPassword := user.PasswordHash != ""
+		linked := make(map[string]bool, len(identities))
+		items := make([]fiber.Map, 0, len(identities))
+		for _, identity := range identities {
+			linked[identity.Provider] = true
+			displayName := identity.Provider
+			if p, ok := oauthProviders[identity.Provider]; ok {
+				displayName = p.DisplayName
+			}
+			items = append(items, fiber.Map{
+				"ID":          identity.ID,
+				"Provider":    identity.Provider,
+				"DisplayName": displayName,
+				"Email":       identity.Email,
+				"LinkedLabel": formatTimeVN(identity.CreatedAt),
+				"CanUnlink":   hasPassword || len(identities) > 1,
+			})
+		}
+		available := make([]fiber.Map, 0)
+		for _, p := range oauthProviderOrder {
+			if !linked[p.Name] {
+				available = append(available, fiber.Map{"Name": p.Name, "DisplayName": p.DisplayName})
+			}
+		}
+
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{
+				"user":         fiber.Map{"id": user.ID, "name": user.Name, "email": user.Email, "email_verified": user.EmailVerified()},
+				"has_password": hasPassword,
+				"two_factor":   user.TwoFactorEnabled(),
+				"identities":   items,
+				"available":    available,
+			})
+		}
+		return render(c, "pages/account", fiber.Map{
+			"Title":       "Tài khoản",
+			"User":        user,
+			"HasPassword": hasPassword,
+			"Identities":  items,
+			"Available":   available,
+		}, "main")
+	}
+}
+
+// UnlinkIdentity hủy liên kết một danh tính; không cho hủy phương thức đăng nhập cuối cùng.
+func UnlinkIdentity() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login?next=/account")
+		}
+		identityID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "ID liên kết không hợp lệ", "/account")
+		}
+
+		db := database.Get()
+		var identity models.UserIdentity
+		if err := db.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+			return respondError(c, fiber.StatusNotFound, "Liên kết không tồn tại", "/account")
+		}
+		var user models.User
+		if err := db.Select("id", "password_hash").First(&user, userID).Error; err != nil {
+			return respondError(c, fiber.StatusNotFound, "Tài khoản không tồn tại", "/account")
+		}
+		var count int64
+		if err := db.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể hủy liên kết", "/account")
+		}
+		if user.PasswordHash == "" && count <= 1 {
+			return respondError(c, fiber.StatusConflict, "Đây là cách đăng nhập duy nhất của bạn. Hãy đặt mật khẩu (qua Quên mật khẩu) trước khi hủy liên kết.", "/account")
+		}
+		if err := db.Delete(&identity).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể hủy liên kết", "/account")
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true})
+		}
+		setFlash(c, "success", "Đã hủy liên kết tài khoản")
+		return c.Status(fiber.StatusSeeOther).Redirect("/account")
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errPageOrder là lỗi dữ liệu do client gửi lên, trả 400 thay vì 500.
+type errPageOrder string
+
+func (e errPageOrder) Error() string { return string(e) }
+
+// lockBookPages khóa dòng sách trong transaction để các thao tác thêm/xóa/sắp xếp trang
+// của cùng một sách chạy tuần tự, rồi trả về ID các trang theo thứ tự hiện tại.
+func lockBookPages(tx *gorm.DB, bookID uint) ([]uint, error) {
+	var book models.Book
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&book, bookID).Error; err != nil {
+		return nil, err
+	}
+	var ids []uint
+	err := tx.Model(&models.BookPage{}).Where("book_id = ?", bookID).
+		Order("page_number ASC, id ASC").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// renumberBookPages gán page_number 1..n theo thứ tự ids. Unique index (book_id, page_number)
+// được kiểm tra theo từng dòng nên trước hết đổi dấu toàn bộ số trang để tránh trùng tạm thời.
+func renumberBookPages(tx *gorm.DB, bookID uint, current, ids []uint) error {
+	changed := len(current) != len(ids)
+	for i := range ids {
+		if !changed && current[i] != ids[i] {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := tx.Model(&models.BookPage{}).Where("book_id = ?", bookID).
+		Update("page_number", gorm.Expr("-page_number")).Error; err != nil {
+		return err
+	}
+	for i, id := range ids {
+		if err := tx.Model(&models.BookPage{}).Where("id = ?", id).Update("page_number", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// movePage trả về thứ tự mới sau khi đặt pageID ngay trước hoặc ngay sau targetID.
+func movePage(ids []uint, pageID, targetID uint, after bool) ([]uint, error) {
+	if pageID == targetID {
+		return nil, errPageOrder("Trang đích phải khác trang cần di chuyển")
+	}
+	found := false
+	rest := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id == pageID {
+			found = true
+			continue
+		}
+		rest = append(rest, id)
+	}
+	if !found {
+		return nil, errPageOrder("Không tìm thấy trang cần di chuyển")
+	}
+	for i, id := range rest {
+		if id != targetID {
+			continue
+		}
+		if after {
+			i++
+		}
+		order := append([]uint{}, rest[:i]...)
+		order = append(order, pageID)
+		return append(order, rest[i:]...), nil
+	}
+	return nil, errPageOrder("Không tìm thấy trang đích")
+}
+
+// checkFullOrder đảm bảo thứ tự client gửi chứa đúng mỗi trang của sách một lần.
+func checkFullOrder(ids, order []uint) error {
+	if len(order) != len(ids) {
+		return errPageOrder("Thứ tự phải liệt kê đủ tất cả các trang của sách")
+	}
+	remaining := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		remaining[id] = true
+	}
+	for _, id := range order {
+		if !remaining[id] {
+			return errPageOrder("Thứ tự chứa trang không thuộc sách hoặc bị lặp")
+		}
+		delete(remaining, id)
+	}
+	return nil
+}
+
+// ReorderBookPages sắp xếp lại trang của sách trong một transaction. Nhận một trong hai dạng:
+// {"order": [id...]} liệt kê toàn bộ trang, hoặc {"page_id": id, "before"|"after": id} để di chuyển một trang.
+func ReorderBookPages() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		book, err := loadEditableBook(c)
+		if book == nil {
+			return err
+		}
+		redirect := bookPath(*book) + "#toc"
+
+		var body struct {
+			Order  []uint `json:"order"`
+			PageID uint   `json:"page_id"`
+			Before uint   `json:"before"`
+			After  uint   `json:"after"`
+		}
+		if isJSONRequest(c) {
+			if err := c.BodyParser(&body); err != nil {
+				return respondError(c, fiber.StatusBadRequest, "Dữ liệu không hợp lệ", redirect)
+			}
+		} else {
+			for field, target := range map[string]*uint{"page_id": &body.PageID, "before": &body.Before, "after": &body.After} {
+				value := strings.TrimSpace(c.FormValue(field))
+				if value == "" {
+					continue
+				}
+				id, err := strconv.ParseUint(value, 10, 64)
+				if err != nil {
+					return respondError(c, fiber.StatusBadRequest, "Dữ liệu không hợp lệ", redirect)
+				}
+				*target = uint(id)
+			}
+		}
+		if body.Order == nil && (body.PageID == 0 || (body.Before == 0) == (body.After == 0)) {
+			return respondError(c, fiber.StatusBadRequest, "Cần gửi order hoặc page_id kèm đúng một trong before/after", redirect)
+		}
+
+		var order []uint
+		err = database.Get().Transaction(func(tx *gorm.DB) error {
+			ids, err := lockBookPages(tx, book.ID)
+			if err != nil {
+				return err
+			}
+			if body.Order != nil {
+				if err := checkFullOrder(ids, body.Order); err != nil {
+					return err
+				}
+				order = body.Order
+			} else if body.Before != 0 {
+				order, err = movePage(ids, body.PageID, body.Before, false)
+			} else {
+				order, err = movePage(ids, body.PageID, body.After, true)
+			}
+			if err != nil {
+				return err
+			}
+			return renumberBookPages(tx, book.ID, ids, order)
+		})
+		var orderErr errPageOrder
+		if errors.As(err, &orderErr) {
+			return respondError(c, fiber.StatusBadRequest, orderErr.Error(), redirect)
+		}
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi sắp xếp trang", redirect)
+		}
+		invalidateBookExports(book.ID)
+
+		pages := make([]fiber.Map, len(order))
+		for i, id := range order {
+			pages[i] = fiber.Map{"id": id, "page_number": i + 1}
+		}
+		return bookEditDone(c, book, "Đã sắp xếp lại trang", fiber.Map{"pages": pages})
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/models"
+)
+
+var errInvalidPublishAt = errors.New("thời gian xuất bản không hợp lệ")
+
+// parsePostStatus chuẩn hóa trạng thái và thời gian xuất bản gửi lên từ form/JSON.
+// publishAt chấp nhận định dạng datetime-local (giờ Việt Nam) hoặc RFC3339.
+func parsePostStatus(status, publishAt string) (string, *time.Time, error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	publishAt = strings.TrimSpace(publishAt)
+
+	var when *time.Time
+	if publishAt != "" {
+		t, err := time.Parse(time.RFC3339, publishAt)
+		if err != nil {
+			t, err = time.ParseInLocation("2006-01-02T15:04", publishAt, vietnamLocation)
+		}
+		if err != nil {
+			return "", nil, errInvalidPublishAt
+		}
+		when = &t
+	}
+
+	switch status {
+	case "", models.PostStatusPublished:
+		now := time.Now()
+		return models.PostStatusPublished, &now, nil
+	case models.PostStatusDraft:
+		return models.PostStatusDraft, when, nil
+	case models.PostStatusScheduled:
+		if when == nil {
+			return "", nil, errInvalidPublishAt
+		}
+		if !when.After(time.Now()) {
+			return models.PostStatusPublished, when, nil
+		}
+		return models.PostStatusScheduled, when, nil
+	}
+	return "", nil, errors.New("trạng thái bài viết không hợp lệ")
+}
+
+// publishedPosts giới hạn truy vấn ở các bài viết đã xuất bản và không bị ẩn.
+func publishedPosts(db *gorm.DB) *gorm.DB {
+	return db.Where("posts.status = ? AND posts.hidden = ?", models.PostStatusPublished, false)
+}
+
+func postStatusLabel(status string) string {
+	switch status {
+	case models.PostStatusDraft:
+		return "Bản nháp"
+	case models.PostStatusScheduled:
+		return "Đã lên lịch"
+	}
+	return "Đã xuất bản"
+}
+
+// publishAtInput định dạng thời gian cho ô datetime-local trong form chỉnh sửa.
+func publishAtInput(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(vietnamLocation).Format("2006-01-02T15:04")
+}
+
+// postOrder sắp xếp bài viết theo thời điểm xuất bản, bài cũ chưa có publish_at dùng created_at.
+const postOrder = "COALESCE(posts.publish_at, posts.created_at) DESC"
@@ -0,0 +1,632 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+	"fiber-learning-community/internal/slug"
+)
+
+const (
+	handleMaxLength  = 30
+	profileListLimit = 10
+	maxBioLength     = 1000
+	maxProfileLinks  = 5
+	// heatmapWeeks là số cột tuần của biểu đồ hoạt động (khoảng một năm).
+	heatmapWeeks     = 53
+	contributorLimit = 24
+)
+
+// handlePattern: 3-30 ký tự chữ thường, số hoặc gạch ngang, không bắt đầu/kết thúc bằng gạch ngang.
+var handlePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,28}[a-z0-9]$`)
+
+// profilePath trả đường dẫn hồ sơ công khai; tài khoản chưa có handle đi qua /authors/:id.
+func profilePath(user models.User) string {
+	if user.Handle == "" {
+		return fmt.Sprintf("/authors/%d", user.ID)
+	}
+	return "/u/" + user.Handle
+}
+
+func handleTaken(db *gorm.DB, candidate string, userID uint) (bool, error) {
+	var count int64
+	err := db.Unscoped().Model(&models.User{}).Where("handle = ? AND id <> ?", candidate, userID).Count(&count).Error
+	return count > 0, err
+}
+
+// uniqueHandle sinh handle từ tên (hoặc phần trước @ của email) và thêm hậu tố -2, -3... nếu trùng.
+func uniqueHandle(db *gorm.DB, name, email string, userID uint) (string, error) {
+	base := slug.Make(name)
+	if len(base) < 3 {
+		base = slug.Make(strings.SplitN(email, "@", 2)[0])
+	}
+	if len(base) < 3 {
+		base = "thanh-vien"
+	}
+
+	for n := 1; ; n++ {
+		suffix := ""
+		if n > 1 {
+			suffix = "-" + strconv.Itoa(n)
+		}
+		trimmed := base
+		if len(trimmed)+len(suffix) > handleMaxLength {
+			trimmed = strings.Trim(trimmed[:handleMaxLength-len(suffix)], "-")
+		}
+		candidate := trimmed + suffix
+		taken, err := handleTaken(db, candidate, userID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+}
+
+// assignUserHandle gán handle cho tài khoản mới trước khi lưu.
+func assignUserHandle(db *gorm.DB, user *models.User) error {
+	handle, err := uniqueHandle(db, user.Name, user.Email, user.ID)
+	if err != nil {
+		return err
+	}
+	user.Handle = handle
+	return nil
+}
+
+// BackfillHandles sinh handle cho các tài khoản cũ chưa có.
+func BackfillHandles(db *gorm.DB) error {
+	var users []models.User
+	if err := db.Unscoped().Where("handle IS NULL OR handle = ''").Find(&users).Error; err != nil {
+		return err
+	}
+	for i := range users {
+		if err := assignUserHandle(db, &users[i]); err != nil {
+			return err
+		}
+		if err := db.Unscoped().Model(&users[i]).UpdateColumn("handle", users[i].Handle).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func userInitial(name string) string {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(name))
+	if r == utf8.RuneError {
+		return "?"
+	}
+	return strings.ToUpper(string(r))
+}
+
+// publicComments là các bình luận hiển thị công khai: chưa xóa, không bị ẩn, trên bài viết đã xuất bản.
+func publicComments(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Comment{}).
+		Joins("JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL").
+		Where("comments.removed = ? AND comments.hidden = ? AND posts.status = ? AND posts.hidden = ?",
+			false, false, models.PostStatusPublished, false)
+}
+
+// publicHighlights là highlight tác giả tạo trên sách của chính mình; trang đọc hiển thị chúng cho mọi người.
+func publicHighlights(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Highlight{}).
+		Joins("JOIN book_pages ON book_pages.id = highlights.book_page_id AND book_pages.deleted_at IS NULL").
+		Joins("JOIN books ON books.id = book_pages.book_id AND books.deleted_at IS NULL").
+		Where("books.author_id = highlights.user_id AND books.published = ? AND books.hidden = ?", true, false)
+}
+
+func publicBooks(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Book{}).Where("books.published = ? AND books.hidden = ?", true, false)
+}
+
+// dailyCounts đếm số bản ghi theo ngày (giờ Việt Nam) của cột thời gian column, kể từ since.
+func dailyCounts(query *gorm.DB, column string, since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Day   time.Time
+		Total int64
+	}
+	err := query.
+		Select(fmt.Sprintf("date_trunc('day', %s AT TIME ZONE 'Asia/Ho_Chi_Minh') AS day, COUNT(*) AS total", column)).
+		Where(column+" >= ?", since).
+		Group("day").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Day.Format("2006-01-02")] = row.Total
+	}
+	return counts, nil
+}
+
+func heatmapLevel(count int64) int {
+	switch {
+	case count == 0:
+		return 0
+	case count == 1:
+		return 1
+	case count <= 3:
+		return 2
+	case count <= 6:
+		return 3
+	}
+	return 4
+}
+
+// activityHeatmap dựng lưới hoạt động theo tuần (cột) và thứ (hàng, thứ Hai trước) trong khoảng một năm.
+func activityHeatmap(db *gorm.DB, userID uint) ([][]fiber.Map, int64) {
+	now := time.Now().In(vietnamLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, vietnamLocation)
+	start := today.AddDate(0, 0, -((int(today.Weekday())+6)%7)-7*(heatmapWeeks-1))
+
+	sources := []struct {
+		query  *gorm.DB
+		column string
+	}{
+		{publishedPosts(db.Model(&models.Post{})).Where("posts.author_id = ?", userID), "COALESCE(posts.publish_at, posts.created_at)"},
+		{publicBooks(db).Where("books.author_id = ?", userID), "books.created_at"},
+		{publicComments(db).Where("comments.author_id = ?", userID), "comments.created_at"},
+		{publicHighlights(db).Where("highlights.user_id = ?", userID), "highlights.created_at"},
+	}
+	counts := make(map[string]int64)
+	for _, source := range sources {
+		daily, err := dailyCounts(source.query, source.column, start)
+		if err != nil {
+			log.Printf("failed to count profile activity: %v", err)
+			continue
+		}
+		for day, n := range daily {
+			counts[day] += n
+		}
+	}
+
+	var total int64
+	weeks := make([][]fiber.Map, 0, heatmapWeeks)
+	for w := 0; w < heatmapWeeks; w++ {
+		days := make([]fiber.Map, 0, 7)
+		for d := 0; d < 7; d++ {
+			date := start.AddDate(0, 0, w*7+d)
+			if date.After(today) {
+				days = append(days, fiber.Map{"Future": true})
+				continue
+			}
+			n := counts[date.Format("2006-01-02")]
+			total += n
+			days = append(days, fiber.Map{
+				"Level": heatmapLevel(n),
+				"Label": fmt.Sprintf("%s: %d đóng góp", date.Format("02/01/2006"), n),
+			})
+		}
+		weeks = append(weeks, days)
+	}
+	return weeks, total
+}
+
+// ProfilePage là hồ sơ công khai /u/:handle với bài viết, sách, bình luận, highlight và biểu đồ hoạt động.
+func ProfilePage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		db := database.Get()
+		handle := strings.ToLower(c.Params("handle"))
+		var member models.User
+		if err := db.Where("handle = ?", handle).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return respondError(c, fiber.StatusNotFound, "Không tìm thấy thành viên", "/contributors")
+			}
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải hồ sơ", "/contributors")
+		}
+		viewer := currentUser(c)
+		if member.Suspended() && !policy.IsStaff(viewer) {
+			return respondError(c, fiber.StatusNotFound, "Không tìm thấy thành viên", "/contributors")
+		}
+
+		postQuery := func() *gorm.DB {
+			return publishedPosts(db.Model(&models.Post{})).Where("posts.author_id = ?", member.ID)
+		}
+		bookQuery := func() *gorm.DB { return publicBooks(db).Where("books.author_id = ?", member.ID) }
+		commentQuery := func() *gorm.DB { return publicComments(db).Where("comments.author_id = ?", member.ID) }
+		highlightQuery := func() *gorm.DB { return publicHighlights(db).Where("highlights.user_id = ?", member.ID) }
+
+		var counts struct{ Posts, Books, Comments, Highlights int64 }
+		postQuery().Count(&counts.Posts)
+		bookQuery().Count(&counts.Books)
+		commentQuery().Count(&counts.Comments)
+		highlightQuery().Count(&counts.Highlights)
+
+		var posts []models.Post
+		postQuery().Order(postOrder).Limit(profileListLimit).Find(&posts)
+		postItems := make([]fiber.Map, 0, len(posts))
+		for _, p := range posts {
+			postItems = append(postItems, fiber.Map{
+				"Title":        p.Title,
+				"Summary":      p.Summary,
+				"URL":          postPath(p),
+				"CreatedLabel": formatTimeVN(p.CreatedAt),
+			})
+		}
+
+		var books []models.Book
+		bookQuery().Order("books.created_at DESC").Limit(profileListLimit).Find(&books)
+		bookItems := make([]fiber.Map, 0, len(books))
+		for _, b := range books {
+			bookItems = append(bookItems, fiber.Map{
+				"Title":        b.Title,
+				"Description":  truncate(b.Description, 200),
+				"URL":          bookPath(b),
+				"CreatedLabel": formatTimeVN(b.CreatedAt),
+			})
+		}
+
+		var comments []struct {
+			ID        uint
+			PostID    uint
+			Content   string
+			CreatedAt time.Time
+			PostTitle string
+		}
+		commentQuery().
+			Select("comments.id, comments.post_id, comments.content, comments.created_at, posts.title AS post_title").
+			Order("comments.created_at DESC").Limit(profileListLimit).Scan(&comments)
+		commentItems := make([]fiber.Map, 0, len(comments))
+		for _, cm := range comments {
+			commentItems = append(commentItems, fiber.Map{
+				"Excerpt":      truncate(cm.Content, 200),
+				"PostTitle":    cm.PostTitle,
+				"URL":          commentAnchor(cm.PostID, cm.ID),
+				"CreatedLabel": formatTimeVN(cm.CreatedAt),
+			})
+		}
+
+		var highlights []struct {
+			ID              uint
+			HighlightedText string
+			Note            string
+			Color           string
+			CreatedAt       time.Time
+			BookID          uint
+			BookSlug        string
+			BookTitle       string
+			PageID          uint
+		}
+		highlightQuery().
+			Select("highlights.id, highlights.highlighted_text, highlights.note, highlights.color, highlights.created_at, " +
+				"books.id AS book_id, books.slug AS book_slug, books.title AS book_title, book_pages.id AS page_id").
+			Order("highlights.created_at DESC").Limit(profileListLimit).Scan(&highlights)
+		highlightItems := make([]fiber.Map, 0, len(highlights))
+		for _, h := range highlights {
+			highlightItems = append(highlightItems, fiber.Map{
+				"Text":         truncate(h.HighlightedText, 240),
+				"Note":         h.Note,
+				"BookTitle":    h.BookTitle,
+				"URL":          bookPath(models.Book{ID: h.BookID, Slug: h.BookSlug}) + "/read?page=" + strconv.FormatUint(uint64(h.PageID), 10),
+				"CreatedLabel": formatTimeVN(h.CreatedAt),
+			})
+		}
+
+		heatmap, yearTotal := activityHeatmap(db, member.ID)
+		profile := fiber.Map{
+			"ID":          member.ID,
+			"Name":        member.Name,
+			"Handle":      member.Handle,
+			"JobTitle":    member.JobTitle,
+			"Bio":         member.Bio,
+			"Links":       member.ProfileLinks(),
+			"AvatarURL":   member.AvatarURL(),
+			"Initial":     userInitial(member.Name),
+			"JoinedLabel": formatTimeVN(member.CreatedAt),
+			"Suspended":   member.Suspended(),
+			"FeedURL":     fmt.Sprintf("/authors/%d/feed.xml", member.ID),
+		}
+
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{
+				"profile":    profile,
+				"counts":     counts,
+				"posts":      postItems,
+				"books":      bookItems,
+				"comments":   commentItems,
+				"highlights": highlightItems,
+				"year_total": yearTotal,
+			})
+		}
+		isOwner := viewer != nil && viewer.ID == member.ID
+		// Tiến độ đọc là dữ liệu riêng tư nên kệ "Đọc tiếp" chỉ hiện cho chính chủ hồ sơ
+		var shelf []fiber.Map
+		if isOwner {
+			shelf = readingShelf(db, viewer, readingShelfLimit)
+		}
+		return render(c, "pages/profile", fiber.Map{
+			"Title":        member.Name,
+			"Profile":      profile,
+			"Counts":       counts,
+			"Posts":        postItems,
+			"Books":        bookItems,
+			"Comments":     commentItems,
+			"Highlights":   highlightItems,
+			"Heatmap":      heatmap,
+			"YearTotal":    yearTotal,
+			"IsOwner":      isOwner,
+			"ReadingShelf": shelf,
+			"Description":  member.JobTitle,
+		}, "main")
+	}
+}
+
+// AuthorProfileRedirect chuyển /authors/:id sang hồ sơ /u/:handle.
+func AuthorProfileRedirect() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authorID, err := strconv.Atoi(c.Params("id"))
+		if err != nil || authorID <= 0 {
+			return respondError(c, fiber.StatusBadRequest, "ID tác giả không hợp lệ", "/contributors")
+		}
+		var member models.User
+		if err := database.Get().Select("id", "handle").First(&member, authorID).Error; err != nil || member.Handle == "" {
+			return respondError(c, fiber.StatusNotFound, "Không tìm thấy thành viên", "/contributors")
+		}
+		return c.Redirect(profilePath(member), fiber.StatusMovedPermanently)
+	}
+}
+
+// topContributors xếp hạng thành viên theo số bài viết, sách và bình luận công khai.
+func topContributors(db *gorm.DB, limit int) ([]fiber.Map, error) {
+	type row struct {
+		UserID uint
+		Total  int64
+	}
+	var posts, books, comments []row
+	if err := publishedPosts(db.Model(&models.Post{})).
+		Select("posts.author_id AS user_id, COUNT(*) AS total").Group("posts.author_id").Scan(&posts).Error; err != nil {
+		return nil, err
+	}
+	if err := publicBooks(db).
+		Select("books.author_id AS user_id, COUNT(*) AS total").Group("books.author_id").Scan(&books).Error; err != nil {
+		return nil, err
+	}
+	if err := publicComments(db).
+		Select("comments.author_id AS user_id, COUNT(*) AS total").Group("comments.author_id").Scan(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	type tally struct{ Posts, Books, Comments int64 }
+	tallies := make(map[uint]*tally)
+	get := func(id uint) *tally {
+		if tallies[id] == nil {
+			tallies[id] = &tally{}
+		}
+		return tallies[id]
+	}
+	for _, r := range posts {
+		get(r.UserID).Posts = r.Total
+	}
+	for _, r := range books {
+		get(r.UserID).Books = r.Total
+	}
+	for _, r := range comments {
+		get(r.UserID).Comments = r.Total
+	}
+	if len(tallies) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, 0, len(tallies))
+	for id := range tallies {
+		ids = append(ids, id)
+	}
+	var users []models.User
+	if err := db.Where("id IN ? AND suspended_at IS NULL", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	total := func(u models.User) int64 {
+		t := tallies[u.ID]
+		return t.Posts + t.Books + t.Comments
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if ti, tj := total(users[i]), total(users[j]); ti != tj {
+			return ti > tj
+		}
+		return users[i].Name < users[j].Name
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	items := make([]fiber.Map, 0, len(users))
+	for _, u := range users {
+		t := tallies[u.ID]
+		items = append(items, fiber.Map{
+			"Name":          u.Name,
+			"URL":           profilePath(u),
+			"JobTitle":      u.JobTitle,
+			"Bio":           truncate(u.Bio, 160),
+			"AvatarURL":     u.AvatarURL(),
+			"Initial":       userInitial(u.Name),
+			"Posts":         t.Posts,
+			"Books":         t.Books,
+			"Comments":      t.Comments,
+			"Contributions": total(u),
+		})
+	}
+	return items, nil
+}
+
+// loadProfileAccount tải tài khoản đang đăng nhập cho trang chỉnh hồ sơ; không cho dùng token API.
+func loadProfileAccount(c *fiber.Ctx) (*models.User, error) {
+	userID, err := sessionOnlyUserID(c)
+	if err != nil {
+		if requestAPIToken(c) != nil {
+			return nil, respondError(c, fiber.StatusForbidden, "Không thể sửa hồ sơ bằng token", "/account")
+		}
+		return nil, respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login?next=/account/profile")
+	}
+	var user models.User
+	if err := database.Get().First(&user, userID).Error; err != nil {
+		return nil, respondError(c, fiber.StatusNotFound, "Tài khoản không tồn tại", "/")
+	}
+	return &user, nil
+}
+
+// ProfileSettingsPage hiển thị form chỉnh sửa hồ sơ công khai.
+func ProfileSettingsPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadProfileAccount(c)
+		if user == nil {
+			return err
+		}
+		return render(c, "pages/account_profile", fiber.Map{
+			"Title":      "Hồ sơ công khai",
+			"User":       user,
+			"Links":      user.Links,
+			"ProfileURL": profilePath(*user),
+			"AvatarURL":  user.AvatarURL(),
+		}, "main")
+	}
+}
+
+type updateProfileRequest struct {
+	Name         string `json:"name"`
+	Handle       string `json:"handle"`
+	JobTitle     string `json:"job_title"`
+	Bio          string `json:"bio"`
+	Links        string `json:"links"`
+	RemoveAvatar bool   `json:"remove_avatar"`
+}
+
+// normalizeProfileLinks kiểm tra mỗi dòng là URL http(s) hợp lệ.
+func normalizeProfileLinks(raw string) (string, error) {
+	var links []string
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parsed, err := url.Parse(line)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(line) > 255 {
+			return "", fmt.Errorf("Liên kết không hợp lệ: %s", truncate(line, 60))
+		}
+		links = append(links, line)
+	}
+	if len(links) > maxProfileLinks {
+		return "", fmt.Errorf("Tối đa %d liên kết", maxProfileLinks)
+	}
+	return strings.Join(links, "\n"), nil
+}
+
+// UpdateProfile lưu tên, handle, chức danh, giới thiệu, liên kết và ảnh đại diện.
+// Ảnh đại diện lưu chung bảng images; ảnh cũ bị xóa khi thay hoặc gỡ.
+func UpdateProfile() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadProfileAccount(c)
+		if user == nil {
+			return err
+		}
+
+		var body updateProfileRequest
+		if isJSONRequest(c) {
+			if err := c.BodyParser(&body); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "payload không hợp lệ")
+			}
+		} else {
+			body.Name = c.FormValue("name")
+			body.Handle = c.FormValue("handle")
+			body.JobTitle = c.FormValue("job_title")
+			body.Bio = c.FormValue("bio")
+			body.Links = c.FormValue("links")
+			body.RemoveAvatar = c.FormValue("remove_avatar") != ""
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		body.Handle = strings.ToLower(strings.TrimSpace(body.Handle))
+		body.JobTitle = strings.TrimSpace(body.JobTitle)
+		body.Bio = strings.TrimSpace(body.Bio)
+
+		const redirect = "/account/profile"
+		if len(body.Name) < 3 || len(body.Name) > 120 {
+			return respondError(c, fiber.StatusBadRequest, "Tên phải từ 3 đến 120 ký tự", redirect)
+		}
+		if !handlePattern.MatchString(body.Handle) {
+			return respondError(c, fiber.StatusBadRequest, "Handle chỉ gồm 3-30 chữ thường, số hoặc dấu gạch ngang", redirect)
+		}
+		if len(body.JobTitle) > 120 {
+			return respondError(c, fiber.StatusBadRequest, "Chức danh tối đa 120 ký tự", redirect)
+		}
+		if len(body.Bio) > maxBioLength {
+			return respondError(c, fiber.StatusBadRequest, fmt.Sprintf("Giới thiệu tối đa %d ký tự", maxBioLength), redirect)
+		}
+		links, err := normalizeProfileLinks(body.Links)
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, err.Error(), redirect)
+		}
+
+		db := database.Get()
+		if taken, err := handleTaken(db, body.Handle, user.ID); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể kiểm tra handle", redirect)
+		} else if taken {
+			return respondError(c, fiber.StatusConflict, "Handle này đã có người dùng", redirect)
+		}
+
+		var avatar *models.Image
+		if !isJSONRequest(c) {
+			if fileHeader, err := c.FormFile("avatar"); err == nil && fileHeader.Size > 0 {
+				image, uploadErr := readImageUpload(fileHeader, user.ID)
+				if uploadErr != nil {
+					return respondError(c, uploadErr.Code, uploadErr.Message, redirect)
+				}
+				avatar = image
+			}
+		}
+
+		oldAvatarID := user.AvatarImageID
+		err = db.Transaction(func(tx *gorm.DB) error {
+			updates := map[string]interface{}{
+				"name":      body.Name,
+				"handle":    body.Handle,
+				"job_title": body.JobTitle,
+				"bio":       body.Bio,
+				"links":     links,
+			}
+			switch {
+			case avatar != nil:
+				if err := tx.Create(avatar).Error; err != nil {
+					return err
+				}
+				updates["avatar_image_id"] = avatar.ID
+			case body.RemoveAvatar:
+				updates["avatar_image_id"] = nil
+			}
+			if err := tx.Model(user).Updates(updates).Error; err != nil {
+				return err
+			}
+			if oldAvatarID != nil && (avatar != nil || body.RemoveAvatar) {
+				return tx.Where("id = ? AND uploader_id = ?", *oldAvatarID, user.ID).Delete(&models.Image{}).Error
+			}
+			return nil
+		})
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể lưu hồ sơ", redirect)
+		}
+
+		if sess, err := sessionStore.Get(c); err == nil {
+			sess.Set("userName", body.Name)
+			_ = sess.Save()
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "url": "/u/" + body.Handle})
+		}
+		setFlash(c, "success", "Đã cập nhật hồ sơ")
+		return c.Status(fiber.StatusSeeOther).Redirect(redirect)
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"fiber-learning-community/internal/ratelimit"
+)
+
+// Tên các policy rate limit; giới hạn mặc định ghi đè được bằng RATE_LIMIT_<TÊN> (vd. RATE_LIMIT_LOGIN=10/1m).
+const (
+	RateLimitLogin         = "login"
+	RateLimitRegister      = "register"
+	RateLimitPasswordReset = "password-reset"
+	RateLimitComment       = "comment"
+	RateLimitUpload        = "upload"
+)
+
+var defaultRateLimits = []ratelimit.Policy{
+	{Name: RateLimitLogin, Limit: 10, Window: time.Minute},
+	{Name: RateLimitRegister, Limit: 5, Window: time.Hour},
+	{Name: RateLimitPasswordReset, Limit: 5, Window: time.Hour},
+	{Name: RateLimitComment, Limit: 10, Window: time.Minute},
+	{Name: RateLimitUpload, Limit: 30, Window: time.Hour},
+}
+
+// loginGuardConfig cấu hình chống dò mật khẩu theo từng tài khoản (email).
+type loginGuardConfig struct {
+	DelayAfter   int           // Số lần sai trước khi bắt đầu buộc chờ giữa các lần thử
+	LockAfter    int           // Số lần sai trong LockDuration thì khóa đăng nhập tạm thời
+	LockDuration time.Duration // Thời gian khóa, cũng là cửa sổ đếm số lần sai
+	MaxDelay     time.Duration
+}
+
+var (
+	rateLimitStore    ratelimit.Store = ratelimit.NewMemoryStore()
+	rateLimitPolicies                 = policyMap(defaultRateLimits)
+	loginGuard                        = loginGuardConfig{DelayAfter: 3, LockAfter: 10, LockDuration: 15 * time.Minute, MaxDelay: time.Minute}
+)
+
+func policyMap(policies []ratelimit.Policy) map[string]ratelimit.Policy {
+	m := make(map[string]ratelimit.Policy, len(policies))
+	for _, p := range policies {
+		m[p.Name] = p
+	}
+	return m
+}
+
+// ConfigureRateLimits chọn store và đọc policy từ biến môi trường:
+// RATE_LIMIT_<TÊN>, LOGIN_DELAY_AFTER, LOGIN_LOCKOUT_AFTER, LOGIN_LOCKOUT_MINUTES.
+func ConfigureRateLimits(store ratelimit.Store) error {
+	policies := make([]ratelimit.Policy, 0, len(defaultRateLimits))
+	for _, def := range defaultRateLimits {
+		p, err := ratelimit.PolicyFromEnv(def)
+		if err != nil {
+			return err
+		}
+		policies = append(policies, p)
+	}
+
+	guard := loginGuard
+	for key, target := range map[string]*int{"LOGIN_DELAY_AFTER": &guard.DelayAfter, "LOGIN_LOCKOUT_AFTER": &guard.LockAfter} {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("%s không hợp lệ: %s", key, value)
+			}
+			*target = n
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv("LOGIN_LOCKOUT_MINUTES")); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			return fmt.Errorf("LOGIN_LOCKOUT_MINUTES không hợp lệ: %s", value)
+		}
+		guard.LockDuration = time.Duration(minutes) * time.Minute
+	}
+
+	rateLimitStore = store
+	rateLimitPolicies = policyMap(policies)
+	loginGuard = guard
+	return nil
+}
+
+// rateLimitKey đếm theo tài khoản khi đã đăng nhập (kể cả qua token), nếu không thì theo IP.
+func rateLimitKey(c *fiber.Ctx) string {
+	if userID, err := currentUserID(c); err == nil && userID != 0 {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return "ip:" + c.IP()
+}
+
+func secondsUntil(t, now time.Time) int {
+	return int(math.Ceil(t.Sub(now).Seconds()))
+}
+
+// setRateLimitHeaders gửi các header RateLimit-* theo draft IETF "RateLimit header fields for HTTP".
+func setRateLimitHeaders(c *fiber.Ctx, policy ratelimit.Policy, counter ratelimit.Counter, now time.Time) {
+	remaining := policy.Limit - counter.Count
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Set("RateLimit-Reset", strconv.Itoa(secondsUntil(counter.ResetAt, now)))
+	c.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds())))
+}
+
+func retryLabel(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%d giây", seconds)
+	}
+	return fmt.Sprintf("%d phút", (seconds+59)/60)
+}
+
+// tooManyRequests trả 429 kèm Retry-After. Với form HTML, người dùng được đưa về redirect kèm thông báo.
+func tooManyRequests(c *fiber.Ctx, retryAfter int, message, redirect string) error {
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return respondError(c, fiber.StatusTooManyRequests, fmt.Sprintf("%s, hãy thử lại sau %s", message, retryLabel(retryAfter)), redirect)
+}
+
+// RateLimit giới hạn route theo policy name. Lỗi store không chặn request để sự cố
+// database không làm sập chức năng đăng nhập.
+func RateLimit(name string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		policy, ok := rateLimitPolicies[name]
+		if !ok {
+			return c.Next()
+		}
+		now := time.Now()
+		counter, allowed, err := ratelimit.Allow(rateLimitStore, policy, rateLimitKey(c), now)
+		if err != nil {
+			log.Printf("rate limit %s unavailable: %v", name, err)
+			return c.Next()
+		}
+		setRateLimitHeaders(c, policy, counter, now)
+		if !allowed {
+			return tooManyRequests(c, secondsUntil(counter.ResetAt, now), "Bạn thao tác quá nhiều lần", sameOriginReferer(c))
+		}
+		return c.Next()
+	}
+}
+
+func loginKey(kind, email string) string {
+	return "login-" + kind + ":" + email
+}
+
+// loginThrottled cho biết email đang bị khóa hoặc phải chờ trước lần thử tiếp theo.
+// Áp dụng cả với email không tồn tại để không lộ tài khoản nào có thật.
+func loginThrottled(email string, now time.Time) (retryAfter int, locked bool) {
+	if lock, err := rateLimitStore.Peek(loginKey("lock", email), now); err == nil && lock.Count > 0 {
+		return secondsUntil(lock.ResetAt, now), true
+	}
+	if wait, err := rateLimitStore.Peek(loginKey("wait", email), now); err == nil && wait.Count > 0 {
+		return secondsUntil(wait.ResetAt, now), false
+	}
+	return 0, false
+}
+
+// recordLoginFailure đếm lần đăng nhập sai: từ lần thứ DelayAfter thời gian chờ tăng gấp đôi
+// sau mỗi lần sai (1s, 2s, 4s... tối đa MaxDelay), đến LockAfter thì khóa LockDuration.
+func recordLoginFailure(email string, now time.Time) {
+	failures, err := rateLimitStore.Incr(loginKey("fail", email), loginGuard.LockDuration, now)
+	if err != nil {
+		log.Printf("failed to record login failure: %v", err)
+		return
+	}
+	switch {
+	case failures.Count >= loginGuard.LockAfter:
+		_, err = rateLimitStore.Incr(loginKey("lock", email), loginGuard.LockDuration, now)
+		log.Printf("🔒 Login locked for %s after %d failed attempts", email, failures.Count)
+	case failures.Count >= loginGuard.DelayAfter:
+		delay := loginGuard.MaxDelay
+		if shift := failures.Count - loginGuard.DelayAfter; shift < 30 {
+			delay = time.Second << uint(shift)
+		}
+		if delay > loginGuard.MaxDelay {
+			delay = loginGuard.MaxDelay
+		}
+		_, err = rateLimitStore.Incr(loginKey("wait", email), delay, now)
+	}
+	if err != nil {
+		log.Printf("failed to record login failure: %v", err)
+	}
+}
+
+// clearLoginFailures xóa bộ đếm sai sau khi đăng nhập thành công.
+func clearLoginFailures(email string) {
+	for _, kind := range []string{"fail", "wait"} {
+		if err := rateLimitStore.Reset(loginKey(kind, email)); err != nil {
+			log.Printf("failed to reset login failures: %v", err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// readingShelfLimit là số sách tối đa trên kệ "Đọc tiếp".
+const readingShelfLimit = 6
+
+// readingFinishedPercent: đang ở trang cuối và đã cuộn tới mức này thì coi như đọc xong, bỏ khỏi kệ.
+const readingFinishedPercent = 95
+
+// readingProgressFor trả về vị trí đọc của user trong sách, nil nếu chưa đọc hoặc chưa đăng nhập.
+func readingProgressFor(db *gorm.DB, user *models.User, bookID uint) *models.ReadingProgress {
+	if user == nil {
+		return nil
+	}
+	var progress models.ReadingProgress
+	if err := db.Where("user_id = ? AND book_id = ?", user.ID, bookID).First(&progress).Error; err != nil {
+		return nil
+	}
+	return &progress
+}
+
+// SaveReadingProgress ghi lại trang và vị trí cuộn người dùng đang đọc. Trình đọc gọi endpoint này
+// mỗi khi lật trang hoặc dừng cuộn: {"page_id": id, "scroll_percent": 0-100}.
+func SaveReadingProgress() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Chưa đăng nhập", "/auth/login")
+		}
+		bookID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "ID sách không hợp lệ", "/books")
+		}
+
+		var body struct {
+			PageID        uint `json:"page_id"`
+			ScrollPercent int  `json:"scroll_percent"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.PageID == 0 {
+			return respondError(c, fiber.StatusBadRequest, "Dữ liệu không hợp lệ", "/books")
+		}
+		body.ScrollPercent = min(max(body.ScrollPercent, 0), 100)
+
+		db := database.Get()
+		var book models.Book
+		if err := db.First(&book, bookID).Error; err != nil || !can(user, policy.View, book) {
+			return respondError(c, fiber.StatusNotFound, "Không tìm thấy sách", "/books")
+		}
+		var page models.BookPage
+		if err := db.Select("id", "page_number").Where("id = ? AND book_id = ?", body.PageID, book.ID).First(&page).Error; err != nil {
+			return respondError(c, fiber.StatusNotFound, "Không tìm thấy trang", bookPath(book))
+		}
+
+		var progress models.ReadingProgress
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ? AND book_id = ?", user.ID, book.ID).First(&progress).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if progress.FurthestPageID != page.ID {
+				// Trang xa nhất so theo số trang hiện tại, trang đã bị xóa thì thay luôn
+				var furthest models.BookPage
+				if tx.Select("page_number").Where("id = ? AND book_id = ?", progress.FurthestPageID, book.ID).First(&furthest).Error != nil ||
+					page.PageNumber > furthest.PageNumber {
+					progress.FurthestPageID = page.ID
+				}
+			}
+			progress.UserID, progress.BookID = user.ID, book.ID
+			progress.PageID, progress.ScrollPercent = page.ID, body.ScrollPercent
+			if progress.ID != 0 {
+				return tx.Save(&progress).Error
+			}
+			// Hai tab cùng ghi lần đầu: bản ghi sau cập nhật bản ghi trước thay vì lỗi unique
+			return tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"page_id", "scroll_percent", "furthest_page_id", "updated_at"}),
+			}).Create(&progress).Error
+		})
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi lưu tiến độ đọc", bookPath(book))
+		}
+		return c.JSON(fiber.Map{"success": true, "progress": progress})
+	}
+}
+
+// readingShelf trả về các sách user đang đọc dở, đọc gần nhất lên trước, kèm link mở lại đúng trang.
+func readingShelf(db *gorm.DB, user *models.User, limit int) []fiber.Map {
+	shelf := []fiber.Map{}
+	if user == nil {
+		return shelf
+	}
+	const pageCount = "(SELECT COUNT(*) FROM book_pages p WHERE p.book_id = books.id AND p.deleted_at IS NULL)"
+	var rows []struct {
+		BookID        uint
+		BookSlug      string
+		BookTitle     string
+		CoverURL      string
+		CoverColor    string
+		AuthorName    string
+		PageID        uint
+		PageNumber    int
+		PageCount     int
+		ScrollPercent int
+		UpdatedAt     time.Time
+	}
+	err := db.Table("reading_progresses").
+		Select("books.id AS book_id, books.slug AS book_slug, books.title AS book_title, books.cover_url, books.cover_color, "+
+			"users.name AS author_name, book_pages.id AS page_id, COALESCE(book_pages.page_number, 1) AS page_number, "+
+			pageCount+" AS page_count, reading_progresses.scroll_percent, reading_progresses.updated_at").
+		Joins("JOIN books ON books.id = reading_progresses.book_id AND books.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = books.author_id").
+		Joins("LEFT JOIN book_pages ON book_pages.id = reading_progresses.page_id AND book_pages.deleted_at IS NULL").
+		Where("reading_progresses.user_id = ?", user.ID).
+		Where("books.id IN (?)", policy.VisibleBooks(db.Model(&models.Book{}), user).Select("books.id")).
+		Where("NOT (COALESCE(book_pages.page_number, 1) >= "+pageCount+" AND reading_progresses.scroll_percent >= ?)", readingFinishedPercent).
+		Order("reading_progresses.updated_at DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return shelf
+	}
+
+	for _, row := range rows {
+		book := models.Book{ID: row.BookID, Slug: row.BookSlug}
+		url := bookPath(book) + "/read"
+		if row.PageID != 0 {
+			url += "?page=" + strconv.FormatUint(uint64(row.PageID), 10)
+		}
+		percent := 0
+		if row.PageCount > 0 {
+			percent = min(((row.PageNumber-1)*100+row.ScrollPercent)/row.PageCount, 100)
+		}
+		shelf = append(shelf, fiber.Map{
+			"Title":        row.BookTitle,
+			"AuthorName":   row.AuthorName,
+			"CoverURL":     row.CoverURL,
+			"CoverColor":   row.CoverColor,
+			"URL":          url,
+			"PageNumber":   row.PageNumber,
+			"PageCount":    row.PageCount,
+			"Percent":      percent,
+			"UpdatedLabel": formatTimeVN(row.UpdatedAt),
+		})
+	}
+	return shelf
+}
+
+// readingFunnel đếm số người đọc (trừ tác giả) đã đọc tới từng trang, tính theo trang xa nhất của mỗi người.
+// Trả về một dòng cho mỗi trang theo thứ tự và tổng số người đã mở sách.
+func readingFunnel(db *gorm.DB, book models.Book, pages []models.BookPage) ([]fiber.Map, int64, error) {
+	var rows []struct {
+		PageNumber int
+		Readers    int64
+	}
+	err := db.Table("reading_progresses").
+		Select("book_pages.page_number, COUNT(*) AS readers").
+		Joins("JOIN book_pages ON book_pages.id = reading_progresses.furthest_page_id AND book_pages.deleted_at IS NULL").
+		Where("reading_progresses.book_id = ? AND reading_progresses.user_id <> ?", book.ID, book.AuthorID).
+		Group("book_pages.page_number").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	stoppedAt := make(map[int]int64, len(rows))
+	for _, row := range rows {
+		stoppedAt[row.PageNumber] = row.Readers
+	}
+
+	// Ai dừng ở trang N cũng đã đọc qua mọi trang trước đó: cộng dồn từ trang cuối về đầu.
+	reached := make([]int64, len(pages))
+	var total int64
+	for i := len(pages) - 1; i >= 0; i-- {
+		total += stoppedAt[pages[i].PageNumber]
+		reached[i] = total
+	}
+	funnel := make([]fiber.Map, len(pages))
+	for i, page := range pages {
+		percent := 0
+		if total > 0 {
+			percent = int(reached[i] * 100 / total)
+		}
+		funnel[i] = fiber.Map{
+			"PageID":     page.ID,
+			"PageNumber": page.PageNumber,
+			"Title":      pageTitle(page),
+			"Readers":    reached[i],
+			"Percent":    percent,
+		}
+	}
+	return funnel, total, nil
+}
+
+// BookReadingStats trả về phễu đọc theo trang của sách cho tác giả (GET /books/:id/reading-stats).
+func BookReadingStats() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		book, err := loadEditableBook(c)
+		if book == nil {
+			return err
+		}
+		db := database.Get()
+		var pages []models.BookPage
+		if err := db.Where("book_id = ?", book.ID).Order("page_number ASC").Find(&pages).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi tải thống kê", bookPath(*book))
+		}
+		funnel, readers, err := readingFunnel(db, *book, pages)
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Lỗi tải thống kê", bookPath(*book))
+		}
+		items := make([]fiber.Map, 0, len(funnel))
+		for _, row := range funnel {
+			items = append(items, fiber.Map{
+				"page_id":     row["PageID"],
+				"page_number": row["PageNumber"],
+				"title":       row["Title"],
+				"readers":     row["Readers"],
+				"percent":     row["Percent"],
+			})
+		}
+		return c.JSON(fiber.Map{"book_id": book.ID, "readers": readers, "pages": items})
+	}
+}
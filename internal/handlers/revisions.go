@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"fiber-learning-community/internal/anchor"
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+)
+
+// diffLine là một dòng trong kết quả so sánh hai phiên bản.
+type diffLine struct {
+	Kind    string // "same", "add" hoặc "del"
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// loadLineAnnotations trả về map số dòng -> chú thích của bài viết.
+func loadLineAnnotations(db *gorm.DB, postID uint) map[int]string {
+	lineAnnotations := make(map[int]string)
+	var annotations []models.Annotation
+	if err := db.Where("post_id = ? AND orphaned = ?", postID, false).Find(&annotations).Error; err != nil {
+		return lineAnnotations
+	}
+	for _, ann := range annotations {
+		lineAnnotations[ann.LineNumber] = ann.Content
+	}
+	return lineAnnotations
+}
+
+// replaceLineAnnotations thay chú thích của bài viết bằng map (theo nội dung hiện tại của post)
+// và gắn lại bình luận theo dòng từ nội dung cũ. Chú thích mồ côi được giữ lại để tác giả ghim lại.
+func replaceLineAnnotations(db *gorm.DB, post *models.Post, oldContent string, lineAnnotations map[int]string) error {
+	if err := db.Where("post_id = ? AND orphaned = ?", post.ID, false).Delete(&models.Annotation{}).Error; err != nil {
+		return err
+	}
+	blocks := anchor.Blocks(post.Content)
+	for lineNum, text := range lineAnnotations {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		line, lineAnchor := anchorNewNote(blocks, lineNum, "")
+		annotation := models.Annotation{
+			PostID:     &[]uint{post.ID}[0],
+			LineNumber: line,
+			Content:    text,
+			LineAnchor: lineAnchor,
+		}
+		if err := db.Create(&annotation).Error; err != nil {
+			return err
+		}
+	}
+	return reanchorComments(db, post.ID, anchor.Blocks(oldContent), blocks)
+}
+
+// snapshotPost ghi lại trạng thái hiện tại của bài viết thành một phiên bản mới. Số phiên bản được
+// tính trong cùng transaction với lúc ghi, sau khi khóa dòng bài viết, để hai lần lưu đồng thời
+// không nhận cùng một số (unique index (post_id, number) là chốt chặn cuối).
+func snapshotPost(db *gorm.DB, post *models.Post, editorID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Post{}, post.ID).Error; err != nil {
+			return err
+		}
+
+		encoded := make(map[string]string)
+		for line, text := range loadLineAnnotations(tx, post.ID) {
+			encoded[strconv.Itoa(line)] = text
+		}
+		annotationsJSON, err := json.Marshal(encoded)
+		if err != nil {
+			return err
+		}
+
+		var lastNumber int
+		if err := tx.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).Select("COALESCE(MAX(number), 0)").Scan(&lastNumber).Error; err != nil {
+			return err
+		}
+
+		revision := models.PostRevision{
+			PostID:          post.ID,
+			Number:          lastNumber + 1,
+			Title:           post.Title,
+			Summary:         post.Summary,
+			Content:         post.Content,
+			Tags:            post.Tags,
+			LineAnnotations: string(annotationsJSON),
+			EditorID:        editorID,
+		}
+		return tx.Create(&revision).Error
+	})
+}
+
+// revisionAnnotations giải mã chú thích đã lưu trong phiên bản.
+func revisionAnnotations(rev models.PostRevision) map[int]string {
+	result := make(map[int]string)
+	if rev.LineAnnotations == "" {
+		return result
+	}
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(rev.LineAnnotations), &raw); err != nil {
+		return result
+	}
+	for lineStr, text := range raw {
+		if line, err := strconv.Atoi(lineStr); err == nil {
+			result[line] = text
+		}
+	}
+	return result
+}
+
+// maxDiffCells giới hạn bảng LCS (số dòng cũ × số dòng mới, sau khi bỏ phần đầu/cuối giống nhau)
+// vì trang so sánh là GET công khai: hai bài vài chục nghìn dòng không được chiếm hàng GB bộ nhớ.
+const maxDiffCells = 1 << 21
+
+// diffLines so sánh hai đoạn văn bản theo từng dòng bằng thuật toán LCS. Phần giữa quá lớn
+// thì hiển thị toàn bộ dòng cũ là xóa, dòng mới là thêm thay vì tính LCS.
+func diffLines(oldText, newText string) []diffLine {
+	a := strings.Split(oldText, "\n")
+	b := strings.Split(newText, "\n")
+	lines := make([]diffLine, 0, len(a)+len(b))
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		lines = append(lines, diffLine{Kind: "same", Text: a[prefix], OldLine: prefix + 1, NewLine: prefix + 1})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	if len(midA)*len(midB) > maxDiffCells {
+		for i, text := range midA {
+			lines = append(lines, diffLine{Kind: "del", Text: text, OldLine: prefix + i + 1})
+		}
+		for j, text := range midB {
+			lines = append(lines, diffLine{Kind: "add", Text: text, NewLine: prefix + j + 1})
+		}
+	} else {
+		lines = appendLCSDiff(lines, midA, midB, prefix)
+	}
+
+	for k := suffix; k > 0; k-- {
+		lines = append(lines, diffLine{Kind: "same", Text: a[len(a)-k], OldLine: len(a) - k + 1, NewLine: len(b) - k + 1})
+	}
+	return lines
+}
+
+// appendLCSDiff so sánh a và b (bắt đầu sau offset dòng giống nhau) bằng bảng LCS.
+func appendLCSDiff(lines []diffLine, a, b []string, offset int) []diffLine {
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{Kind: "same", Text: a[i], OldLine: offset + i + 1, NewLine: offset + j + 1})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{Kind: "del", Text: a[i], OldLine: offset + i + 1})
+			i++
+		default:
+			lines = append(lines, diffLine{Kind: "add", Text: b[j], NewLine: offset + j + 1})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{Kind: "del", Text: a[i], OldLine: offset + i + 1})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{Kind: "add", Text: b[j], NewLine: offset + j + 1})
+	}
+	return lines
+}
+
+// diffAnnotations liệt kê các dòng có chú thích thay đổi giữa hai phiên bản.
+func diffAnnotations(oldAnn, newAnn map[int]string) []fiber.Map {
+	lineSet := make(map[int]bool)
+	for line := range oldAnn {
+		lineSet[line] = true
+	}
+	for line := range newAnn {
+		lineSet[line] = true
+	}
+	lines := make([]int, 0, len(lineSet))
+	for line := range lineSet {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	changes := make([]fiber.Map, 0)
+	for _, line := range lines {
+		if oldAnn[line] == newAnn[line] {
+			continue
+		}
+		changes = append(changes, fiber.Map{
+			"LineNumber": line,
+			"Old":        oldAnn[line],
+			"New":        newAnn[line],
+		})
+	}
+	return changes
+}
+
+func wantsJSON(c *fiber.Ctx) bool {
+	return isJSONRequest(c) || c.Get(fiber.HeaderAccept) == fiber.MIMEApplicationJSON
+}
+
+func loadPostForRevisions(c *fiber.Ctx) (*models.Post, error) {
+	postID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, respondError(c, fiber.StatusBadRequest, "ID bài viết không hợp lệ", "/posts")
+	}
+
+	var post models.Post
+	if err := database.Get().First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, respondError(c, fiber.StatusNotFound, "Bài viết không tồn tại", "/posts")
+		}
+		return nil, respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/posts")
+	}
+
+	if !can(currentUser(c), policy.View, post) {
+		return nil, respondError(c, fiber.StatusNotFound, "Bài viết không tồn tại", "/posts")
+	}
+	return &post, nil
+}
+
+// PostRevisionsPage liệt kê lịch sử chỉnh sửa của bài viết.
+func PostRevisionsPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		post, err := loadPostForRevisions(c)
+		if post == nil {
+			return err
+		}
+
+		db := database.Get()
+		var revisions []models.PostRevision
+		if err := db.Preload("Editor").Where("post_id = ?", post.ID).Order("number DESC").Find(&revisions).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải lịch sử chỉnh sửa", fmt.Sprintf("/posts/%d", post.ID))
+		}
+
+		isAuthor := can(currentUser(c), policy.Edit, post)
+
+		if wantsJSON(c) {
+			items := make([]fiber.Map, 0, len(revisions))
+			for _, rev := range revisions {
+				items = append(items, fiber.Map{
+					"id":          rev.ID,
+					"number":      rev.Number,
+					"title":       rev.Title,
+					"editor_id":   rev.EditorID,
+					"editor_name": rev.Editor.Name,
+					"created_at":  rev.CreatedAt,
+				})
+			}
+			return c.JSON(fiber.Map{"post_id": post.ID, "revisions": items})
+		}
+
+		items := make([]fiber.Map, 0, len(revisions))
+		for i, rev := range revisions {
+			item := fiber.Map{
+				"ID":           rev.ID,
+				"Number":       rev.Number,
+				"Title":        rev.Title,
+				"EditorName":   rev.Editor.Name,
+				"CreatedLabel": formatTimeVN(rev.CreatedAt),
+				"IsCurrent":    i == 0,
+			}
+			if i+1 < len(revisions) {
+				item["PreviousID"] = revisions[i+1].ID
+			}
+			items = append(items, item)
+		}
+
+		return render(c, "pages/post_revisions", fiber.Map{
+			"Title":     "Lịch sử chỉnh sửa",
+			"Post":      fiber.Map{"ID": post.ID, "URL": postPath(*post), "Title": post.Title},
+			"Revisions": items,
+			"IsAuthor":  isAuthor,
+		}, "main")
+	}
+}
+
+// PostRevisionDiff so sánh hai phiên bản bất kỳ của bài viết (?from=&to=).
+func PostRevisionDiff() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		post, err := loadPostForRevisions(c)
+		if post == nil {
+			return err
+		}
+
+		backURL := fmt.Sprintf("/posts/%d/revisions", post.ID)
+		fromID, errFrom := strconv.Atoi(c.Query("from"))
+		toID, errTo := strconv.Atoi(c.Query("to"))
+		if errFrom != nil || errTo != nil {
+			return respondError(c, fiber.StatusBadRequest, "Cần chọn hai phiên bản để so sánh", backURL)
+		}
+
+		db := database.Get()
+		var from, to models.PostRevision
+		if err := db.Where("post_id = ?", post.ID).First(&from, fromID).Error; err != nil {
+			return respondError(c, fiber.StatusNotFound, "Phiên bản không tồn tại", backURL)
+		}
+		if err := db.Where("post_id = ?", post.ID).First(&to, toID).Error; err != nil {
+			return respondError(c, fiber.StatusNotFound, "Phiên bản không tồn tại", backURL)
+		}
+
+		contentDiff := diffLines(from.Content, to.Content)
+		annotationChanges := diffAnnotations(revisionAnnotations(from), revisionAnnotations(to))
+
+		if wantsJSON(c) {
+			lines := make([]fiber.Map, 0, len(contentDiff))
+			for _, line := range contentDiff {
+				lines = append(lines, fiber.Map{
+					"kind":     line.Kind,
+					"text":     line.Text,
+					"old_line": line.OldLine,
+					"new_line": line.NewLine,
+				})
+			}
+			annotations := make([]fiber.Map, 0, len(annotationChanges))
+			for _, change := range annotationChanges {
+				annotations = append(annotations, fiber.Map{
+					"line_number": change["LineNumber"],
+					"old":         change["Old"],
+					"new":         change["New"],
+				})
+			}
+			return c.JSON(fiber.Map{
+				"from":        from.Number,
+				"to":          to.Number,
+				"title":       fiber.Map{"old": from.Title, "new": to.Title},
+				"summary":     fiber.Map{"old": from.Summary, "new": to.Summary},
+				"tags":        fiber.Map{"old": from.Tags, "new": to.Tags},
+				"content":     lines,
+				"annotations": annotations,
+			})
+		}
+
+		return render(c, "pages/post_revision_diff", fiber.Map{
+			"Title":             "So sánh phiên bản",
+			"Post":              fiber.Map{"ID": post.ID, "URL": postPath(*post), "Title": post.Title},
+			"From":              from,
+			"To":                to,
+			"TitleChanged":      from.Title != to.Title,
+			"SummaryChanged":    from.Summary != to.Summary,
+			"TagsChanged":       from.Tags != to.Tags,
+			"ContentDiff":       contentDiff,
+			"AnnotationChanges": annotationChanges,
+		}, "main")
+	}
+}
+
+// RestorePostRevision khôi phục bài viết về một phiên bản cũ (tác giả hoặc ban quản trị).
+func RestorePostRevision() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để khôi phục bài viết", "/auth/login")
+		}
+
+		post, err := loadPostForRevisions(c)
+		if post == nil {
+			return err
+		}
+
+		backURL := fmt.Sprintf("/posts/%d/revisions", post.ID)
+		if !can(currentUser(c), policy.Edit, post) {
+			return respondError(c, fiber.StatusForbidden, "Bạn không có quyền khôi phục bài viết này", backURL)
+		}
+
+		revisionID, err := strconv.Atoi(c.Params("revisionId"))
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "Phiên bản không hợp lệ", backURL)
+		}
+
+		db := database.Get()
+		var revision models.PostRevision
+		if err := db.Where("post_id = ?", post.ID).First(&revision, revisionID).Error; err != nil {
+			return respondError(c, fiber.StatusNotFound, "Phiên bản không tồn tại", backURL)
+		}
+
+		oldContent := post.Content
+		err = db.Transaction(func(tx *gorm.DB) error {
+			post.Title = revision.Title
+			post.Summary = revision.Summary
+			post.Content = revision.Content
+			post.Tags = revision.Tags
+			if err := assignPostSlug(tx, post); err != nil {
+				return err
+			}
+			if err := tx.Save(post).Error; err != nil {
+				return err
+			}
+			if err := syncPostTags(tx, post, revision.Tags); err != nil {
+				return err
+			}
+			if err := replaceLineAnnotations(tx, post, oldContent, revisionAnnotations(revision)); err != nil {
+				return err
+			}
+			return snapshotPost(tx, post, userID)
+		})
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể khôi phục phiên bản", backURL)
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{
+				"message":  fmt.Sprintf("Đã khôi phục phiên bản #%d", revision.Number),
+				"post_id":  post.ID,
+				"restored": revision.Number,
+			})
+		}
+
+		setFlash(c, "success", fmt.Sprintf("Đã khôi phục phiên bản #%d", revision.Number))
+		return c.Status(fiber.StatusSeeOther).Redirect(postPath(*post))
+	}
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/microcosm-cc/bluemonday"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+)
+
+// Loại kết quả tìm kiếm.
+const (
+	searchTypePost = "post"
+	searchTypeBook = "book"
+	searchTypePage = "page"
+)
+
+// snippetPolicy chỉ giữ lại thẻ <mark> do ts_headline chèn vào.
+var snippetPolicy = bluemonday.NewPolicy().AllowElements("mark")
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" … \""
+
+type searchFilters struct {
+	Query    string
+	Types    map[string]bool
+	TagID    uint
+	AuthorID uint
+	Limit    int
+	Offset   int
+}
+
+type searchResult struct {
+	Type       string  `json:"type"`
+	ID         uint    `json:"id"`
+	BookID     uint    `json:"book_id,omitempty"`
+	Title      string  `json:"title"`
+	URL        string  `json:"url"`
+	Snippet    string  `json:"snippet"`
+	Rank       float64 `json:"rank"`
+	AuthorID   uint    `json:"author_id"`
+	AuthorName string  `json:"author_name"`
+}
+
+type searchRow struct {
+	Type       string
+	ID         uint
+	BookID     uint
+	Slug       string
+	Title      string
+	Snippet    string
+	Rank       float64
+	AuthorID   uint
+	AuthorName string
+}
+
+func parseSearchFilters(c *fiber.Ctx) (searchFilters, string) {
+	filters := searchFilters{
+		Query: strings.TrimSpace(c.Query("q")),
+		Types: make(map[string]bool),
+	}
+
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		switch t = strings.TrimSpace(strings.ToLower(t)); t {
+		case searchTypePost, searchTypeBook, searchTypePage:
+			filters.Types[t] = true
+		}
+	}
+	if len(filters.Types) == 0 {
+		filters.Types = map[string]bool{searchTypePost: true, searchTypeBook: true, searchTypePage: true}
+	}
+
+	tagName := ""
+	if raw := strings.TrimSpace(c.Query("tag")); raw != "" {
+		if tag, err := lookupTag(database.Get(), raw); err == nil {
+			filters.TagID = tag.ID
+			tagName = tag.Name
+		} else {
+			// Tag không tồn tại thì không có kết quả nào khớp
+			filters.TagID = math.MaxUint32
+			tagName = normalizeTagName(raw)
+		}
+	}
+	if author, err := strconv.Atoi(c.Query("author")); err == nil && author > 0 {
+		filters.AuthorID = uint(author)
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	filters.Limit, _ = strconv.Atoi(c.Query("limit", "20"))
+	if filters.Limit < 1 || filters.Limit > 100 {
+		filters.Limit = 20
+	}
+	filters.Offset = (page - 1) * filters.Limit
+	return filters, tagName
+}
+
+// searchExpr là các biểu thức SQL khớp, xếp hạng và trích đoạn cho một loại nội dung.
+type searchExpr struct {
+	Match, Rank, Snippet             string
+	MatchArgs, RankArgs, SnippetArgs []interface{}
+}
+
+// textSearchExpr dùng full-text search trên cột search_vector của bảng alias; nếu cơ sở dữ liệu
+// chưa có cột này (thiếu extension unaccent...) thì khớp ILIKE trên các cột columns,
+// ưu tiên kết quả khớp tiêu đề và trích đoạn là phần đầu của text.
+func textSearchExpr(query, alias, text string, columns ...string) searchExpr {
+	if database.FullTextSearchReady() {
+		tsquery := "websearch_to_tsquery('" + database.SearchConfig + "', ?)"
+		return searchExpr{
+			Match:       alias + ".search_vector @@ " + tsquery,
+			Rank:        "ts_rank(" + alias + ".search_vector, " + tsquery + ")",
+			Snippet:     "ts_headline('" + database.SearchConfig + "', " + text + ", " + tsquery + ", '" + headlineOptions + "')",
+			MatchArgs:   []interface{}{query},
+			RankArgs:    []interface{}{query},
+			SnippetArgs: []interface{}{query},
+		}
+	}
+	match, args := likeMatch(query, columns...)
+	return searchExpr{
+		Match:     match,
+		Rank:      "CASE WHEN " + columns[0] + ` ILIKE ? ESCAPE '\' THEN 1 ELSE 0 END`,
+		Snippet:   "left(" + text + ", 200)",
+		MatchArgs: args,
+		RankArgs:  []interface{}{"%" + escapeLike(query) + "%"},
+	}
+}
+
+// likeMatch khớp query ở bất kỳ vị trí nào trong một trong các cột, không phân biệt hoa thường.
+func likeMatch(query string, columns ...string) (string, []interface{}) {
+	like := "%" + escapeLike(query) + "%"
+	conds := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conds[i] = column + ` ILIKE ? ESCAPE '\'`
+		args[i] = like
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// searchQuery ghép các truy vấn con cho từng loại nội dung thành một UNION ALL xếp hạng.
+func searchQuery(f searchFilters) (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+
+	if f.Types[searchTypePost] {
+		e := textSearchExpr(f.Query, "p", `regexp_replace(coalesce(p.summary, '') || ' ' || coalesce(p.content, ''), '<[^>]+>', ' ', 'g')`,
+			"p.title", "p.summary", "p.tags", "p.content")
+		sql := `SELECT 'post' AS type, p.id AS id, 0 AS book_id, p.slug AS slug, p.title AS title,
+			` + e.Snippet + ` AS snippet,
+			` + e.Rank + ` AS rank, p.author_id AS author_id, coalesce(u.name, '') AS author_name
+			FROM posts p LEFT JOIN users u ON u.id = p.author_id
+			WHERE p.deleted_at IS NULL AND p.status = ? AND NOT p.hidden AND ` + e.Match
+		partArgs := append(append(append([]interface{}{}, e.SnippetArgs...), e.RankArgs...), models.PostStatusPublished)
+		partArgs = append(partArgs, e.MatchArgs...)
+		if f.TagID != 0 {
+			sql += ` AND p.id IN (SELECT post_id FROM post_tags WHERE tag_id = ?)`
+			partArgs = append(partArgs, f.TagID)
+		}
+		if f.AuthorID != 0 {
+			sql += ` AND p.author_id = ?`
+			partArgs = append(partArgs, f.AuthorID)
+		}
+		parts = append(parts, sql)
+		args = append(args, partArgs...)
+	}
+
+	if f.Types[searchTypeBook] {
+		e := textSearchExpr(f.Query, "b", `coalesce(b.description, '')`,
+			"b.title", "b.description", "b.book_tag", "b.book_category")
+		sql := `SELECT 'book' AS type, b.id AS id, b.id AS book_id, b.slug AS slug, b.title AS title,
+			` + e.Snippet + ` AS snippet,
+			` + e.Rank + ` AS rank, b.author_id AS author_id, coalesce(u.name, '') AS author_name
+			FROM books b LEFT JOIN users u ON u.id = b.author_id
+			WHERE b.deleted_at IS NULL AND b.published = true AND NOT b.hidden AND ` + e.Match
+		partArgs := append(append(append([]interface{}{}, e.SnippetArgs...), e.RankArgs...), e.MatchArgs...)
+		if f.TagID != 0 {
+			sql += ` AND b.id IN (SELECT book_id FROM book_tags WHERE tag_id = ?)`
+			partArgs = append(partArgs, f.TagID)
+		}
+		if f.AuthorID != 0 {
+			sql += ` AND b.author_id = ?`
+			partArgs = append(partArgs, f.AuthorID)
+		}
+		parts = append(parts, sql)
+		args = append(args, partArgs...)
+	}
+
+	if f.Types[searchTypePage] {
+		e := textSearchExpr(f.Query, "bp", `regexp_replace(coalesce(bp.content, ''), '<[^>]+>', ' ', 'g')`,
+			"bp.title", "bp.content")
+		sql := `SELECT 'page' AS type, bp.id AS id, b.id AS book_id, b.slug AS slug, b.title || ' · ' || coalesce(nullif(bp.title, ''), 'Trang ' || bp.page_number) AS title,
+			` + e.Snippet + ` AS snippet,
+			` + e.Rank + ` AS rank, b.author_id AS author_id, coalesce(u.name, '') AS author_name
+			FROM book_pages bp JOIN books b ON b.id = bp.book_id LEFT JOIN users u ON u.id = b.author_id
+			WHERE bp.deleted_at IS NULL AND b.deleted_at IS NULL AND b.published = true AND NOT b.hidden AND ` + e.Match
+		partArgs := append(append(append([]interface{}{}, e.SnippetArgs...), e.RankArgs...), e.MatchArgs...)
+		if f.TagID != 0 {
+			sql += ` AND b.id IN (SELECT book_id FROM book_tags WHERE tag_id = ?)`
+			partArgs = append(partArgs, f.TagID)
+		}
+		if f.AuthorID != 0 {
+			sql += ` AND b.author_id = ?`
+			partArgs = append(partArgs, f.AuthorID)
+		}
+		parts = append(parts, sql)
+		args = append(args, partArgs...)
+	}
+
+	return strings.Join(parts, " UNION ALL "), args
+}
+
+// runSearch thực thi truy vấn full-text và trả về kết quả đã xếp hạng cùng tổng số kết quả.
+func runSearch(db *gorm.DB, f searchFilters) ([]searchResult, int64, error) {
+	results := make([]searchResult, 0)
+	if f.Query == "" {
+		return results, 0, nil
+	}
+
+	union, args := searchQuery(f)
+
+	var total int64
+	if err := db.Raw("SELECT COUNT(*) FROM ("+union+") AS results", args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []searchRow
+	pageArgs := append(append([]interface{}{}, args...), f.Limit, f.Offset)
+	if err := db.Raw("SELECT * FROM ("+union+") AS results ORDER BY rank DESC, id DESC LIMIT ? OFFSET ?", pageArgs...).Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	for _, row := range rows {
+		result := searchResult{
+			Type:       row.Type,
+			ID:         row.ID,
+			Title:      row.Title,
+			Snippet:    snippetPolicy.Sanitize(row.Snippet),
+			Rank:       row.Rank,
+			AuthorID:   row.AuthorID,
+			AuthorName: row.AuthorName,
+		}
+		switch row.Type {
+		case searchTypePost:
+			result.URL = postPath(models.Post{Model: gorm.Model{ID: row.ID}, Slug: row.Slug})
+		case searchTypeBook:
+			result.URL = bookPath(models.Book{ID: row.ID, Slug: row.Slug})
+		case searchTypePage:
+			result.BookID = row.BookID
+			result.URL = bookPath(models.Book{ID: row.BookID, Slug: row.Slug}) + "/read?page=" + strconv.FormatUint(uint64(row.ID), 10)
+		}
+		results = append(results, result)
+	}
+	return results, total, nil
+}
+
+// SearchPage tìm kiếm toàn văn trên bài viết, sách và trang sách.
+func SearchPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filters, tagName := parseSearchFilters(c)
+		results, total, err := runSearch(database.Get(), filters)
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tìm kiếm", "/")
+		}
+
+		page := filters.Offset/filters.Limit + 1
+		totalPages := int(math.Ceil(float64(total) / float64(filters.Limit)))
+		types := make([]string, 0, len(filters.Types))
+		for _, t := range []string{searchTypePost, searchTypeBook, searchTypePage} {
+			if filters.Types[t] {
+				types = append(types, t)
+			}
+		}
+
+		return render(c, "pages/search", fiber.Map{
+			"Title":      "Tìm kiếm",
+			"Query":      filters.Query,
+			"Types":      filters.Types,
+			"TypeParam":  strings.Join(types, ","),
+			"Tag":        tagName,
+			"AuthorID":   filters.AuthorID,
+			"Results":    results,
+			"Total":      total,
+			"Page":       page,
+			"HasPrev":    page > 1,
+			"HasNext":    page < totalPages,
+			"PrevPage":   page - 1,
+			"NextPage":   page + 1,
+			"TotalPages": totalPages,
+		}, "main")
+	}
+}
+
+// SearchAPI là phiên bản JSON của /search.
+func SearchAPI() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filters, _ := parseSearchFilters(c)
+		if filters.Query == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Query parameter 'q' is required"})
+		}
+
+		results, total, err := runSearch(database.Get(), filters)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Lỗi tìm kiếm"})
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"results": results,
+			"total":   total,
+			"page":    filters.Offset/filters.Limit + 1,
+			"limit":   filters.Limit,
+			"query":   filters.Query,
+		})
+	}
+}
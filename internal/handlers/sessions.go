@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/sessionstore"
+)
+
+// sessionTouchInterval giới hạn tần suất ghi "lần cuối hoạt động" để không ghi DB ở mọi request.
+const sessionTouchInterval = time.Minute
+
+// ConfigureSessions thay session store mặc định (bộ nhớ) bằng storage đã chọn.
+// storage == nil giữ lại storage trong bộ nhớ của Fiber.
+func ConfigureSessions(storage fiber.Storage) {
+	sessionStore = session.New(session.Config{
+		Storage:        storage,
+		Expiration:     sessionstore.Expiration(),
+		CookieHTTPOnly: true,
+		CookieSameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// recordUserSession tạo hoặc cập nhật bản ghi phiên đăng nhập của người dùng.
+func recordUserSession(db *gorm.DB, c *fiber.Ctx, sessionID string, userID uint, now time.Time) error {
+	entry := models.UserSession{
+		SessionID:  sessionID,
+		UserID:     userID,
+		UserAgent:  truncate(c.Get(fiber.HeaderUserAgent), 512),
+		IPAddress:  c.IP(),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(sessionstore.Expiration()),
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "user_agent", "ip_address", "last_seen_at", "expires_at"}),
+	}).Create(&entry).Error
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// TrackSessions cập nhật thời điểm hoạt động gần nhất của phiên đăng nhập
+// để trang quản lý phiên hiển thị đúng và job dọn phiên không xóa nhầm phiên còn dùng.
+func TrackSessions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if strings.HasPrefix(c.Path(), "/static/") {
+			return c.Next()
+		}
+		sess, err := sessionStore.Get(c)
+		if err != nil {
+			return c.Next()
+		}
+		userID, ok := sessionUserID(sess)
+		if !ok {
+			return c.Next()
+		}
+
+		now := time.Now()
+		lastSeen, _ := sess.Get("lastSeen").(int64)
+		if now.Sub(time.Unix(lastSeen, 0)) < sessionTouchInterval {
+			return c.Next()
+		}
+		if err := recordUserSession(database.Get(), c, sess.ID(), userID, now); err != nil {
+			log.Printf("failed to record session: %v", err)
+			return c.Next()
+		}
+		sess.Set("lastSeen", now.Unix())
+		_ = sess.Save()
+		return c.Next()
+	}
+}
+
+// describeUserAgent rút gọn User-Agent thành "Trình duyệt trên Hệ điều hành".
+func describeUserAgent(ua string) string {
+	if ua == "" {
+		return "Thiết bị không xác định"
+	}
+	browser := "Trình duyệt khác"
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+	system := ""
+	switch {
+	case strings.Contains(ua, "Android"):
+		system = "Android"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		system = "iOS"
+	case strings.Contains(ua, "Windows"):
+		system = "Windows"
+	case strings.Contains(ua, "Mac OS X"):
+		system = "macOS"
+	case strings.Contains(ua, "Linux"):
+		system = "Linux"
+	}
+	if system == "" {
+		return browser
+	}
+	return browser + " trên " + system
+}
+
+// currentSessionID trả về ID phiên của request hiện tại.
+func currentSessionID(c *fiber.Ctx) string {
+	sess, err := sessionStore.Get(c)
+	if err != nil {
+		return ""
+	}
+	return sess.ID()
+}
+
+// SessionsPage liệt kê các phiên đăng nhập còn hiệu lực của người dùng.
+func SessionsPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return c.Redirect("/auth/login?next=/account/sessions")
+		}
+
+		var entries []models.UserSession
+		if err := database.Get().
+			Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+			Order("last_seen_at DESC").
+			Find(&entries).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải danh sách phiên đăng nhập", "/")
+		}
+
+		currentID := currentSessionID(c)
+		items := make([]fiber.Map, 0, len(entries))
+		others := 0
+		for _, entry := range entries {
+			isCurrent := entry.SessionID == currentID
+			if !isCurrent {
+				others++
+			}
+			items = append(items, fiber.Map{
+				"ID":            entry.ID,
+				"Device":        describeUserAgent(entry.UserAgent),
+				"UserAgent":     entry.UserAgent,
+				"IPAddress":     entry.IPAddress,
+				"LastSeenLabel": formatTimeVN(entry.LastSeenAt),
+				"CreatedLabel":  formatTimeVN(entry.CreatedAt),
+				"Current":       isCurrent,
+			})
+		}
+
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"sessions": items})
+		}
+
+		return render(c, "pages/account_sessions", fiber.Map{
+			"Title":      "Phiên đăng nhập",
+			"Sessions":   items,
+			"OtherCount": others,
+		}, "main")
+	}
+}
+
+// revokeUserSessions đăng xuất mọi phiên của người dùng, trừ exceptSessionID (có thể rỗng).
+func revokeUserSessions(db *gorm.DB, userID uint, exceptSessionID string) (int, error) {
+	var entries []models.UserSession
+	if err := db.Where("user_id = ? AND session_id <> ?", userID, exceptSessionID).Find(&entries).Error; err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if err := sessionStore.Delete(entry.SessionID); err != nil {
+			return 0, err
+		}
+		if err := db.Delete(&entry).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// LogoutOtherSessions đăng xuất mọi phiên của người dùng trừ phiên hiện tại.
+func LogoutOtherSessions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login")
+		}
+
+		revoked, err := revokeUserSessions(database.Get(), userID, currentSessionID(c))
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể đăng xuất các phiên khác", "/account/sessions")
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "revoked": revoked})
+		}
+		setFlash(c, "success", "Đã đăng xuất khỏi các phiên khác")
+		return c.Status(fiber.StatusSeeOther).Redirect("/account/sessions")
+	}
+}
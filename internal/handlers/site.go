@@ -2,14 +2,14 @@ package handlers
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math"
+	"mime/multipart"
 	"net/mail"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -17,11 +17,14 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
 
+	"fiber-learning-community/internal/anchor"
 	"fiber-learning-community/internal/database"
 	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var sessionStore = session.New()
@@ -69,119 +72,27 @@ func popFlash(sess *session.Session) fiber.Map {
 	return fiber.Map{"Type": typ, "Message": msg}
 }
 
-// parseInlineAnnotations extracts inline annotations from HTML content
-// Returns map of line_number -> annotation_text
-func parseInlineAnnotations(htmlContent string) map[int]string {
-	annotations := make(map[int]string)
-	if htmlContent == "" {
-		return annotations
-	}
-
-	// Split content by common block elements
-	linePattern := regexp.MustCompile(`<(?:p|h[1-6]|li|div)[^>]*>.*?</(?:p|h[1-6]|li|div)>|[^<]+`)
-	matches := linePattern.FindAllString(htmlContent, -1)
-
-	lineNumber := 0
-	for _, match := range matches {
-		// Skip empty matches
-		if strings.TrimSpace(match) == "" {
-			continue
-		}
-
-		// Check if this is a block element with content
-		if strings.Contains(match, "<") {
-			lineNumber++
-
-			// Extract text content and look for #annotation pattern
-			// Remove HTML tags to get plain text
-			tagPattern := regexp.MustCompile(`<[^>]+>`)
-			plainText := tagPattern.ReplaceAllString(match, "")
-			plainText = strings.TrimSpace(plainText)
-
-			if plainText == "" {
-				continue
-			}
-
-			// Look for #annotation at the end of the line
-			annotationPattern := regexp.MustCompile(`\s*#([^#]+)$`)
-			if annotationMatch := annotationPattern.FindStringSubmatch(plainText); len(annotationMatch) > 1 {
-				annotationText := strings.TrimSpace(annotationMatch[1])
-				if annotationText != "" {
-					annotations[lineNumber] = annotationText
-				}
-			}
-		}
-	}
-
-	return annotations
-}
-
-// updatePostContentAnnotation updates the #annotation in post content for a specific line
-func updatePostContentAnnotation(db *gorm.DB, post *models.Post, lineNumber int, newAnnotation string) error {
-	if post.Content == "" {
-		return nil
-	}
-
-	// Split content by common block elements
-	linePattern := regexp.MustCompile(`(<(?:p|h[1-6]|li|div)[^>]*>)(.*?)(</(?:p|h[1-6]|li|div)>)`)
-	matches := linePattern.FindAllStringSubmatch(post.Content, -1)
-
-	currentLine := 0
-	updatedContent := post.Content
-
-	for _, match := range matches {
-		if len(match) < 4 {
-			continue
-		}
-
-		openTag := match[1]
-		content := match[2]
-		closeTag := match[3]
-		fullMatch := match[0]
-
-		// Skip empty content
-		tagPattern := regexp.MustCompile(`<[^>]+>`)
-		plainText := tagPattern.ReplaceAllString(content, "")
-		if strings.TrimSpace(plainText) == "" {
-			continue
-		}
-
-		currentLine++
-
-		if currentLine == lineNumber {
-			// Remove existing #annotation if any
-			annotationPattern := regexp.MustCompile(`\s*#[^#]*$`)
-			cleanContent := annotationPattern.ReplaceAllString(content, "")
-
-			// Add new annotation if provided
-			var newContent string
-			if newAnnotation != "" {
-				newContent = cleanContent + " #" + newAnnotation
-			} else {
-				newContent = cleanContent
-			}
-
-			// Replace in full content
-			newFullMatch := openTag + newContent + closeTag
-			updatedContent = strings.Replace(updatedContent, fullMatch, newFullMatch, 1)
-			break
-		}
-	}
-
-	// Save updated content
-	post.Content = updatedContent
-	return db.Save(post).Error
-}
-
 func setUserSession(c *fiber.Ctx, user models.User) error {
 	sess, err := sessionStore.Get(c)
 	if err != nil {
 		return err
 	}
+	// Cấp ID phiên mới khi đăng nhập để tránh session fixation
+	if err := sess.Regenerate(); err != nil {
+		return err
+	}
+	now := time.Now()
 	sess.Set("userID", strconv.FormatUint(uint64(user.ID), 10))
 	sess.Set("userName", user.Name)
 	sess.Set("userEmail", user.Email)
-	return sess.Save()
+	sess.Set("lastSeen", now.Unix())
+	sess.Set("emailUnverified", !user.EmailVerified())
+	sess.Set(csrfSessionKey, newCSRFToken())
+	sessionID := sess.ID()
+	if err := sess.Save(); err != nil {
+		return err
+	}
+	return recordUserSession(database.Get(), c, sessionID, user.ID, now)
 }
 
 func clearUserSession(c *fiber.Ctx) {
@@ -189,6 +100,7 @@ func clearUserSession(c *fiber.Ctx) {
 	if err != nil {
 		return
 	}
+	database.Get().Where("session_id = ?", sess.ID()).Delete(&models.UserSession{})
 	_ = sess.Destroy()
 }
 
@@ -203,27 +115,44 @@ func sessionUser(sess *session.Session) fiber.Map {
 	}
 	name, _ := sess.Get("userName").(string)
 	email, _ := sess.Get("userEmail").(string)
+	unverified, _ := sess.Get("emailUnverified").(bool)
 	return fiber.Map{
-		"ID":    uint(id),
-		"Name":  name,
-		"Email": email,
+		"ID":            uint(id),
+		"Name":          name,
+		"Email":         email,
+		"EmailVerified": !unverified,
 	}
 }
 
-func currentUserID(c *fiber.Ctx) (uint, error) {
-	sess, err := sessionStore.Get(c)
-	if err != nil {
-		return 0, err
-	}
+func sessionUserID(sess *session.Session) (uint, bool) {
 	idStr, _ := sess.Get("userID").(string)
 	if idStr == "" {
-		return 0, fiber.ErrUnauthorized
+		return 0, false
 	}
 	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func currentUserID(c *fiber.Ctx) (uint, error) {
+	if id, ok := tokenUserID(c); ok {
+		return id, nil
+	}
+	// Request kèm token mà token không dùng được cho route này thì không rơi về phiên cookie
+	if requestAPIToken(c) != nil {
+		return 0, fiber.ErrUnauthorized
+	}
+	sess, err := sessionStore.Get(c)
 	if err != nil {
 		return 0, err
 	}
-	return uint(id), nil
+	id, ok := sessionUserID(sess)
+	if !ok {
+		return 0, fiber.ErrUnauthorized
+	}
+	return id, nil
 }
 
 func respondError(c *fiber.Ctx, status int, message, redirect string) error {
@@ -244,18 +173,27 @@ func render(c *fiber.Ctx, view string, data fiber.Map, layout string) error {
 		"IsAuthenticated": false,
 		"RequestPath":     c.OriginalURL(),
 		"RequestRoute":    c.Path(),
+		"OAuthProviders":  oauthProviderLinks(),
 	}
 
 	sess, err := sessionStore.Get(c)
 	if err == nil {
+		dirty := false
 		if flash := popFlash(sess); flash != nil {
 			base["Flash"] = flash
+			dirty = true
 		}
 		if user := sessionUser(sess); user != nil {
 			base["CurrentUser"] = user
 			base["IsAuthenticated"] = true
+			base["IsAdmin"] = policy.IsAdmin(currentUser(c))
+		}
+		token, minted := csrfToken(c, sess)
+		base["CSRFToken"] = token
+		// Chỉ ghi phiên khi có thay đổi: khách xem trang không tạo bản ghi phiên rỗng trong store
+		if dirty || minted {
+			_ = sess.Save()
 		}
-		_ = sess.Save()
 	}
 
 	for k, v := range data {
@@ -274,7 +212,7 @@ func Home() fiber.Handler {
 		db := database.Get()
 		var posts []models.Post
 		latestPosts := []fiber.Map{}
-		if err := db.Preload("Author").Order("created_at DESC").Limit(6).Find(&posts).Error; err == nil {
+		if err := publishedPosts(db).Preload("Author").Order(postOrder).Limit(6).Find(&posts).Error; err == nil {
 			for _, p := range posts {
 				postTags := []string{}
 				if strings.TrimSpace(p.Tags) != "" {
@@ -288,6 +226,7 @@ func Home() fiber.Handler {
 				}
 				latestPosts = append(latestPosts, fiber.Map{
 					"ID":           p.ID,
+					"URL":          postPath(p),
 					"Title":        p.Title,
 					"Summary":      p.Summary,
 					"CoverURL":     p.CoverURL,
@@ -301,7 +240,7 @@ func Home() fiber.Handler {
 		// Get 6 random published books
 		var books []models.Book
 		randomBooks := []fiber.Map{}
-		if err := db.Preload("Author").Where("published = ?", true).Order("RANDOM()").Limit(6).Find(&books).Error; err == nil {
+		if err := policy.VisibleBooks(db, nil).Preload("Author").Order("RANDOM()").Limit(6).Find(&books).Error; err == nil {
 			for _, b := range books {
 				randomBooks = append(randomBooks, fiber.Map{
 					"ID":           b.ID,
@@ -366,27 +305,31 @@ func Home() fiber.Handler {
 					"Summary": "Checklist kiểm thử Terraform và cách tích hợp Terratest vào pipeline CI.",
 				},
 			},
-			"LatestPosts": latestPosts,
-			"RandomBooks": randomBooks,
+			"LatestPosts":  latestPosts,
+			"RandomBooks":  randomBooks,
+			"ReadingShelf": readingShelf(db, currentUser(c), readingShelfLimit),
 		}, "main")
 	}
 }
 
 type createPostRequest struct {
-	Title           string `json:"title"`
-	Summary         string `json:"summary"`
-	Content         string `json:"content"`
-	ContentEncoded  string `json:"content_encoded"` // Base64 encoded content to bypass WAF
-	CoverURL        string `json:"cover_url"`
-	Tags            string `json:"tags"`
-	AuthorID        uint   `json:"author_id"`
-	LineAnnotations string `json:"line_annotations"` // JSON string: {"1": "notice text", "2": "another notice"}
+	Title            string `json:"title"`
+	Summary          string `json:"summary"`
+	Content          string `json:"content"`
+	ContentEncoded   string `json:"content_encoded"` // Base64 encoded content to bypass WAF
+	CoverURL         string `json:"cover_url"`
+	Tags             string `json:"tags"`
+	Status           string `json:"status"`            // draft | scheduled | published
+	PublishAt        string `json:"publish_at"`        // RFC3339 hoặc "2006-01-02T15:04" (giờ Việt Nam)
+	LineAnnotations  string `json:"line_annotations"`  // JSON string: {"1": "notice text", "2": "another notice"}
+	AnnotationQuotes string `json:"annotation_quotes"` // JSON string: {"1": "nội dung dòng 1"}, giúp server xác định đúng dòng
 }
 
 type createCommentRequest struct {
 	Content    string `json:"content"`
-	AuthorID   uint   `json:"author_id"`
 	LineNumber *int   `json:"line_number"`
+	ParentID   *uint  `json:"parent_id"`
+	Quote      string `json:"quote"` // Nội dung dòng được bình luận
 }
 
 func PostsPage() fiber.Handler {
@@ -395,8 +338,10 @@ func PostsPage() fiber.Handler {
 		createMode := c.Query("create") == "true"
 		if createMode {
 			// Check if user is authenticated
-			userID, _ := currentUserID(c)
-			if userID > 0 {
+			if user := currentUser(c); user != nil {
+				if !can(user, policy.Create, policy.Posts) {
+					return respondError(c, fiber.StatusForbidden, "Tài khoản của bạn không có quyền đăng bài viết", "/posts")
+				}
 				return render(c, "pages/posts_create_smart", fiber.Map{
 					"Title": "Tạo bài viết mới",
 				}, "main")
@@ -415,14 +360,20 @@ func PostsPage() fiber.Handler {
 		const pageSize = 10
 		offset := (page - 1) * pageSize
 
-		builder := db.Model(&models.Post{}).Preload("Author").Order("created_at DESC")
+		builder := publishedPosts(db.Model(&models.Post{})).Preload("Author")
 		if query != "" {
-			like := fmt.Sprintf("%%%s%%", query)
-			builder = builder.Where("title LIKE ? OR summary LIKE ?", like, like)
+			e := textSearchExpr(query, "posts", "", "posts.title", "posts.summary", "posts.tags", "posts.content")
+			builder = builder.Where(e.Match, e.MatchArgs...).
+				Order(clause.Expr{SQL: e.Rank + " DESC", Vars: e.RankArgs})
 		}
+		builder = builder.Order(postOrder)
 		if selectedTag != "" {
-			tagLike := fmt.Sprintf("%%%s%%", selectedTag)
-			builder = builder.Where("tags LIKE ?", tagLike)
+			if tag, err := lookupTag(db, selectedTag); err == nil {
+				selectedTag = tag.Name
+				builder = postsWithTag(builder, tag.ID)
+			} else {
+				builder = builder.Where("1 = 0")
+			}
 		}
 
 		var total int64
@@ -449,6 +400,7 @@ func PostsPage() fiber.Handler {
 
 			items = append(items, fiber.Map{
 				"ID":           p.ID,
+				"URL":          postPath(p),
 				"Title":        p.Title,
 				"Summary":      p.Summary,
 				"Content":      p.Content,
@@ -468,25 +420,33 @@ func PostsPage() fiber.Handler {
 			pages[i] = i + 1
 		}
 
-		// Get all unique tags
-		var allPosts []models.Post
-		db.Select("tags").Find(&allPosts)
-		tagSet := make(map[string]bool)
-		for _, p := range allPosts {
-			if p.Tags != "" {
-				tags := strings.Split(p.Tags, ",")
-				for _, tag := range tags {
-					tag = strings.TrimSpace(tag)
-					if tag != "" {
-						tagSet[tag] = true
+		// Tag cloud tính trực tiếp từ bảng post_tags
+		allTags := make([]string, 0)
+		for _, tc := range popularPostTags(db, 30) {
+			allTags = append(allTags, tc.Name)
+		}
+
+		// Bản nháp và bài đã lên lịch chỉ hiển thị cho chính tác giả
+		myDrafts := []fiber.Map{}
+		if userID, err := currentUserID(c); err == nil {
+			var drafts []models.Post
+			if err := db.Where("author_id = ? AND status <> ?", userID, models.PostStatusPublished).Order("updated_at DESC").Find(&drafts).Error; err == nil {
+				for _, d := range drafts {
+					draft := fiber.Map{
+						"ID":           d.ID,
+						"URL":          postPath(d),
+						"Title":        d.Title,
+						"Status":       d.Status,
+						"StatusLabel":  postStatusLabel(d.Status),
+						"UpdatedLabel": formatTimeVN(d.UpdatedAt),
 					}
+					if d.PublishAt != nil {
+						draft["PublishLabel"] = formatTimeVN(*d.PublishAt)
+					}
+					myDrafts = append(myDrafts, draft)
 				}
 			}
 		}
-		allTags := make([]string, 0, len(tagSet))
-		for tag := range tagSet {
-			allTags = append(allTags, tag)
-		}
 
 		return render(c, "pages/posts", fiber.Map{
 			"Title":       "Bài viết cộng đồng",
@@ -494,6 +454,7 @@ func PostsPage() fiber.Handler {
 			"Query":       query,
 			"SelectedTag": selectedTag,
 			"AllTags":     allTags,
+			"MyDrafts":    myDrafts,
 			"Page":        page,
 			"TotalPages":  totalPages,
 			"HasPrev":     page > 1,
@@ -515,12 +476,15 @@ func PostPreviewPage() fiber.Handler {
 
 func PostDetailPage() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		postID, err := strconv.Atoi(c.Params("id"))
+		db := database.Get()
+		postID, canonical, err := resolveSlugParam(db, models.SlugResourcePost, c.Params("slug"))
 		if err != nil {
-			return respondError(c, fiber.StatusBadRequest, "ID bài viết không hợp lệ", "/posts")
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return respondError(c, fiber.StatusNotFound, "Bài viết không tồn tại", "/posts")
+			}
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/posts")
 		}
 
-		db := database.Get()
 		var post models.Post
 		if err := db.Preload("Author").Preload("Comments", func(tx *gorm.DB) *gorm.DB {
 			return tx.Preload("Author").Order("created_at ASC")
@@ -531,26 +495,20 @@ func PostDetailPage() fiber.Handler {
 			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/posts")
 		}
 
-		lineComments := make(map[int][]fiber.Map)
-		generalComments := make([]fiber.Map, 0)
+		user := currentUser(c)
+		if !can(user, policy.View, post) {
+			return respondError(c, fiber.StatusNotFound, "Bài viết không tồn tại", "/posts")
+		}
 
-		for _, cm := range post.Comments {
-			comment := fiber.Map{
-				"ID":         cm.ID,
-				"Content":    cm.Content,
-				"AuthorName": cm.Author.Name,
-				"CreatedAt":  formatTimeVN(cm.CreatedAt),
-			}
-			if cm.LineNumber != nil {
-				lineComments[*cm.LineNumber] = append(lineComments[*cm.LineNumber], comment)
-			} else {
-				generalComments = append(generalComments, comment)
-			}
+		if !canonical && post.Slug != "" {
+			return redirectCanonical(c, postPath(post))
 		}
 
+		generalComments, lineComments := buildCommentThreads(post.Comments, user)
+
 		// Load annotations
 		var annotations []models.Annotation
-		result := db.Where("post_id = ?", post.ID).Find(&annotations)
+		result := db.Where("post_id = ? AND orphaned = ?", post.ID, false).Find(&annotations)
 		fmt.Printf("Query executed, found %d rows\n", result.RowsAffected)
 
 		lineAnnotations := make(map[int]string)
@@ -567,9 +525,18 @@ func PostDetailPage() fiber.Handler {
 			fmt.Printf("Line %d: %s\n", line, content)
 		}
 
-		// Check if current user is author
-		userID, _ := currentUserID(c)
-		isAuthor := userID == post.AuthorID
+		// Tác giả và ban quản trị được sửa bài, ghim lại ghi chú mồ côi
+		isAuthor := can(user, policy.Edit, post)
+
+		// Ghi chú mồ côi chỉ hiển thị cho tác giả để ghim lại
+		var orphaned []fiber.Map
+		var lineOptions []fiber.Map
+		if isAuthor {
+			orphaned = orphanedNotes(db, post.ID)
+			if len(orphaned) > 0 {
+				lineOptions = postLineOptions(post.Content)
+			}
+		}
 
 		// Parse tags
 		postTags := []string{}
@@ -594,6 +561,7 @@ func PostDetailPage() fiber.Handler {
 			"Title": "Chi tiết bài viết",
 			"Post": fiber.Map{
 				"ID":           post.ID,
+				"URL":          postPath(post),
 				"Title":        post.Title,
 				"Summary":      post.Summary,
 				"Content":      post.Content,
@@ -601,13 +569,23 @@ func PostDetailPage() fiber.Handler {
 				"Tags":         post.Tags,
 				"AuthorName":   post.Author.Name,
 				"AuthorID":     post.AuthorID,
+				"AuthorURL":    profilePath(post.Author),
 				"CreatedLabel": formatTimeVN(post.CreatedAt),
+				"Status":       post.Status,
+				"StatusLabel":  postStatusLabel(post.Status),
+				"IsPublished":  post.IsPublished(),
+				"PublishAt":    publishAtInput(post.PublishAt),
+				"Hidden":       post.Hidden,
 			},
 			"PostTags":        postTags,
 			"LineComments":    lineComments,
 			"GeneralComments": generalComments,
 			"LineAnnotations": lineAnnotations,
+			"LineQuotes":      lineQuotes(post.Content),
+			"OrphanedNotes":   orphaned,
+			"LineOptions":     lineOptions,
 			"IsAuthor":        isAuthor,
+			"CanModerate":     can(user, policy.Hide, post),
 		}, "main")
 	}
 }
@@ -661,7 +639,10 @@ func CreatePost() fiber.Handler {
 			body.Content = c.FormValue("content")
 			body.CoverURL = c.FormValue("cover_url")
 			body.Tags = c.FormValue("tags")
+			body.Status = c.FormValue("status")
+			body.PublishAt = c.FormValue("publish_at")
 			body.LineAnnotations = c.FormValue("line_annotations")
+			body.AnnotationQuotes = c.FormValue("annotation_quotes")
 		}
 
 		body.Title = strings.TrimSpace(body.Title)
@@ -678,16 +659,16 @@ func CreatePost() fiber.Handler {
 			}
 		}
 
-		if !isJSON {
-			authorID, err := currentUserID(c)
-			if err != nil {
-				return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để tạo bài viết", "/auth/login")
-			}
-			body.AuthorID = authorID
-		} else if body.AuthorID == 0 {
-			if authorID, err := currentUserID(c); err == nil {
-				body.AuthorID = authorID
-			}
+		// Tác giả luôn là người đang đăng nhập, không nhận author_id từ payload
+		author := currentUser(c)
+		if author == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để tạo bài viết", "/auth/login")
+		}
+		if !can(author, policy.Create, policy.Posts) {
+			return respondError(c, fiber.StatusForbidden, "Tài khoản của bạn không có quyền đăng bài viết", "/posts")
+		}
+		if ok, err := requireVerifiedEmail(c, author.ID, "/posts"); !ok {
+			return err
 		}
 
 		if len(body.Title) < 3 {
@@ -704,36 +685,41 @@ func CreatePost() fiber.Handler {
 			return respondError(c, fiber.StatusBadRequest, "Nội dung phải từ 10 ký tự", "/posts")
 		}
 
-		if body.AuthorID == 0 {
+		tags, err := normalizePostTags(body.Tags)
+		if err != nil {
 			if isJSON {
-				return fiber.NewError(fiber.StatusBadRequest, "thiếu thông tin tác giả")
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
 			}
-			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để tạo bài viết", "/auth/login")
+			return respondError(c, fiber.StatusBadRequest, fmt.Sprintf("Mỗi bài viết chỉ được gắn tối đa %d tag", maxPostTags), "/posts")
 		}
+		body.Tags = tags
 
-		db := database.Get()
-
-		var author models.User
-		if err := db.First(&author, body.AuthorID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				if isJSON {
-					return fiber.NewError(fiber.StatusBadRequest, "tác giả không tồn tại")
-				}
-				return respondError(c, fiber.StatusBadRequest, "Người dùng không tồn tại", "/posts")
-			}
+		status, publishAt, err := parsePostStatus(body.Status, body.PublishAt)
+		if err != nil {
 			if isJSON {
-				return fiber.NewError(fiber.StatusInternalServerError, "không thể kiểm tra tác giả")
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
 			}
-			return respondError(c, fiber.StatusInternalServerError, "Không thể kiểm tra tác giả", "/posts")
+			return respondError(c, fiber.StatusBadRequest, "Trạng thái hoặc thời gian xuất bản không hợp lệ", "/posts")
 		}
 
+		db := database.Get()
+
 		post := models.Post{
-			Title:    body.Title,
-			Summary:  body.Summary,
-			Content:  body.Content,
-			CoverURL: body.CoverURL,
-			Tags:     body.Tags,
-			AuthorID: body.AuthorID,
+			Title:     body.Title,
+			Summary:   body.Summary,
+			Content:   body.Content,
+			CoverURL:  body.CoverURL,
+			Tags:      body.Tags,
+			Status:    status,
+			PublishAt: publishAt,
+			AuthorID:  author.ID,
+		}
+
+		if err := assignPostSlug(db, &post); err != nil {
+			if isJSON {
+				return fiber.NewError(fiber.StatusInternalServerError, "không thể tạo đường dẫn bài viết")
+			}
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tạo đường dẫn bài viết", "/posts")
 		}
 
 		if err := db.Create(&post).Error; err != nil {
@@ -745,47 +731,49 @@ func CreatePost() fiber.Handler {
 
 		// Create annotations from line_annotations JSON field
 		if body.LineAnnotations != "" {
-			var annotationsMap map[string]string
-			if err := json.Unmarshal([]byte(body.LineAnnotations), &annotationsMap); err == nil {
-				for lineNumStr, annotationText := range annotationsMap {
-					lineNum, err := strconv.Atoi(lineNumStr)
-					if err != nil {
-						continue
-					}
-					annotation := models.Annotation{
-						PostID:     &[]uint{post.ID}[0],
-						LineNumber: lineNum,
-						Content:    strings.TrimSpace(annotationText),
-					}
-					if err := db.Create(&annotation).Error; err != nil {
-						fmt.Printf("Warning: Failed to create annotation for line %d: %v\n", lineNum, err)
-					}
-				}
-			} else {
+			annotationsMap, err := parseLineMap(body.LineAnnotations)
+			if err != nil {
 				fmt.Printf("Warning: Failed to parse line_annotations JSON: %v\n", err)
+			} else {
+				quotes, _ := parseLineMap(body.AnnotationQuotes)
+				if err := syncLineAnnotations(db, &post, "", annotationsMap, quotes); err != nil {
+					fmt.Printf("Warning: Failed to create annotations for post %d: %v\n", post.ID, err)
+				}
 			}
 		}
 
+		if err := syncPostTags(db, &post, body.Tags); err != nil {
+			fmt.Printf("Warning: Failed to sync tags for post %d: %v\n", post.ID, err)
+		}
+
+		if err := snapshotPost(db, &post, author.ID); err != nil {
+			fmt.Printf("Warning: Failed to snapshot post %d: %v\n", post.ID, err)
+		}
+
 		if isJSON {
 			return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 				"message": "Tạo bài viết thành công",
 				"post": fiber.Map{
 					"id":         post.ID,
+					"slug":       post.Slug,
+					"url":        postPath(post),
 					"title":      post.Title,
 					"summary":    post.Summary,
 					"content":    post.Content,
 					"author_id":  post.AuthorID,
+					"status":     post.Status,
+					"publish_at": post.PublishAt,
 					"created_at": post.CreatedAt,
 				},
 			})
 		}
 
 		setFlash(c, "success", "Bài viết đã được tạo thành công")
-		return c.Status(fiber.StatusSeeOther).Redirect(fmt.Sprintf("/posts/%d", post.ID))
+		return c.Status(fiber.StatusSeeOther).Redirect(postPath(post))
 	}
 }
 
-// UpdatePost cho phép tác giả chỉnh sửa bài viết của mình.
+// UpdatePost cho phép tác giả (hoặc ban quản trị) chỉnh sửa bài viết.
 func UpdatePost() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		postID, err := strconv.Atoi(c.Params("id"))
@@ -802,13 +790,16 @@ func UpdatePost() fiber.Handler {
 		}
 
 		var req struct {
-			Title           string `json:"title"`
-			Summary         string `json:"summary"`
-			Content         string `json:"content"`
-			ContentEncoded  string `json:"content_encoded"` // Base64 encoded content to bypass WAF
-			CoverURL        string `json:"cover_url"`
-			Tags            string `json:"tags"`
-			LineAnnotations string `json:"line_annotations"`
+			Title            string `json:"title"`
+			Summary          string `json:"summary"`
+			Content          string `json:"content"`
+			ContentEncoded   string `json:"content_encoded"` // Base64 encoded content to bypass WAF
+			CoverURL         string `json:"cover_url"`
+			Tags             string `json:"tags"`
+			Status           string `json:"status"`
+			PublishAt        string `json:"publish_at"`
+			LineAnnotations  string `json:"line_annotations"`
+			AnnotationQuotes string `json:"annotation_quotes"`
 		}
 
 		isJSON := isJSONRequest(c)
@@ -822,7 +813,10 @@ func UpdatePost() fiber.Handler {
 			req.Content = c.FormValue("content")
 			req.CoverURL = c.FormValue("cover_url")
 			req.Tags = c.FormValue("tags")
+			req.Status = c.FormValue("status")
+			req.PublishAt = c.FormValue("publish_at")
 			req.LineAnnotations = c.FormValue("line_annotations")
+			req.AnnotationQuotes = c.FormValue("annotation_quotes")
 		}
 
 		req.Title = strings.TrimSpace(req.Title)
@@ -853,6 +847,15 @@ func UpdatePost() fiber.Handler {
 			return respondError(c, fiber.StatusBadRequest, "Nội dung phải từ 10 ký tự", fmt.Sprintf("/posts/%d", postID))
 		}
 
+		tags, err := normalizePostTags(req.Tags)
+		if err != nil {
+			if isJSON {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			return respondError(c, fiber.StatusBadRequest, fmt.Sprintf("Mỗi bài viết chỉ được gắn tối đa %d tag", maxPostTags), fmt.Sprintf("/posts/%d", postID))
+		}
+		req.Tags = tags
+
 		db := database.Get()
 
 		var post models.Post
@@ -869,19 +872,47 @@ func UpdatePost() fiber.Handler {
 			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/posts")
 		}
 
-		if post.AuthorID != userID {
+		if !can(currentUser(c), policy.Edit, post) {
 			if isJSON {
-				return fiber.NewError(fiber.StatusForbidden, "chỉ tác giả mới được chỉnh sửa")
+				return fiber.NewError(fiber.StatusForbidden, "không có quyền chỉnh sửa bài viết")
+			}
+			return respondError(c, fiber.StatusForbidden, "Bạn không có quyền chỉnh sửa bài viết này", fmt.Sprintf("/posts/%d", postID))
+		}
+		if ok, err := requireVerifiedEmail(c, userID, fmt.Sprintf("/posts/%d", postID)); !ok {
+			return err
+		}
+
+		// Không gửi trạng thái nghĩa là giữ nguyên trạng thái hiện tại
+		if strings.TrimSpace(req.Status) != "" {
+			wasPublished := post.IsPublished()
+			status, publishAt, err := parsePostStatus(req.Status, req.PublishAt)
+			if err != nil {
+				if isJSON {
+					return fiber.NewError(fiber.StatusBadRequest, err.Error())
+				}
+				return respondError(c, fiber.StatusBadRequest, "Trạng thái hoặc thời gian xuất bản không hợp lệ", fmt.Sprintf("/posts/%d", postID))
+			}
+			post.Status = status
+			// Bài đã xuất bản từ trước thì giữ nguyên thời điểm xuất bản ban đầu
+			if !(wasPublished && status == models.PostStatusPublished) {
+				post.PublishAt = publishAt
 			}
-			return respondError(c, fiber.StatusForbidden, "Chỉ tác giả mới có thể chỉnh sửa bài viết", fmt.Sprintf("/posts/%d", postID))
 		}
 
+		oldContent := post.Content
 		post.Title = req.Title
 		post.Summary = req.Summary
 		post.Content = req.Content
 		post.CoverURL = req.CoverURL
 		post.Tags = req.Tags
 
+		if err := assignPostSlug(db, &post); err != nil {
+			if isJSON {
+				return fiber.NewError(fiber.StatusInternalServerError, "không thể cập nhật đường dẫn bài viết")
+			}
+			return respondError(c, fiber.StatusInternalServerError, "Không thể cập nhật đường dẫn bài viết", fmt.Sprintf("/posts/%d", postID))
+		}
+
 		if err := db.Save(&post).Error; err != nil {
 			if isJSON {
 				return fiber.NewError(fiber.StatusInternalServerError, "không thể cập nhật bài viết")
@@ -889,49 +920,47 @@ func UpdatePost() fiber.Handler {
 			return respondError(c, fiber.StatusInternalServerError, "Không thể cập nhật bài viết", fmt.Sprintf("/posts/%d", postID))
 		}
 
-		// Sync annotations from line_annotations JSON field
+		// Gắn lại chú thích, bình luận theo dòng vào nội dung mới và áp dụng chú thích từ editor
+		var annotationsMap map[int]string
 		if req.LineAnnotations != "" {
-			var annotationsMap map[string]string
-			if err := json.Unmarshal([]byte(req.LineAnnotations), &annotationsMap); err == nil {
-				// Delete all existing annotations for this post
-				db.Where("post_id = ?", post.ID).Delete(&models.Annotation{})
-
-				// Create new annotations from JSON
-				for lineNumStr, annotationText := range annotationsMap {
-					lineNum, err := strconv.Atoi(lineNumStr)
-					if err != nil {
-						continue
-					}
-
-					annotation := models.Annotation{
-						PostID:     &[]uint{post.ID}[0],
-						LineNumber: lineNum,
-						Content:    strings.TrimSpace(annotationText),
-					}
-					if err := db.Create(&annotation).Error; err != nil {
-						fmt.Printf("Warning: Failed to create annotation for line %d: %v\n", lineNum, err)
-					}
-				}
-			} else {
+			parsed, err := parseLineMap(req.LineAnnotations)
+			if err != nil {
 				fmt.Printf("Warning: Failed to parse line_annotations JSON: %v\n", err)
 			}
+			annotationsMap = parsed
+		}
+		quotes, _ := parseLineMap(req.AnnotationQuotes)
+		if err := syncLineAnnotations(db, &post, oldContent, annotationsMap, quotes); err != nil {
+			fmt.Printf("Warning: Failed to re-anchor notes for post %d: %v\n", post.ID, err)
+		}
+
+		if err := syncPostTags(db, &post, req.Tags); err != nil {
+			fmt.Printf("Warning: Failed to sync tags for post %d: %v\n", post.ID, err)
+		}
+
+		if err := snapshotPost(db, &post, userID); err != nil {
+			fmt.Printf("Warning: Failed to snapshot post %d: %v\n", post.ID, err)
 		}
 
 		if isJSON {
 			return c.JSON(fiber.Map{
 				"message": "Cập nhật bài viết thành công",
 				"post": fiber.Map{
-					"id":        post.ID,
-					"title":     post.Title,
-					"summary":   post.Summary,
-					"content":   post.Content,
-					"cover_url": post.CoverURL,
+					"id":         post.ID,
+					"slug":       post.Slug,
+					"url":        postPath(post),
+					"title":      post.Title,
+					"summary":    post.Summary,
+					"content":    post.Content,
+					"cover_url":  post.CoverURL,
+					"status":     post.Status,
+					"publish_at": post.PublishAt,
 				},
 			})
 		}
 
 		setFlash(c, "success", "Bài viết đã được cập nhật")
-		return c.Status(fiber.StatusSeeOther).Redirect(fmt.Sprintf("/posts/%d", post.ID))
+		return c.Status(fiber.StatusSeeOther).Redirect(postPath(post))
 	}
 }
 
@@ -957,6 +986,13 @@ func CreateComment() fiber.Handler {
 					body.LineNumber = &val
 				}
 			}
+			body.Quote = c.FormValue("quote")
+			if pid := c.FormValue("parent_id"); pid != "" {
+				if val, err := strconv.ParseUint(pid, 10, 64); err == nil && val > 0 {
+					parentID := uint(val)
+					body.ParentID = &parentID
+				}
+			}
 		}
 
 		body.Content = strings.TrimSpace(body.Content)
@@ -967,18 +1003,17 @@ func CreateComment() fiber.Handler {
 			return respondError(c, fiber.StatusBadRequest, "Bình luận phải từ 3 ký tự", fmt.Sprintf("/posts/%d", postID))
 		}
 
-		if body.AuthorID == 0 {
-			if userID, err := currentUserID(c); err == nil {
-				body.AuthorID = userID
-			}
-		}
-
-		if body.AuthorID == 0 {
-			if isJSON {
-				return fiber.NewError(fiber.StatusBadRequest, "thiếu thông tin người bình luận")
-			}
+		// Người bình luận luôn là người đang đăng nhập, không nhận author_id từ payload
+		author := currentUser(c)
+		if author == nil {
 			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để bình luận", "/auth/login")
 		}
+		if !can(author, policy.Create, policy.Comments) {
+			return respondError(c, fiber.StatusForbidden, "Tài khoản của bạn không có quyền bình luận", fmt.Sprintf("/posts/%d", postID))
+		}
+		if ok, err := requireVerifiedEmail(c, author.ID, fmt.Sprintf("/posts/%d", postID)); !ok {
+			return err
+		}
 
 		db := database.Get()
 
@@ -989,28 +1024,36 @@ func CreateComment() fiber.Handler {
 			}
 			return respondError(c, fiber.StatusInternalServerError, "Không thể truy vấn bài viết", "/posts")
 		}
-
-		var author models.User
-		if err := db.First(&author, body.AuthorID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				if isJSON {
-					return fiber.NewError(fiber.StatusBadRequest, "người dùng không tồn tại")
-				}
-				return respondError(c, fiber.StatusBadRequest, "Người dùng không tồn tại", fmt.Sprintf("/posts/%d", postID))
-			}
-			if isJSON {
-				return fiber.NewError(fiber.StatusInternalServerError, "không thể kiểm tra người dùng")
-			}
-			return respondError(c, fiber.StatusInternalServerError, "Không thể kiểm tra người dùng", fmt.Sprintf("/posts/%d", postID))
+		if !can(author, policy.View, post) {
+			return respondError(c, fiber.StatusNotFound, "Bài viết không tồn tại", "/posts")
 		}
 
 		comment := models.Comment{
 			Content:    body.Content,
 			PostID:     uint(postID),
-			AuthorID:   body.AuthorID,
+			AuthorID:   author.ID,
 			LineNumber: body.LineNumber,
 		}
 
+		if body.ParentID != nil && *body.ParentID != 0 {
+			parent, err := resolveCommentParent(db, uint(postID), *body.ParentID)
+			if err != nil {
+				if isJSON {
+					return fiber.NewError(fiber.StatusBadRequest, "bình luận cha không hợp lệ")
+				}
+				return respondError(c, fiber.StatusBadRequest, "Không thể trả lời bình luận này", fmt.Sprintf("/posts/%d", postID))
+			}
+			// Trả lời luôn nằm cùng dòng với bình luận gốc
+			comment.ParentID = &parent.ID
+			comment.Depth = parent.Depth + 1
+			comment.LineNumber = parent.LineNumber
+			comment.LineAnchor = parent.LineAnchor
+		} else if comment.LineNumber != nil {
+			line, lineAnchor := anchorNewNote(anchor.Blocks(post.Content), *comment.LineNumber, body.Quote)
+			comment.LineNumber = &line
+			comment.LineAnchor = lineAnchor
+		}
+
 		if err := db.Create(&comment).Error; err != nil {
 			if isJSON {
 				return fiber.NewError(fiber.StatusInternalServerError, "không thể tạo bình luận")
@@ -1026,14 +1069,16 @@ func CreateComment() fiber.Handler {
 					"id":          comment.ID,
 					"content":     comment.Content,
 					"author_name": authorName,
-					"line_number": body.LineNumber,
+					"line_number": comment.LineNumber,
+					"parent_id":   comment.ParentID,
+					"depth":       comment.Depth,
 					"created_at":  formatTimeVN(comment.CreatedAt),
 				},
 			})
 		}
 
 		setFlash(c, "success", "Đã thêm bình luận")
-		return c.Status(fiber.StatusSeeOther).Redirect(fmt.Sprintf("/posts/%d", postID))
+		return c.Status(fiber.StatusSeeOther).Redirect(commentAnchor(comment.PostID, comment.ID))
 	}
 }
 
@@ -1111,6 +1156,9 @@ func Register() fiber.Handler {
 			Email:        body.Email,
 			PasswordHash: string(hash),
 		}
+		if err := assignUserHandle(db, &user); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tạo tài khoản", "/auth/register")
+		}
 
 		if err := db.Create(&user).Error; err != nil {
 			if isJSON {
@@ -1119,6 +1167,10 @@ func Register() fiber.Handler {
 			return respondError(c, fiber.StatusInternalServerError, "Không thể tạo tài khoản", "/auth/register")
 		}
 
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("failed to send verification email: %v", err)
+		}
+
 		if err := setUserSession(c, user); err != nil {
 			if isJSON {
 				return fiber.NewError(fiber.StatusInternalServerError, "không thể khởi tạo phiên đăng nhập")
@@ -1137,7 +1189,7 @@ func Register() fiber.Handler {
 			})
 		}
 
-		setFlash(c, "success", "Chào mừng bạn đến với cộng đồng!")
+		setFlash(c, "success", fmt.Sprintf("Chào mừng bạn đến với cộng đồng! Hãy mở link xác minh đã gửi tới %s để bắt đầu đăng bài.", user.Email))
 		redirect := c.FormValue("next")
 		if redirect == "" || redirect == "/auth/login" || redirect == "/auth/register" {
 			redirect = "/"
@@ -1181,8 +1233,16 @@ func Login() fiber.Handler {
 			return respondError(c, fiber.StatusBadRequest, "Mật khẩu phải từ 6 ký tự", "/auth/login")
 		}
 
+		if retryAfter, locked := loginThrottled(body.Email, time.Now()); retryAfter > 0 {
+			if locked {
+				return tooManyRequests(c, retryAfter, "Tài khoản tạm khóa do đăng nhập sai nhiều lần", "/auth/login")
+			}
+			return tooManyRequests(c, retryAfter, "Bạn đã nhập sai nhiều lần", "/auth/login")
+		}
+
 		var user models.User
 		if err := database.Get().Where("email = ?", body.Email).First(&user).Error; err != nil {
+			recordLoginFailure(body.Email, time.Now())
 			if isJSON {
 				return fiber.NewError(fiber.StatusUnauthorized, "email hoặc mật khẩu sai")
 			}
@@ -1190,12 +1250,30 @@ func Login() fiber.Handler {
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(body.Password)); err != nil {
+			recordLoginFailure(body.Email, time.Now())
 			if isJSON {
 				return fiber.NewError(fiber.StatusUnauthorized, "email hoặc mật khẩu sai")
 			}
 			return respondError(c, fiber.StatusUnauthorized, "Email hoặc mật khẩu không đúng", "/auth/login")
 		}
 
+		if user.Suspended() {
+			if isJSON {
+				return fiber.NewError(fiber.StatusForbidden, "tài khoản đã bị khóa")
+			}
+			return respondError(c, fiber.StatusForbidden, suspendedAccountMessage, "/auth/login")
+		}
+
+		redirect := c.FormValue("next")
+		if redirect == "" || redirect == "/auth/login" || redirect == "/auth/register" {
+			redirect = "/"
+		}
+		// Bộ đếm sai chỉ được xóa khi qua cả bước mã 2FA, để không dùng mật khẩu đúng làm mới lượt đoán mã
+		if user.TwoFactorEnabled() {
+			return beginTwoFactorLogin(c, user, redirect)
+		}
+		clearLoginFailures(body.Email)
+
 		if err := setUserSession(c, user); err != nil {
 			if isJSON {
 				return fiber.NewError(fiber.StatusInternalServerError, "không thể khởi tạo phiên đăng nhập")
@@ -1215,10 +1293,6 @@ func Login() fiber.Handler {
 		}
 
 		setFlash(c, "success", fmt.Sprintf("Chào mừng trở lại, %s!", user.Name))
-		redirect := c.FormValue("next")
-		if redirect == "" || redirect == "/auth/login" || redirect == "/auth/register" {
-			redirect = "/"
-		}
 		return c.Status(fiber.StatusSeeOther).Redirect(redirect)
 	}
 }
@@ -1255,31 +1329,17 @@ func Courses() fiber.Handler {
 	}
 }
 
+// Contributors xếp hạng thành viên theo đóng góp công khai thực tế.
 func Contributors() fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		contributors, err := topContributors(database.Get(), contributorLimit)
+		if err != nil {
+			log.Printf("failed to load contributors: %v", err)
+		}
 		return render(c, "pages/contributors", fiber.Map{
-			"Title":       "Thành viên đóng góp",
-			"Description": "Những kỹ sư DevOps đang cùng xây dựng thư viện kiến thức mở.",
-			"Contributors": []fiber.Map{
-				{
-					"Name":          "Lan Nguyễn",
-					"Role":          "Platform Engineer",
-					"Bio":           "Thiết kế hệ thống Kubernetes multi-cluster cho doanh nghiệp fintech.",
-					"Contributions": 14,
-				},
-				{
-					"Name":          "Minh Phạm",
-					"Role":          "Site Reliability Engineer",
-					"Bio":           "Tối ưu pipeline CI/CD và vận hành hệ thống với SLO rõ ràng.",
-					"Contributions": 21,
-				},
-				{
-					"Name":          "Hoàng Lê",
-					"Role":          "DevSecOps Specialist",
-					"Bio":           "Xây dựng framework bảo mật và audit tự động cho microservices.",
-					"Contributions": 11,
-				},
-			},
+			"Title":        "Thành viên đóng góp",
+			"Description":  "Những kỹ sư DevOps đang cùng xây dựng thư viện kiến thức mở.",
+			"Contributors": contributors,
 		}, "main")
 	}
 }
@@ -1375,12 +1435,20 @@ func Contribute() fiber.Handler {
 func UploadImage() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Kiểm tra đăng nhập
-		userID, err := currentUserID(c)
-		if err != nil {
+		user := currentUser(c)
+		if user == nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Bạn cần đăng nhập để upload ảnh",
 			})
 		}
+		if !can(user, policy.Create, policy.Images) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Tài khoản của bạn không có quyền upload ảnh",
+			})
+		}
+		if ok, err := requireVerifiedEmail(c, user.ID, ""); !ok {
+			return err
+		}
 
 		// Lấy file từ form
 		fileHeader, err := c.FormFile("image")
@@ -1390,74 +1458,16 @@ func UploadImage() fiber.Handler {
 			})
 		}
 
-		// Kiểm tra loại file
-		ext := filepath.Ext(fileHeader.Filename)
-		allowedExts := map[string]bool{
-			".jpg":  true,
-			".jpeg": true,
-			".png":  true,
-			".gif":  true,
-			".webp": true,
-		}
-		if !allowedExts[strings.ToLower(ext)] {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Chỉ hỗ trợ file ảnh: jpg, jpeg, png, gif, webp",
-			})
-		}
-
-		// Kiểm tra kích thước (max 5MB)
-		if fileHeader.Size > 5*1024*1024 {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Kích thước ảnh không được vượt quá 5MB",
-			})
-		}
-
-		// Đọc nội dung file
-		file, err := fileHeader.Open()
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Không thể đọc file",
-			})
-		}
-		defer file.Close()
-
-		// Đọc dữ liệu binary
-		fileData, err := io.ReadAll(file)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Không thể đọc dữ liệu file",
+		image, uploadErr := readImageUpload(fileHeader, user.ID)
+		if uploadErr != nil {
+			return c.Status(uploadErr.Code).JSON(fiber.Map{
+				"error": uploadErr.Message,
 			})
 		}
 
-		// Xác định content type
-		contentType := fileHeader.Header.Get("Content-Type")
-		if contentType == "" {
-			// Fallback dựa vào extension
-			switch strings.ToLower(ext) {
-			case ".jpg", ".jpeg":
-				contentType = "image/jpeg"
-			case ".png":
-				contentType = "image/png"
-			case ".gif":
-				contentType = "image/gif"
-			case ".webp":
-				contentType = "image/webp"
-			default:
-				contentType = "application/octet-stream"
-			}
-		}
-
 		// Lưu vào database
 		db := database.Get()
-		image := models.Image{
-			Filename:    fileHeader.Filename,
-			ContentType: contentType,
-			Size:        fileHeader.Size,
-			Data:        fileData,
-			UploaderID:  userID,
-		}
-
-		if err := db.Create(&image).Error; err != nil {
+		if err := db.Create(image).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Không thể lưu ảnh vào database",
 			})
@@ -1472,6 +1482,59 @@ func UploadImage() fiber.Handler {
 	}
 }
 
+// imageUploadTypes là các đuôi file ảnh được phép tải lên cùng content type tương ứng.
+var imageUploadTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// maxImageUploadSize là dung lượng tối đa của một ảnh (5MB).
+const maxImageUploadSize = 5 * 1024 * 1024
+
+// readImageUpload kiểm tra định dạng, kích thước file ảnh và đọc thành bản ghi Image chưa lưu.
+func readImageUpload(fileHeader *multipart.FileHeader, uploaderID uint) (*models.Image, *fiber.Error) {
+	// Kiểm tra loại file
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if _, ok := imageUploadTypes[ext]; !ok {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Chỉ hỗ trợ file ảnh: jpg, jpeg, png, gif, webp")
+	}
+
+	// Kiểm tra kích thước (max 5MB)
+	if fileHeader.Size > maxImageUploadSize {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Kích thước ảnh không được vượt quá 5MB")
+	}
+
+	// Đọc nội dung file
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Không thể đọc file")
+	}
+	defer file.Close()
+
+	// Đọc dữ liệu binary
+	fileData, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Không thể đọc dữ liệu file")
+	}
+
+	// Xác định content type, fallback dựa vào extension
+	contentType := fileHeader.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = imageUploadTypes[ext]
+	}
+
+	return &models.Image{
+		Filename:    fileHeader.Filename,
+		ContentType: contentType,
+		Size:        fileHeader.Size,
+		Data:        fileData,
+		UploaderID:  uploaderID,
+	}, nil
+}
+
 // GetImage trả về ảnh từ database
 func GetImage() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -1503,11 +1566,11 @@ func GetImage() fiber.Handler {
 	}
 }
 
-// CreateAnnotation tạo chú thích cho dòng trong bài viết (chỉ tác giả)
+// CreateAnnotation tạo chú thích cho dòng trong bài viết (tác giả hoặc ban quản trị)
 func CreateAnnotation() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userID, err := currentUserID(c)
-		if err != nil {
+		user := currentUser(c)
+		if user == nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Bạn cần đăng nhập",
 			})
@@ -1523,6 +1586,7 @@ func CreateAnnotation() fiber.Handler {
 		var body struct {
 			Content    string `json:"content"`
 			LineNumber int    `json:"line_number"`
+			Quote      string `json:"quote"`
 		}
 
 		if err := c.BodyParser(&body); err != nil {
@@ -1541,21 +1605,27 @@ func CreateAnnotation() fiber.Handler {
 			})
 		}
 
-		if post.AuthorID != userID {
+		if !can(user, policy.Edit, post) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Chỉ tác giả mới có thể thêm chú thích",
+				"error": "Bạn không có quyền thêm chú thích cho bài viết này",
 			})
 		}
+		if ok, err := requireVerifiedEmail(c, user.ID, ""); !ok {
+			return err
+		}
+
+		line, lineAnchor := anchorNewNote(anchor.Blocks(post.Content), body.LineNumber, body.Quote)
 
 		// Xóa annotation cũ nếu có
-		db.Where("post_id = ? AND line_number = ?", postID, body.LineNumber).Delete(&models.Annotation{})
+		db.Where("post_id = ? AND line_number = ? AND orphaned = ?", postID, line, false).Delete(&models.Annotation{})
 
 		// Tạo mới
 		postIDUint := uint(postID)
 		annotation := models.Annotation{
 			Content:    strings.TrimSpace(body.Content),
 			PostID:     &postIDUint,
-			LineNumber: body.LineNumber,
+			LineNumber: line,
+			LineAnchor: lineAnchor,
 		}
 
 		if err := db.Create(&annotation).Error; err != nil {
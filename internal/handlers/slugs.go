@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/slug"
+)
+
+// reservedSlugs trùng với các route tĩnh dưới /posts và /books.
+var reservedSlugs = map[string]bool{
+	"preview": true,
+	"import":  true,
+}
+
+var slugSuffixPattern = regexp.MustCompile(`^(.+)-\d+$`)
+
+func slugTable(resourceType string) string {
+	if resourceType == models.SlugResourceBook {
+		return "books"
+	}
+	return "posts"
+}
+
+func slugFallback(resourceType string) string {
+	if resourceType == models.SlugResourceBook {
+		return "sach"
+	}
+	return "bai-viet"
+}
+
+// slugTaken kiểm tra slug đã được tài nguyên khác dùng (kể cả trong lịch sử) hay chưa.
+func slugTaken(db *gorm.DB, resourceType, candidate string, resourceID uint) (bool, error) {
+	if reservedSlugs[candidate] || slug.IsNumeric(candidate) {
+		return true, nil
+	}
+
+	var count int64
+	if err := db.Table(slugTable(resourceType)).Where("slug = ? AND id <> ?", candidate, resourceID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := db.Model(&models.SlugHistory{}).
+		Where("resource_type = ? AND slug = ? AND resource_id <> ?", resourceType, candidate, resourceID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// uniqueSlug sinh slug chưa bị dùng bằng cách thêm hậu tố -2, -3...
+func uniqueSlug(db *gorm.DB, resourceType, title string, resourceID uint) (string, error) {
+	base := slug.Make(title)
+	if base == "" {
+		base = slugFallback(resourceType)
+	}
+
+	candidate := base
+	for n := 2; ; n++ {
+		taken, err := slugTaken(db, resourceType, candidate, resourceID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// slugMatchesTitle cho biết slug hiện tại vẫn được sinh từ tiêu đề (có thể kèm hậu tố số).
+func slugMatchesTitle(current, title string) bool {
+	base := slug.Make(title)
+	if current == "" || base == "" {
+		return false
+	}
+	if current == base {
+		return true
+	}
+	if m := slugSuffixPattern.FindStringSubmatch(current); m != nil {
+		return m[1] == base
+	}
+	return false
+}
+
+// nextSlug tính slug mới khi tiêu đề thay đổi và lưu slug cũ vào lịch sử.
+func nextSlug(db *gorm.DB, resourceType string, resourceID uint, current, title string) (string, error) {
+	if slugMatchesTitle(current, title) {
+		return current, nil
+	}
+
+	next, err := uniqueSlug(db, resourceType, title, resourceID)
+	if err != nil {
+		return "", err
+	}
+
+	if current != "" && resourceID != 0 {
+		history := models.SlugHistory{ResourceType: resourceType, Slug: current, ResourceID: resourceID}
+		if err := db.Where(models.SlugHistory{ResourceType: resourceType, Slug: current}).
+			Assign(models.SlugHistory{ResourceID: resourceID}).
+			FirstOrCreate(&history).Error; err != nil {
+			return "", err
+		}
+	}
+	return next, nil
+}
+
+func assignPostSlug(db *gorm.DB, post *models.Post) error {
+	next, err := nextSlug(db, models.SlugResourcePost, post.ID, post.Slug, post.Title)
+	if err != nil {
+		return err
+	}
+	post.Slug = next
+	return nil
+}
+
+func assignBookSlug(db *gorm.DB, book *models.Book) error {
+	next, err := nextSlug(db, models.SlugResourceBook, book.ID, book.Slug, book.Title)
+	if err != nil {
+		return err
+	}
+	book.Slug = next
+	return nil
+}
+
+// postPath trả về URL chuẩn của bài viết, ưu tiên slug.
+func postPath(post models.Post) string {
+	if post.Slug != "" {
+		return "/posts/" + post.Slug
+	}
+	return fmt.Sprintf("/posts/%d", post.ID)
+}
+
+// bookPath trả về URL chuẩn của sách, ưu tiên slug.
+func bookPath(book models.Book) string {
+	if book.Slug != "" {
+		return "/books/" + book.Slug
+	}
+	return fmt.Sprintf("/books/%d", book.ID)
+}
+
+// resolveSlugParam tìm ID tài nguyên từ tham số URL (slug hiện tại, slug cũ hoặc ID số).
+// canonical=false khi URL không phải dạng slug hiện tại và cần redirect 301.
+func resolveSlugParam(db *gorm.DB, resourceType, param string) (id uint, canonical bool, err error) {
+	param = strings.TrimSpace(param)
+	if slug.IsNumeric(param) {
+		value, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return 0, false, gorm.ErrRecordNotFound
+		}
+		return uint(value), false, nil
+	}
+
+	var ids []uint
+	if err := db.Table(slugTable(resourceType)).Where("slug = ? AND deleted_at IS NULL", param).Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, false, err
+	}
+	if len(ids) > 0 {
+		return ids[0], true, nil
+	}
+
+	var history models.SlugHistory
+	if err := db.Where("resource_type = ? AND slug = ?", resourceType, param).First(&history).Error; err != nil {
+		return 0, false, err
+	}
+	return history.ResourceID, false, nil
+}
+
+// redirectCanonical chuyển hướng 301 sang URL chuẩn, giữ nguyên query string.
+func redirectCanonical(c *fiber.Ctx, path string) error {
+	if query := string(c.Request().URI().QueryString()); query != "" {
+		path += "?" + query
+	}
+	return c.Redirect(path, fiber.StatusMovedPermanently)
+}
+
+// BackfillSlugs sinh slug cho các bài viết và sách cũ chưa có slug.
+func BackfillSlugs(db *gorm.DB) error {
+	var posts []models.Post
+	if err := db.Unscoped().Where("slug IS NULL OR slug = ''").Find(&posts).Error; err != nil {
+		return err
+	}
+	for i := range posts {
+		if err := assignPostSlug(db, &posts[i]); err != nil {
+			return err
+		}
+		if err := db.Unscoped().Model(&posts[i]).UpdateColumn("slug", posts[i].Slug).Error; err != nil {
+			return err
+		}
+	}
+
+	var books []models.Book
+	if err := db.Unscoped().Where("slug IS NULL OR slug = ''").Find(&books).Error; err != nil {
+		return err
+	}
+	for i := range books {
+		if err := assignBookSlug(db, &books[i]); err != nil {
+			return err
+		}
+		if err := db.Unscoped().Model(&books[i]).UpdateColumn("slug", books[i].Slug).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
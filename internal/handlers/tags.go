@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+)
+
+const (
+	maxTagLength = 60
+	// maxPostTags tag dài tối đa cùng dấu phẩy phải vừa cột posts.tags (size:255)
+	maxPostTags       = 4
+	maxPostTagsLength = 255
+)
+
+var errTooManyTags = fmt.Errorf("mỗi bài viết chỉ được gắn tối đa %d tag", maxPostTags)
+
+// normalizeTagName đưa tên tag về dạng chuẩn: chữ thường, khoảng trắng thành "-".
+// Độ dài tính theo ký tự để không cắt đôi chữ có dấu.
+func normalizeTagName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimLeft(name, "#")
+	name = strings.Join(strings.Fields(name), "-")
+	if runes := []rune(name); len(runes) > maxTagLength {
+		name = strings.TrimRight(string(runes[:maxTagLength]), "-")
+	}
+	return name
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike thoát các ký tự đặc biệt của LIKE để chuỗi người dùng nhập chỉ khớp đúng nguyên văn.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// splitTagInput tách chuỗi tags phân cách bằng dấu phẩy thành danh sách tên đã chuẩn hóa.
+func splitTagInput(input string) []string {
+	names := make([]string, 0)
+	for _, part := range strings.Split(input, ",") {
+		if name := normalizeTagName(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// normalizePostTags chuẩn hóa và bỏ trùng chuỗi tags của bài viết, từ chối khi vượt quá maxPostTags.
+func normalizePostTags(input string) (string, error) {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, name := range splitTagInput(input) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) > maxPostTags {
+		return "", errTooManyTags
+	}
+	return strings.Join(names, ","), nil
+}
+
+// lookupTag tìm tag theo tên chuẩn hoặc alias, không tạo mới.
+func lookupTag(db *gorm.DB, name string) (*models.Tag, error) {
+	name = normalizeTagName(name)
+	if name == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var alias models.TagAlias
+	if err := db.Where("alias = ?", name).First(&alias).Error; err == nil {
+		var tag models.Tag
+		if err := db.First(&tag, alias.TagID).Error; err != nil {
+			return nil, err
+		}
+		return &tag, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var tag models.Tag
+	if err := db.Where("name = ?", name).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// resolveTags chuyển danh sách tên (kể cả alias) thành tag chuẩn, tạo tag mới nếu chưa có.
+func resolveTags(db *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[uint]bool)
+	for _, name := range names {
+		tag, err := lookupTag(db, name)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			tag = &models.Tag{Name: normalizeTagName(name)}
+			err = db.Where(models.Tag{Name: tag.Name}).FirstOrCreate(tag).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+		if seen[tag.ID] {
+			continue
+		}
+		seen[tag.ID] = true
+		tags = append(tags, *tag)
+	}
+	return tags, nil
+}
+
+func joinTagNames(tags []models.Tag) string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// syncPostTags gắn tag cho bài viết đã lưu và cập nhật lại chuỗi Tags hiển thị.
+func syncPostTags(db *gorm.DB, post *models.Post, input string) error {
+	tags, err := resolveTags(db, splitTagInput(input))
+	if err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(joinTagNames(tags)) > maxPostTagsLength {
+		return errTooManyTags
+	}
+	if err := db.Model(post).Association("TagList").Replace(tags); err != nil {
+		return err
+	}
+	post.TagList = tags
+	post.Tags = joinTagNames(tags)
+	return db.Model(post).UpdateColumn("tags", post.Tags).Error
+}
+
+// syncBookTags chuẩn hóa BookTag, BookCategory theo cùng bộ tag và gắn vào sách.
+func syncBookTags(db *gorm.DB, book *models.Book) error {
+	names := make([]string, 0, 2)
+	if name := normalizeTagName(book.BookTag); name != "" {
+		names = append(names, name)
+	}
+	if name := normalizeTagName(book.BookCategory); name != "" {
+		names = append(names, name)
+	}
+
+	tags, err := resolveTags(db, names)
+	if err != nil {
+		return err
+	}
+
+	// Đưa giá trị free-text về tên tag chuẩn (alias -> tag gốc)
+	if book.BookTag != "" {
+		if tag, err := lookupTag(db, book.BookTag); err == nil {
+			book.BookTag = tag.Name
+		}
+	}
+	if book.BookCategory != "" {
+		if tag, err := lookupTag(db, book.BookCategory); err == nil {
+			book.BookCategory = tag.Name
+		}
+	}
+
+	if err := db.Model(book).Association("Tags").Replace(tags); err != nil {
+		return err
+	}
+	book.Tags = tags
+	return db.Model(book).UpdateColumns(map[string]interface{}{
+		"book_tag":      book.BookTag,
+		"book_category": book.BookCategory,
+	}).Error
+}
+
+// postsWithTag lọc bài viết có gắn tag theo ID.
+func postsWithTag(db *gorm.DB, tagID uint) *gorm.DB {
+	subquery := db.Session(&gorm.Session{NewDB: true}).Table("post_tags").Select("post_id").Where("tag_id = ?", tagID)
+	return db.Where("posts.id IN (?)", subquery)
+}
+
+// tagPath trả về URL trang tag.
+func tagPath(name string) string {
+	return "/tags/" + url.PathEscape(name)
+}
+
+type tagCount struct {
+	Name  string
+	Count int64
+}
+
+// popularPostTags thống kê tag theo số bài viết đã xuất bản.
+func popularPostTags(db *gorm.DB, limit int) []tagCount {
+	var counts []tagCount
+	db.Table("tags").
+		Select("tags.name AS name, COUNT(posts.id) AS count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Where("posts.status = ? AND posts.hidden = ?", models.PostStatusPublished, false).
+		Group("tags.name").
+		Order("count DESC, tags.name ASC").
+		Limit(limit).
+		Scan(&counts)
+	return counts
+}
+
+// TagPage hiển thị bài viết và sách thuộc một tag.
+func TagPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		raw, _ := url.PathUnescape(c.Params("name"))
+		db := database.Get()
+
+		tag, err := lookupTag(db, raw)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return respondError(c, fiber.StatusNotFound, "Tag không tồn tại", "/posts")
+			}
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải tag", "/posts")
+		}
+		if raw != tag.Name {
+			return c.Redirect(tagPath(tag.Name), fiber.StatusMovedPermanently)
+		}
+
+		var posts []models.Post
+		postsWithTag(publishedPosts(db), tag.ID).Preload("Author").Order(postOrder).Limit(50).Find(&posts)
+		postItems := make([]fiber.Map, 0, len(posts))
+		for _, p := range posts {
+			postItems = append(postItems, fiber.Map{
+				"ID":           p.ID,
+				"URL":          postPath(p),
+				"Title":        p.Title,
+				"Summary":      p.Summary,
+				"AuthorName":   p.Author.Name,
+				"CreatedLabel": formatTimeVN(p.CreatedAt),
+			})
+		}
+
+		var books []models.Book
+		policy.VisibleBooks(db, nil).Where("books.id IN (?)", db.Table("book_tags").Select("book_id").Where("tag_id = ?", tag.ID)).
+			Order("created_at DESC").Limit(50).Find(&books)
+		bookItems := make([]fiber.Map, 0, len(books))
+		for _, b := range books {
+			bookItems = append(bookItems, fiber.Map{
+				"ID":          b.ID,
+				"URL":         bookPath(b),
+				"Title":       b.Title,
+				"Description": b.Description,
+			})
+		}
+
+		var aliases []models.TagAlias
+		db.Where("tag_id = ?", tag.ID).Order("alias ASC").Find(&aliases)
+		aliasNames := make([]string, 0, len(aliases))
+		for _, a := range aliases {
+			aliasNames = append(aliasNames, a.Alias)
+		}
+
+		return render(c, "pages/tag", fiber.Map{
+			"Title":   "#" + tag.Name,
+			"Tag":     tag.Name,
+			"Aliases": aliasNames,
+			"Posts":   postItems,
+			"Books":   bookItems,
+			"FeedURL": tagPath(tag.Name) + "/feed.xml",
+			"RSSURL":  tagPath(tag.Name) + "/rss.xml",
+		}, "main")
+	}
+}
+
+// TagAutocomplete trả về danh sách tag khớp tiền tố (?q=) kèm số bài viết đã xuất bản.
+func TagAutocomplete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		prefix := normalizeTagName(c.Query("q"))
+		limit, _ := strconv.Atoi(c.Query("limit", "10"))
+		if limit < 1 || limit > 50 {
+			limit = 10
+		}
+
+		db := database.Get()
+		like := escapeLike(prefix) + "%"
+
+		var results []struct {
+			Name  string `json:"name"`
+			Count int64  `json:"count"`
+		}
+		query := db.Table("tags").
+			Select("tags.name AS name, COUNT(posts.id) AS count").
+			Joins("LEFT JOIN post_tags ON post_tags.tag_id = tags.id").
+			Joins("LEFT JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL AND posts.status = ? AND posts.hidden = ?",
+				models.PostStatusPublished, false).
+			Group("tags.name").
+			Order("count DESC, tags.name ASC").
+			Limit(limit)
+		if prefix != "" {
+			query = query.Where(`tags.name LIKE ? ESCAPE '\' OR tags.id IN (?)`, like,
+				db.Table("tag_aliases").Select("tag_id").Where(`alias LIKE ? ESCAPE '\'`, like))
+		}
+		if err := query.Scan(&results).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Không thể tải tag"})
+		}
+
+		if results == nil {
+			return c.JSON([]fiber.Map{})
+		}
+		return c.JSON(results)
+	}
+}
+
+// BackfillTags chuyển dữ liệu tag dạng chuỗi cũ sang bảng tags và các bảng liên kết.
+func BackfillTags(db *gorm.DB) error {
+	var posts []models.Post
+	if err := db.Unscoped().
+		Where("tags <> '' AND id NOT IN (?)", db.Table("post_tags").Select("post_id")).
+		Find(&posts).Error; err != nil {
+		return err
+	}
+	for i := range posts {
+		if err := syncPostTags(db.Unscoped(), &posts[i], posts[i].Tags); err != nil {
+			return err
+		}
+	}
+
+	var books []models.Book
+	if err := db.Unscoped().
+		Where("(book_tag <> '' OR book_category <> '') AND id NOT IN (?)", db.Table("book_tags").Select("book_id")).
+		Find(&books).Error; err != nil {
+		return err
+	}
+	for i := range books {
+		if err := syncBookTags(db.Unscoped(), &books[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/policy"
+	"fiber-learning-community/internal/scheduler"
+)
+
+const defaultTrashRetentionDays = 30
+
+// TrashRetention trả về thời gian giữ bài viết trong thùng rác trước khi xóa vĩnh viễn.
+// Cấu hình qua biến môi trường TRASH_RETENTION_DAYS (mặc định 30 ngày).
+func TrashRetention() time.Duration {
+	days, err := strconv.Atoi(strings.TrimSpace(os.Getenv("TRASH_RETENTION_DAYS")))
+	if err != nil || days <= 0 {
+		days = defaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// loadTrashedPost tìm bài viết đã bị xóa mềm mà người dùng được quản lý (tác giả hoặc ban quản trị).
+func loadTrashedPost(c *fiber.Ctx, user *models.User) (*models.Post, error) {
+	postID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, respondError(c, fiber.StatusBadRequest, "ID bài viết không hợp lệ", "/trash")
+	}
+
+	var post models.Post
+	if err := database.Get().Unscoped().
+		Where("deleted_at IS NOT NULL").
+		First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, respondError(c, fiber.StatusNotFound, "Không tìm thấy bài viết trong thùng rác", "/trash")
+		}
+		return nil, respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/trash")
+	}
+	if !can(user, policy.Delete, post) {
+		return nil, respondError(c, fiber.StatusNotFound, "Không tìm thấy bài viết trong thùng rác", "/trash")
+	}
+	return &post, nil
+}
+
+// DeletePost chuyển bài viết vào thùng rác (tác giả hoặc ban quản trị).
+func DeletePost() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập để xóa bài viết", "/auth/login")
+		}
+
+		postID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return respondError(c, fiber.StatusBadRequest, "ID bài viết không hợp lệ", "/posts")
+		}
+
+		db := database.Get()
+		var post models.Post
+		if err := db.First(&post, postID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return respondError(c, fiber.StatusNotFound, "Bài viết không tồn tại", "/posts")
+			}
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải bài viết", "/posts")
+		}
+
+		if !can(user, policy.Delete, post) {
+			return respondError(c, fiber.StatusForbidden, "Bạn không có quyền xóa bài viết này", fmt.Sprintf("/posts/%d", postID))
+		}
+
+		if err := db.Delete(&post).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể xóa bài viết", fmt.Sprintf("/posts/%d", postID))
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "message": "Đã chuyển bài viết vào thùng rác"})
+		}
+
+		setFlash(c, "success", "Đã chuyển bài viết vào thùng rác")
+		return c.Status(fiber.StatusSeeOther).Redirect(localRedirect(c.FormValue("next"), "/trash"))
+	}
+}
+
+// TrashPage hiển thị các bài viết đã xóa của người dùng hiện tại.
+func TrashPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return c.Redirect("/auth/login?next=/trash")
+		}
+
+		db := database.Get()
+		var posts []models.Post
+		if err := db.Unscoped().
+			Where("author_id = ? AND deleted_at IS NOT NULL", userID).
+			Order("deleted_at DESC").
+			Find(&posts).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tải thùng rác", "/posts")
+		}
+
+		retention := TrashRetention()
+		items := make([]fiber.Map, 0, len(posts))
+		for _, p := range posts {
+			deletedAt := p.DeletedAt.Time
+			items = append(items, fiber.Map{
+				"ID":           p.ID,
+				"Title":        p.Title,
+				"DeletedLabel": formatTimeVN(deletedAt),
+				"PurgeLabel":   formatTimeVN(deletedAt.Add(retention)),
+			})
+		}
+
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{"posts": items, "retention_days": int(retention.Hours() / 24)})
+		}
+
+		return render(c, "pages/trash", fiber.Map{
+			"Title":         "Thùng rác",
+			"Posts":         items,
+			"RetentionDays": int(retention.Hours() / 24),
+		}, "main")
+	}
+}
+
+// RestorePost khôi phục bài viết từ thùng rác.
+func RestorePost() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login")
+		}
+
+		post, err := loadTrashedPost(c, user)
+		if post == nil {
+			return err
+		}
+
+		if err := database.Get().Unscoped().Model(post).Update("deleted_at", nil).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể khôi phục bài viết", "/trash")
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "post_id": post.ID})
+		}
+
+		setFlash(c, "success", "Đã khôi phục bài viết")
+		return c.Status(fiber.StatusSeeOther).Redirect(postPath(*post))
+	}
+}
+
+// PurgePost xóa vĩnh viễn bài viết khỏi thùng rác.
+func PurgePost() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login")
+		}
+
+		post, err := loadTrashedPost(c, user)
+		if post == nil {
+			return err
+		}
+
+		if err := scheduler.PurgePost(database.Get(), post.ID); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể xóa vĩnh viễn bài viết", "/trash")
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true})
+		}
+
+		setFlash(c, "success", "Đã xóa vĩnh viễn bài viết")
+		return c.Status(fiber.StatusSeeOther).Redirect("/trash")
+	}
+}
@@ -0,0 +1,578 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"fiber-learning-community/internal/database"
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/totp"
+)
+
+const (
+	// twoFactorPendingTTL là thời gian chờ nhập mã sau khi đã nhập đúng mật khẩu.
+	twoFactorPendingTTL = 5 * time.Minute
+	recoveryCodeCount   = 10
+	// twoFactorRolesTTL giới hạn tần suất đọc thiết lập vai trò bắt buộc 2FA từ DB.
+	twoFactorRolesTTL = 30 * time.Second
+)
+
+// Khóa session của bước đăng nhập đang chờ mã 2FA.
+const (
+	sessionTwoFactorUserID  = "mfaUserID"
+	sessionTwoFactorStarted = "mfaStartedAt"
+	sessionTwoFactorNext    = "mfaNext"
+)
+
+// totpIssuer là tên hiển thị trong ứng dụng xác thực; để ASCII cho link otpauth:// ngắn, mã QR nhỏ và dễ quét.
+const totpIssuer = "Hoc DevOps"
+
+// recoveryAlphabet bỏ các ký tự dễ nhầm (0/o, 1/l/i).
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+var twoFactorRoles struct {
+	sync.Mutex
+	roles    map[string]bool
+	loadedAt time.Time
+}
+
+// twoFactorRequiredRoles trả các vai trò admin đã bắt buộc bật 2FA.
+func twoFactorRequiredRoles() map[string]bool {
+	twoFactorRoles.Lock()
+	defer twoFactorRoles.Unlock()
+	if twoFactorRoles.roles != nil && time.Since(twoFactorRoles.loadedAt) < twoFactorRolesTTL {
+		return twoFactorRoles.roles
+	}
+	roles := make(map[string]bool)
+	var setting models.Setting
+	err := database.Get().Where("key = ?", models.SettingTwoFactorRoles).Limit(1).Find(&setting).Error
+	if err != nil {
+		log.Printf("failed to load 2FA settings: %v", err)
+	}
+	for _, role := range strings.Split(setting.Value, ",") {
+		if role = strings.TrimSpace(role); validRole(role) {
+			roles[role] = true
+		}
+	}
+	twoFactorRoles.roles = roles
+	twoFactorRoles.loadedAt = time.Now()
+	return roles
+}
+
+func saveTwoFactorRequiredRoles(roles []string) error {
+	setting := models.Setting{Key: models.SettingTwoFactorRoles, Value: strings.Join(roles, ",")}
+	err := database.Get().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return err
+	}
+	twoFactorRoles.Lock()
+	twoFactorRoles.roles = nil
+	twoFactorRoles.Unlock()
+	return nil
+}
+
+// twoFactorRequired cho biết vai trò của user bị bắt buộc bật 2FA.
+func twoFactorRequired(user *models.User) bool {
+	return user != nil && twoFactorRequiredRoles()[user.Role]
+}
+
+// RequireTwoFactorEnrollment chặn người dùng thuộc vai trò bắt buộc 2FA nhưng chưa bật,
+// chỉ cho vào trang bật 2FA và các trang đăng nhập/đăng xuất.
+func RequireTwoFactorEnrollment() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		path := c.Path()
+		if strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/auth/") || strings.HasPrefix(path, "/account/2fa") {
+			return c.Next()
+		}
+		if len(twoFactorRequiredRoles()) == 0 {
+			return c.Next()
+		}
+		user := currentUser(c)
+		if user == nil || user.TwoFactorEnabled() || !twoFactorRequired(user) {
+			return c.Next()
+		}
+		return respondError(c, fiber.StatusForbidden, "Vai trò của bạn bắt buộc bật xác thực hai lớp trước khi tiếp tục", "/account/2fa")
+	}
+}
+
+// beginTwoFactorLogin ghi nhớ người dùng đã qua bước mật khẩu (hoặc OAuth) và chuyển sang bước nhập mã.
+// Phiên chỉ được cấp sau khi mã đúng.
+func beginTwoFactorLogin(c *fiber.Ctx, user models.User, next string) error {
+	sess, err := sessionStore.Get(c)
+	if err != nil {
+		return respondError(c, fiber.StatusInternalServerError, "Không thể đăng nhập", "/auth/login")
+	}
+	sess.Set(sessionTwoFactorUserID, strconv.FormatUint(uint64(user.ID), 10))
+	sess.Set(sessionTwoFactorStarted, time.Now().Unix())
+	sess.Set(sessionTwoFactorNext, localRedirect(next, "/"))
+	if err := sess.Save(); err != nil {
+		return respondError(c, fiber.StatusInternalServerError, "Không thể đăng nhập", "/auth/login")
+	}
+	if isJSONRequest(c) {
+		return c.JSON(fiber.Map{
+			"message":             "Nhập mã xác thực hai lớp để hoàn tất đăng nhập",
+			"two_factor_required": true,
+			"verify_url":          "/auth/2fa",
+		})
+	}
+	return c.Status(fiber.StatusSeeOther).Redirect("/auth/2fa")
+}
+
+// pendingTwoFactorUser trả về người dùng đang chờ nhập mã, nil nếu không có hoặc đã quá hạn.
+func pendingTwoFactorUser(c *fiber.Ctx) (*models.User, *session.Session) {
+	sess, err := sessionStore.Get(c)
+	if err != nil {
+		return nil, nil
+	}
+	idStr, _ := sess.Get(sessionTwoFactorUserID).(string)
+	started, _ := sess.Get(sessionTwoFactorStarted).(int64)
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil || time.Since(time.Unix(started, 0)) > twoFactorPendingTTL {
+		return nil, sess
+	}
+	var user models.User
+	if err := database.Get().First(&user, id).Error; err != nil || !user.TwoFactorEnabled() {
+		return nil, sess
+	}
+	return &user, sess
+}
+
+func clearTwoFactorPending(sess *session.Session) {
+	sess.Delete(sessionTwoFactorUserID)
+	sess.Delete(sessionTwoFactorStarted)
+	sess.Delete(sessionTwoFactorNext)
+	_ = sess.Save()
+}
+
+func twoFactorCode(c *fiber.Ctx) string {
+	if isJSONRequest(c) {
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return ""
+		}
+		return strings.TrimSpace(body.Code)
+	}
+	return strings.TrimSpace(c.FormValue("code"))
+}
+
+// verifyTOTP kiểm tra mã TOTP và đánh dấu bước thời gian đã dùng trong cùng một câu UPDATE,
+// nên một mã không dùng được hai lần kể cả khi hai replica nhận cùng lúc.
+func verifyTOTP(db *gorm.DB, user *models.User, code string, now time.Time) bool {
+	step, ok := totp.Validate(user.TOTPSecret, code, now)
+	if !ok {
+		return false
+	}
+	result := db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		UpdateColumn("totp_last_step", step)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// consumeRecoveryCode đánh dấu mã khôi phục đã dùng; mỗi mã chỉ dùng được một lần.
+func consumeRecoveryCode(db *gorm.DB, userID uint, code string) bool {
+	if len(normalizeRecoveryCode(code)) != 10 {
+		return false
+	}
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		UpdateColumn("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// checkSecondFactor chấp nhận mã TOTP 6 số hoặc một mã khôi phục.
+func checkSecondFactor(db *gorm.DB, user *models.User, code string, now time.Time) (ok, usedRecovery bool) {
+	if len(strings.ReplaceAll(code, " ", "")) == totp.Digits {
+		return verifyTOTP(db, user, code, now), false
+	}
+	if consumeRecoveryCode(db, user.ID, code) {
+		return true, true
+	}
+	return false, false
+}
+
+func newRecoveryCode() string {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	for i, b := range buf {
+		buf[i] = recoveryAlphabet[int(b)%len(recoveryAlphabet)]
+	}
+	return string(buf[:5]) + "-" + string(buf[5:])
+}
+
+// replaceRecoveryCodes xóa mã khôi phục cũ và tạo bộ mới, trả về mã gốc để hiển thị một lần.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(codes[i])}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func remainingRecoveryCodes(db *gorm.DB, userID uint) int64 {
+	var count int64
+	db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// TwoFactorLoginPage hiển thị bước nhập mã sau khi đã nhập đúng mật khẩu.
+func TwoFactorLoginPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if user, _ := pendingTwoFactorUser(c); user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Phiên xác thực đã hết hạn, hãy đăng nhập lại", "/auth/login")
+		}
+		return render(c, "pages/auth_2fa", fiber.Map{
+			"Title": "Xác thực hai lớp",
+		}, "main")
+	}
+}
+
+// VerifyTwoFactorLogin kiểm tra mã TOTP hoặc mã khôi phục rồi mới cấp phiên đăng nhập.
+// Nhập sai được tính chung bộ đếm khóa đăng nhập với mật khẩu sai.
+func VerifyTwoFactorLogin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, sess := pendingTwoFactorUser(c)
+		if user == nil {
+			return respondError(c, fiber.StatusUnauthorized, "Phiên xác thực đã hết hạn, hãy đăng nhập lại", "/auth/login")
+		}
+		now := time.Now()
+		if retryAfter, locked := loginThrottled(user.Email, now); retryAfter > 0 {
+			if locked {
+				clearTwoFactorPending(sess)
+				return tooManyRequests(c, retryAfter, "Tài khoản tạm khóa do đăng nhập sai nhiều lần", "/auth/login")
+			}
+			return tooManyRequests(c, retryAfter, "Bạn đã nhập sai nhiều lần", "/auth/2fa")
+		}
+		if user.Suspended() {
+			clearTwoFactorPending(sess)
+			return respondError(c, fiber.StatusForbidden, suspendedAccountMessage, "/auth/login")
+		}
+
+		db := database.Get()
+		ok, usedRecovery := checkSecondFactor(db, user, twoFactorCode(c), now)
+		if !ok {
+			recordLoginFailure(user.Email, now)
+			return respondError(c, fiber.StatusUnauthorized, "Mã xác thực không đúng hoặc đã được dùng", "/auth/2fa")
+		}
+		clearLoginFailures(user.Email)
+
+		next, _ := sess.Get(sessionTwoFactorNext).(string)
+		clearTwoFactorPending(sess)
+		if err := setUserSession(c, *user); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể đăng nhập", "/auth/login")
+		}
+
+		message := fmt.Sprintf("Chào mừng trở lại, %s!", user.Name)
+		if usedRecovery {
+			message = fmt.Sprintf("Bạn vừa dùng một mã khôi phục, còn lại %d mã. Hãy tạo mã mới nếu sắp hết.", remainingRecoveryCodes(db, user.ID))
+		}
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{
+				"message": message,
+				"user":    fiber.Map{"id": user.ID, "name": user.Name, "email": user.Email},
+			})
+		}
+		setFlash(c, "success", message)
+		return c.Status(fiber.StatusSeeOther).Redirect(localRedirect(next, "/"))
+	}
+}
+
+// loadTwoFactorAccount tải tài khoản đang đăng nhập cho các trang 2FA; không cho dùng token API.
+func loadTwoFactorAccount(c *fiber.Ctx) (*models.User, error) {
+	userID, err := sessionOnlyUserID(c)
+	if err != nil {
+		if requestAPIToken(c) != nil {
+			return nil, respondError(c, fiber.StatusForbidden, "Không thể quản lý xác thực hai lớp bằng token", "/account")
+		}
+		return nil, respondError(c, fiber.StatusUnauthorized, "Bạn cần đăng nhập", "/auth/login?next=/account/2fa")
+	}
+	var user models.User
+	if err := database.Get().First(&user, userID).Error; err != nil {
+		return nil, respondError(c, fiber.StatusNotFound, "Tài khoản không tồn tại", "/")
+	}
+	return &user, nil
+}
+
+// groupSecret chia khóa base32 thành nhóm 4 ký tự cho dễ nhập tay.
+func groupSecret(secret string) string {
+	var parts []string
+	for len(secret) > 4 {
+		parts = append(parts, secret[:4])
+		secret = secret[4:]
+	}
+	return strings.Join(append(parts, secret), " ")
+}
+
+func renderTwoFactor(c *fiber.Ctx, user *models.User, recoveryCodes []string) error {
+	data := fiber.Map{
+		"Title":         "Xác thực hai lớp",
+		"Enabled":       user.TwoFactorEnabled(),
+		"Pending":       !user.TwoFactorEnabled() && user.TOTPSecret != "",
+		"Required":      twoFactorRequired(user),
+		"RecoveryCodes": recoveryCodes,
+	}
+	if user.TwoFactorEnabled() {
+		data["EnabledLabel"] = formatTimeVN(*user.TOTPEnabledAt)
+		data["RemainingCodes"] = remainingRecoveryCodes(database.Get(), user.ID)
+	} else if user.TOTPSecret != "" {
+		uri := totp.URI(totpIssuer, user.Email, user.TOTPSecret)
+		svg, err := totp.QRCodeSVG(uri)
+		if err != nil {
+			log.Printf("failed to render 2FA QR code: %v", err)
+		}
+		data["QRCode"] = template.HTML(svg)
+		data["Secret"] = groupSecret(user.TOTPSecret)
+		data["URI"] = uri
+	}
+	return render(c, "pages/account_2fa", data, "main")
+}
+
+// TwoFactorPage hiển thị trạng thái 2FA: bắt đầu bật, quét QR để xác nhận, hoặc quản lý khi đã bật.
+func TwoFactorPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadTwoFactorAccount(c)
+		if user == nil {
+			return err
+		}
+		if wantsJSON(c) {
+			return c.JSON(fiber.Map{
+				"enabled":         user.TwoFactorEnabled(),
+				"required":        twoFactorRequired(user),
+				"recovery_codes":  remainingRecoveryCodes(database.Get(), user.ID),
+				"pending_confirm": !user.TwoFactorEnabled() && user.TOTPSecret != "",
+			})
+		}
+		return renderTwoFactor(c, user, nil)
+	}
+}
+
+// StartTwoFactorSetup sinh khóa TOTP mới chờ người dùng quét QR và xác nhận bằng một mã.
+func StartTwoFactorSetup() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadTwoFactorAccount(c)
+		if user == nil {
+			return err
+		}
+		if user.TwoFactorEnabled() {
+			return respondError(c, fiber.StatusConflict, "Xác thực hai lớp đã được bật", "/account/2fa")
+		}
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tạo khóa xác thực", "/account/2fa")
+		}
+		if err := database.Get().Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tạo khóa xác thực", "/account/2fa")
+		}
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"secret": secret, "otpauth_uri": totp.URI(totpIssuer, user.Email, secret)})
+		}
+		return c.Status(fiber.StatusSeeOther).Redirect("/account/2fa")
+	}
+}
+
+// EnableTwoFactor xác nhận mã đầu tiên từ ứng dụng xác thực, bật 2FA và cấp mã khôi phục.
+func EnableTwoFactor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadTwoFactorAccount(c)
+		if user == nil {
+			return err
+		}
+		if user.TwoFactorEnabled() {
+			return respondError(c, fiber.StatusConflict, "Xác thực hai lớp đã được bật", "/account/2fa")
+		}
+		if user.TOTPSecret == "" {
+			return respondError(c, fiber.StatusBadRequest, "Hãy bắt đầu thiết lập và quét mã QR trước", "/account/2fa")
+		}
+		now := time.Now()
+		step, ok := totp.Validate(user.TOTPSecret, twoFactorCode(c), now)
+		if !ok {
+			return respondError(c, fiber.StatusBadRequest, "Mã xác thực không đúng, hãy kiểm tra đồng hồ điện thoại", "/account/2fa")
+		}
+
+		var codes []string
+		err = database.Get().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Updates(map[string]interface{}{"totp_enabled_at": now, "totp_last_step": step}).Error; err != nil {
+				return err
+			}
+			codes, err = replaceRecoveryCodes(tx, user.ID)
+			return err
+		})
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể bật xác thực hai lớp", "/account/2fa")
+		}
+
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"enabled": true, "recovery_codes": codes})
+		}
+		setFlash(c, "success", "Đã bật xác thực hai lớp. Hãy lưu mã khôi phục ở nơi an toàn.")
+		return renderTwoFactor(c, user, codes)
+	}
+}
+
+// RegenerateRecoveryCodes thay toàn bộ mã khôi phục; cần một mã TOTP hợp lệ.
+func RegenerateRecoveryCodes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadTwoFactorAccount(c)
+		if user == nil {
+			return err
+		}
+		if !user.TwoFactorEnabled() {
+			return respondError(c, fiber.StatusBadRequest, "Xác thực hai lớp chưa được bật", "/account/2fa")
+		}
+		db := database.Get()
+		if !verifyTOTP(db, user, twoFactorCode(c), time.Now()) {
+			return respondError(c, fiber.StatusBadRequest, "Mã xác thực không đúng hoặc đã được dùng", "/account/2fa")
+		}
+		codes, err := replaceRecoveryCodes(db, user.ID)
+		if err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tạo mã khôi phục", "/account/2fa")
+		}
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"recovery_codes": codes})
+		}
+		setFlash(c, "success", "Đã tạo mã khôi phục mới, các mã cũ không còn dùng được")
+		return renderTwoFactor(c, user, codes)
+	}
+}
+
+// disableTwoFactor xóa khóa TOTP và mã khôi phục của người dùng.
+func disableTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret": "", "totp_enabled_at": nil, "totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// DisableTwoFactor tắt 2FA sau khi nhập mã TOTP hoặc mã khôi phục; không cho tắt khi vai trò bắt buộc.
+func DisableTwoFactor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadTwoFactorAccount(c)
+		if user == nil {
+			return err
+		}
+		if !user.TwoFactorEnabled() {
+			return respondError(c, fiber.StatusBadRequest, "Xác thực hai lớp chưa được bật", "/account/2fa")
+		}
+		if twoFactorRequired(user) {
+			return respondError(c, fiber.StatusForbidden, "Vai trò của bạn bắt buộc bật xác thực hai lớp", "/account/2fa")
+		}
+		db := database.Get()
+		if ok, _ := checkSecondFactor(db, user, twoFactorCode(c), time.Now()); !ok {
+			return respondError(c, fiber.StatusBadRequest, "Mã xác thực không đúng hoặc đã được dùng", "/account/2fa")
+		}
+		if err := disableTwoFactor(db, user.ID); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể tắt xác thực hai lớp", "/account/2fa")
+		}
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"enabled": false})
+		}
+		setFlash(c, "success", "Đã tắt xác thực hai lớp")
+		return c.Status(fiber.StatusSeeOther).Redirect("/account/2fa")
+	}
+}
+
+// UpdateTwoFactorPolicy cho admin chọn các vai trò bắt buộc bật 2FA.
+func UpdateTwoFactorPolicy() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var requested []string
+		if isJSONRequest(c) {
+			var body struct {
+				Roles []string `json:"roles"`
+			}
+			if err := c.BodyParser(&body); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "payload không hợp lệ")
+			}
+			requested = body.Roles
+		} else {
+			for _, role := range c.Context().PostArgs().PeekMulti("roles") {
+				requested = append(requested, string(role))
+			}
+		}
+		seen := make(map[string]bool)
+		roles := make([]string, 0, len(requested))
+		for _, role := range requested {
+			if !validRole(role) {
+				return respondError(c, fiber.StatusBadRequest, "Vai trò không hợp lệ: "+role, "/admin/users")
+			}
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+		if err := saveTwoFactorRequiredRoles(roles); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể lưu thiết lập", "/admin/users")
+		}
+		if isJSONRequest(c) {
+			return c.JSON(fiber.Map{"success": true, "roles": roles})
+		}
+		if len(roles) == 0 {
+			setFlash(c, "success", "Không còn vai trò nào bắt buộc xác thực hai lớp")
+		} else {
+			setFlash(c, "success", "Đã bắt buộc xác thực hai lớp cho vai trò: "+strings.Join(roles, ", "))
+		}
+		return c.Status(fiber.StatusSeeOther).Redirect(localRedirect(c.FormValue("next"), "/admin/users"))
+	}
+}
+
+// ResetUserTwoFactor cho admin tắt 2FA của người dùng mất cả thiết bị lẫn mã khôi phục.
+// Mọi phiên của người đó bị đăng xuất; nếu vai trò bắt buộc 2FA họ sẽ phải bật lại khi đăng nhập.
+func ResetUserTwoFactor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := loadAdminTarget(c)
+		if user == nil {
+			return err
+		}
+		if !user.TwoFactorEnabled() && user.TOTPSecret == "" {
+			return respondError(c, fiber.StatusBadRequest, "Người dùng chưa bật xác thực hai lớp", "/admin/users")
+		}
+		db := database.Get()
+		if err := disableTwoFactor(db, user.ID); err != nil {
+			return respondError(c, fiber.StatusInternalServerError, "Không thể đặt lại xác thực hai lớp", "/admin/users")
+		}
+		if _, err := revokeUserSessions(db, user.ID, ""); err != nil {
+			log.Printf("failed to revoke sessions after 2FA reset: %v", err)
+		}
+		user.TOTPSecret, user.TOTPEnabledAt = "", nil
+		return adminUserDone(c, user, fmt.Sprintf("Đã đặt lại xác thực hai lớp của %s", user.Name))
+	}
+}
@@ -0,0 +1,157 @@
+// Package mailer gửi email giao dịch (xác minh email, đặt lại mật khẩu) qua backend có thể thay thế:
+// SMTP cho môi trường thật hoặc sink cục bộ (MailHog, Mailpit...), file/log khi phát triển.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message là một email dạng văn bản thuần.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer gửi một email.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer gửi qua máy chủ SMTP. Username rỗng nghĩa là không xác thực (thường gặp ở SMTP sink cục bộ).
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("MAIL_FROM không hợp lệ: %w", err)
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := m.Host + ":" + strconv.Itoa(m.Port)
+	return smtp.SendMail(addr, auth, from.Address, []string{msg.To}, compose(m.From, msg, time.Now()))
+}
+
+// FileMailer ghi mỗi email thành một file .eml trong Dir để kiểm tra khi phát triển.
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%03d.eml", time.Now().Format("20060102-150405"), m.seq)
+	m.mu.Unlock()
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, compose(m.From, msg, time.Now()), 0o644); err != nil {
+		return err
+	}
+	log.Printf("✉️  Mail to %s saved to %s", msg.To, path)
+	return nil
+}
+
+// LogMailer chỉ in email ra log, mặc định khi chưa cấu hình mailer.
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	log.Printf("✉️  Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// compose dựng email RFC 5322 với tiêu đề mã hóa UTF-8.
+func compose(from string, msg Message, now time.Time) []byte {
+	if addr, err := mail.ParseAddress(from); err == nil {
+		from = addr.String()
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+var (
+	defaultMailer Mailer = LogMailer{}
+	defaultMu     sync.RWMutex
+)
+
+// FromEnv chọn mailer theo MAILER: smtp, file hoặc log (mặc định).
+func FromEnv() (Mailer, error) {
+	from := strings.TrimSpace(os.Getenv("MAIL_FROM"))
+	if from == "" {
+		from = "Cộng đồng Học DevOps <no-reply@localhost>"
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("MAILER"))) {
+	case "", "log":
+		return LogMailer{}, nil
+	case "file":
+		dir := strings.TrimSpace(os.Getenv("MAIL_DIR"))
+		if dir == "" {
+			dir = "tmp/mail"
+		}
+		return &FileMailer{Dir: dir, From: from}, nil
+	case "smtp":
+		host := strings.TrimSpace(os.Getenv("SMTP_HOST"))
+		if host == "" {
+			host = "localhost"
+		}
+		port, err := strconv.Atoi(strings.TrimSpace(os.Getenv("SMTP_PORT")))
+		if err != nil || port <= 0 {
+			port = 1025
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	}
+	return nil, fmt.Errorf("MAILER không hỗ trợ: %s", os.Getenv("MAILER"))
+}
+
+// SetDefault thay mailer dùng chung của ứng dụng.
+func SetDefault(m Mailer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultMailer = m
+}
+
+// Send gửi email bằng mailer dùng chung.
+func Send(msg Message) error {
+	defaultMu.RLock()
+	m := defaultMailer
+	defaultMu.RUnlock()
+	return m.Send(msg)
+}
@@ -8,5 +8,6 @@ type Annotation struct {
 	Content    string   `gorm:"type:text;not null"`
 	PostID     *uint    `gorm:"index"`
 	LineNumber int      `gorm:"index"`
+	LineAnchor
 	Post       Post     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
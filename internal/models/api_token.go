@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIToken là personal access token dùng cho script/CI gọi các endpoint JSON.
+// Chỉ lưu SHA-256 của token; chuỗi gốc chỉ hiển thị một lần khi tạo.
+type APIToken struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	UserID     uint       `gorm:"not null;index"`
+	Name       string     `gorm:"size:120;not null"`
+	Prefix     string     `gorm:"size:16;not null"` // Vài ký tự đầu để người dùng nhận ra token
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex"`
+	Scopes     string     `gorm:"size:255;not null"` // Phân tách bằng dấu cách
+	ExpiresAt  *time.Time `gorm:"index"`             // nil: không hết hạn
+	LastUsedAt *time.Time
+	LastUsedIP string `gorm:"size:64"`
+}
+
+// ScopeList trả danh sách quyền của token.
+func (t APIToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// HasScope cho biết token có quyền scope hay không.
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired cho biết token đã hết hạn tại thời điểm now chưa.
+func (t APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
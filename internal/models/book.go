@@ -12,6 +12,7 @@ type Book struct {
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 	Title        string         `gorm:"not null" json:"title"`
+	Slug         string         `gorm:"size:100;index" json:"slug"`
 	Description  string         `json:"description"`
 	CoverURL     string         `json:"cover_url"`
 	CoverColor   string         `gorm:"default:#1e293b" json:"cover_color"`
@@ -19,9 +20,23 @@ type Book struct {
 	Author       User           `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
 	AuthorName   string         `gorm:"-" json:"author_name"`
 	Published    bool           `gorm:"default:false" json:"published"`
-	BookTag      string         `gorm:"index" json:"book_tag"`      // Tag for book (e.g., "linux", "golang")
-	BookCategory string         `gorm:"index" json:"book_category"` // Category for filtering
+	Hidden       bool           `gorm:"not null;default:false;index" json:"hidden"` // Bị quản trị viên ẩn
+	BookTag      string         `gorm:"index" json:"book_tag"`                      // Tag for book (e.g., "linux", "golang")
+	BookCategory string         `gorm:"index" json:"book_category"`                 // Category for filtering
+	Tags         []Tag          `gorm:"many2many:book_tags;" json:"tags,omitempty"`
 	Pages        []BookPage     `gorm:"foreignKey:BookID" json:"pages,omitempty"`
+	Chapters     []BookChapter  `gorm:"foreignKey:BookID" json:"chapters,omitempty"`
+}
+
+// BookChapter nhóm các trang của sách thành chương; ParentID trỏ tới chương cha để tạo mục con.
+type BookChapter struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	BookID    uint      `gorm:"not null;index" json:"book_id"`
+	ParentID  *uint     `gorm:"index" json:"parent_id"`
+	Position  int       `gorm:"not null;default:0" json:"position"` // Thứ tự giữa các chương cùng cấp
+	Title     string    `gorm:"size:200;not null" json:"title"`
 }
 
 type BookPage struct {
@@ -29,8 +44,9 @@ type BookPage struct {
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
-	BookID     uint           `gorm:"not null;index" json:"book_id"`
-	PageNumber int            `gorm:"not null" json:"page_number"`
+	BookID     uint           `gorm:"not null;index;uniqueIndex:idx_book_pages_book_number,priority:1,where:deleted_at IS NULL" json:"book_id"`
+	PageNumber int            `gorm:"not null;uniqueIndex:idx_book_pages_book_number,priority:2,where:deleted_at IS NULL" json:"page_number"` // Liên tục từ 1, đánh lại khi sắp xếp/xóa trang
+	ChapterID  *uint          `gorm:"index" json:"chapter_id"`
 	Title      string         `json:"title"`
 	Content    string         `gorm:"type:text" json:"content"`
 }
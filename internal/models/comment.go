@@ -1,14 +1,28 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxCommentDepth giới hạn số cấp trả lời lồng nhau (bình luận gốc có độ sâu 0).
+const MaxCommentDepth = 3
 
 // Comment lưu bình luận của người dùng trên bài viết.
 type Comment struct {
 	gorm.Model
-	Content    string `gorm:"type:text;not null"`
-	PostID     uint   `gorm:"index"`
-	AuthorID   uint   `gorm:"index"`
-	LineNumber *int   `gorm:"index"`
-	Post       Post   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	Author     User   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Content    string     `gorm:"type:text;not null"`
+	PostID     uint       `gorm:"index"`
+	AuthorID   uint       `gorm:"index"`
+	LineNumber *int       `gorm:"index"`
+	LineAnchor            // Chỉ dùng khi LineNumber khác nil
+	ParentID   *uint      `gorm:"index"` // nil với bình luận gốc
+	Depth      int        `gorm:"not null;default:0"`
+	EditedAt   *time.Time // Thời điểm sửa gần nhất, nil nếu chưa sửa
+	Removed    bool       `gorm:"not null;default:false"` // Đã xóa nhưng giữ lại vì còn trả lời
+	Hidden     bool       `gorm:"not null;default:false"` // Bị quản trị viên ẩn nội dung
+	Post       Post       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Author     User       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Parent     *Comment   `gorm:"foreignKey:ParentID;constraint:OnDelete:SET NULL;"`
 }
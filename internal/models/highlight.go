@@ -23,6 +23,12 @@ type Highlight struct {
 	StartOffset  int    `gorm:"not null" json:"start_offset"`  // Character offset from start of page content
 	EndOffset    int    `gorm:"not null" json:"end_offset"`    // Character offset from start of page content
 	
+	// Text quote selector (W3C Web Annotation) dùng để gắn lại highlight khi trang bị sửa
+	QuoteExact   string `gorm:"type:text" json:"quote_exact"`
+	QuotePrefix  string `gorm:"type:varchar(160)" json:"quote_prefix"`
+	QuoteSuffix  string `gorm:"type:varchar(160)" json:"quote_suffix"`
+	Detached     bool   `gorm:"not null;default:false;index" json:"detached"` // Không còn tìm thấy đoạn trích trong trang
+	
 	// Relations
 	BookPage     BookPage `gorm:"foreignKey:BookPageID" json:"-"`
 	User         User     `gorm:"foreignKey:UserID" json:"-"`
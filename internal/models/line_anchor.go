@@ -0,0 +1,12 @@
+package models
+
+// LineAnchor gắn ghi chú vào một khối nội dung của bài viết (text-quote selector).
+// LineNumber chỉ là vị trí gần nhất đã biết; khi bài viết được sửa, ghi chú được tìm lại
+// theo nội dung khối và ngữ cảnh xung quanh, hoặc bị đánh dấu Orphaned nếu không còn khớp.
+type LineAnchor struct {
+	AnchorHash   string `gorm:"size:16;index"`
+	AnchorQuote  string `gorm:"type:text"`
+	AnchorPrefix string `gorm:"size:160"`
+	AnchorSuffix string `gorm:"size:160"`
+	Orphaned     bool   `gorm:"not null;default:false;index"`
+}
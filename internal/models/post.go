@@ -1,16 +1,37 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Trạng thái xuất bản của bài viết.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
 
 // Post đại diện cho bài viết do người dùng tạo.
 type Post struct {
 	gorm.Model
-	Title    string    `gorm:"size:200;not null"`
-	Summary  string    `gorm:"size:255"`
-	Content  string    `gorm:"type:text"`
-	CoverURL string    `gorm:"size:512"`
-	Tags     string    `gorm:"size:255"` // Comma-separated tags: "docker,kubernetes,ci-cd"
-	AuthorID uint      `gorm:"index"`
-	Author   User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
-	Comments []Comment `gorm:"constraint:OnDelete:CASCADE;"`
+	Title     string     `gorm:"size:200;not null"`
+	Slug      string     `gorm:"size:100;index"`
+	Summary   string     `gorm:"size:255"`
+	Content   string     `gorm:"type:text"`
+	CoverURL  string     `gorm:"size:512"`
+	Tags      string     `gorm:"size:255"` // Comma-separated canonical tag names, đồng bộ với TagList
+	TagList   []Tag      `gorm:"many2many:post_tags;"`
+	Status    string     `gorm:"size:20;not null;default:published;index"`
+	PublishAt *time.Time `gorm:"index"`                        // Thời điểm bài viết được (hoặc sẽ được) xuất bản
+	Hidden    bool       `gorm:"not null;default:false;index"` // Bị quản trị viên ẩn khỏi nơi công khai
+	AuthorID  uint       `gorm:"index"`
+	Author    User       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Comments  []Comment  `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// IsPublished cho biết bài viết đã hiển thị công khai hay chưa.
+func (p Post) IsPublished() bool {
+	return p.Status == "" || p.Status == PostStatusPublished
 }
@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+// PostRevision lưu ảnh chụp nội dung bài viết mỗi lần bài viết được lưu.
+type PostRevision struct {
+	gorm.Model
+	PostID          uint   `gorm:"index;not null;uniqueIndex:idx_post_revisions_post_number,priority:1,where:deleted_at IS NULL"`
+	Number          int    `gorm:"not null;uniqueIndex:idx_post_revisions_post_number,priority:2,where:deleted_at IS NULL"` // Số thứ tự phiên bản trong bài viết, bắt đầu từ 1
+	Title           string `gorm:"size:200;not null"`
+	Summary         string `gorm:"size:255"`
+	Content         string `gorm:"type:text"`
+	Tags            string `gorm:"size:255"`
+	LineAnnotations string `gorm:"type:text"` // JSON string: {"1": "notice text"}
+	EditorID        uint   `gorm:"index"`
+	Editor          User   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RateLimitCounter là bộ đếm fixed-window của rate limiter khi lưu trên Postgres,
+// dùng chung giữa các replica.
+type RateLimitCounter struct {
+	Key     string    `gorm:"primaryKey;size:191"`
+	Count   int       `gorm:"not null"`
+	ResetAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ReadingProgress lưu vị trí đọc gần nhất của một người dùng trong một cuốn sách để mở lại đúng chỗ.
+// FurthestPageID là trang xa nhất từng đọc tới, dùng cho thống kê tỉ lệ đọc hết từng trang của tác giả.
+type ReadingProgress struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `gorm:"index" json:"updated_at"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_reading_progress_user_book,priority:1" json:"user_id"`
+	BookID         uint      `gorm:"not null;index;uniqueIndex:idx_reading_progress_user_book,priority:2" json:"book_id"`
+	PageID         uint      `gorm:"not null" json:"page_id"`
+	ScrollPercent  int       `gorm:"not null;default:0" json:"scroll_percent"` // Vị trí cuộn trong trang, 0-100
+	FurthestPageID uint      `gorm:"not null;index" json:"furthest_page_id"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode là mã khôi phục dùng một lần khi người dùng mất thiết bị tạo mã TOTP.
+// Chỉ lưu SHA-256 của mã; mã gốc chỉ hiển thị một lần khi tạo.
+type RecoveryCode struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint       `gorm:"not null;index"`
+	CodeHash  string     `gorm:"size:64;not null;uniqueIndex"`
+	UsedAt    *time.Time // nil: chưa dùng
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SessionRecord lưu dữ liệu phiên (đã mã hóa bởi Fiber) khi dùng Postgres làm session storage.
+type SessionRecord struct {
+	ID        string    `gorm:"primaryKey;size:128"`
+	Data      []byte    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+func (SessionRecord) TableName() string {
+	return "sessions"
+}
+
+// UserSession theo dõi các phiên đăng nhập của người dùng để hiển thị và đăng xuất từ xa,
+// độc lập với backend lưu dữ liệu phiên (bộ nhớ, Postgres hay Redis).
+type UserSession struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	SessionID  string    `gorm:"size:128;not null;uniqueIndex"`
+	UserID     uint      `gorm:"not null;index"`
+	UserAgent  string    `gorm:"size:512"`
+	IPAddress  string    `gorm:"size:64"`
+	LastSeenAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null;index"`
+}
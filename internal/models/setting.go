@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Khóa các thiết lập hệ thống do admin chỉnh trong trang quản trị.
+const (
+	// SettingTwoFactorRoles: danh sách vai trò bắt buộc bật 2FA, phân tách bằng dấu phẩy.
+	SettingTwoFactorRoles = "two_factor_required_roles"
+)
+
+// Setting lưu một thiết lập hệ thống dạng khóa/giá trị.
+type Setting struct {
+	Key       string `gorm:"primaryKey;size:64"`
+	Value     string `gorm:"size:1000;not null"`
+	UpdatedAt time.Time
+}
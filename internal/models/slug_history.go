@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Loại tài nguyên có slug.
+const (
+	SlugResourcePost = "post"
+	SlugResourceBook = "book"
+)
+
+// SlugHistory lưu các slug cũ để link đã chia sẻ vẫn hoạt động sau khi đổi tên.
+type SlugHistory struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	ResourceType string `gorm:"size:20;not null;uniqueIndex:idx_slug_histories_resource_slug"`
+	Slug         string `gorm:"size:100;not null;uniqueIndex:idx_slug_histories_resource_slug"`
+	ResourceID   uint   `gorm:"not null;index"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Tag là nhãn phân loại dùng chung cho bài viết và sách, tên luôn ở dạng chữ thường.
+type Tag struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Name      string     `gorm:"size:60;not null;uniqueIndex" json:"name"`
+	Aliases   []TagAlias `gorm:"foreignKey:TagID" json:"aliases,omitempty"`
+}
+
+// TagAlias ánh xạ tên gọi khác (ví dụ "k8s") về tag chuẩn ("kubernetes").
+type TagAlias struct {
+	ID    uint   `gorm:"primarykey" json:"id"`
+	Alias string `gorm:"size:60;not null;uniqueIndex" json:"alias"`
+	TagID uint   `gorm:"not null;index" json:"tag_id"`
+}
@@ -1,15 +1,76 @@
 package models
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Vai trò người dùng. Quyền cụ thể của từng vai trò nằm ở package policy.
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleAuthor    = "author"
+	RoleReader    = "reader"
+)
+
+// Roles liệt kê các vai trò theo thứ tự quyền giảm dần.
+var Roles = []string{RoleAdmin, RoleModerator, RoleAuthor, RoleReader}
+
 // User lưu thông tin người dùng có thể đăng nhập hệ thống.
 type User struct {
 	gorm.Model
 	Name         string `gorm:"size:120"`
 	Email        string `gorm:"size:120;uniqueIndex"`
+	Handle       string `gorm:"size:40;uniqueIndex:idx_users_handle,where:handle <> ''"` // Tên hiển thị trên URL hồ sơ /u/:handle, chữ thường
+	JobTitle     string `gorm:"size:120"`
+	Bio          string `gorm:"type:text"`
+	Links        string `gorm:"type:text"` // Mỗi dòng một URL
+	AvatarImageID *uint // Ảnh đại diện trong bảng images, nil: dùng chữ cái đầu của tên
 	PasswordHash string `gorm:"size:255"`
+	EmailVerifiedAt *time.Time // nil: chưa xác minh email, chưa được đăng bài
+	Role         string `gorm:"size:20;not null;default:author;index"`
+	SuspendedAt  *time.Time `gorm:"index"` // nil: tài khoản đang hoạt động
+	SuspendedReason string `gorm:"size:255"`
+	TOTPSecret   string     `gorm:"column:totp_secret;size:64"` // Khóa TOTP (base32); có giá trị nhưng TOTPEnabledAt nil là đang chờ xác nhận
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at"`    // nil: chưa bật xác thực hai lớp
+	TOTPLastStep int64      `gorm:"column:totp_last_step"`      // Bước thời gian của mã đã dùng gần nhất, chống dùng lại mã
 	Posts        []Post    `gorm:"foreignKey:AuthorID"`
 	Comments     []Comment `gorm:"foreignKey:AuthorID"`
 }
+
+// EmailVerified cho biết người dùng đã xác minh email chưa.
+func (u User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// Suspended cho biết tài khoản đang bị quản trị viên khóa.
+func (u User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// TwoFactorEnabled cho biết người dùng đã bật xác thực hai lớp (TOTP).
+func (u User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil && u.TOTPSecret != ""
+}
+
+// ProfileLinks trả danh sách liên kết cá nhân, bỏ dòng trống.
+func (u User) ProfileLinks() []string {
+	var links []string
+	for _, line := range strings.Split(u.Links, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			links = append(links, line)
+		}
+	}
+	return links
+}
+
+// AvatarURL trả đường dẫn ảnh đại diện, rỗng nếu chưa có.
+func (u User) AvatarURL() string {
+	if u.AvatarImageID == nil {
+		return ""
+	}
+	return fmt.Sprintf("/images/%d", *u.AvatarImageID)
+}
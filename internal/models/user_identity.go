@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity liên kết tài khoản với một danh tính bên ngoài (OIDC, GitHub).
+// Mỗi cặp provider + subject chỉ gắn với một tài khoản.
+type UserIdentity struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint   `gorm:"not null;index"`
+	Provider  string `gorm:"size:64;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject   string `gorm:"size:255;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Email     string `gorm:"size:120"`
+}
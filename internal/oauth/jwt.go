@@ -0,0 +1,279 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew là độ lệch đồng hồ cho phép khi kiểm tra exp/iat.
+const clockSkew = time.Minute
+
+// jwksRefreshInterval giới hạn tần suất tải lại JWKS khi gặp kid lạ (IdP xoay khóa).
+const jwksRefreshInterval = time.Minute
+
+// flexBool chấp nhận email_verified dạng bool hoặc chuỗi "true" (một số IdP trả về chuỗi).
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	*b = flexBool(strings.Trim(string(data), `"`) == "true")
+	return nil
+}
+
+// audience chấp nhận aud dạng chuỗi hoặc mảng.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+type idTokenClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// verifyIDToken kiểm tra chữ ký (RS256/RS384/RS512/ES256/ES384) theo JWKS của provider
+// và các claim bắt buộc theo OpenID Connect Core.
+func (p *Provider) verifyIDToken(ctx context.Context, raw, nonce string) (*idTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("id_token không đúng định dạng JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header id_token không hợp lệ: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("chữ ký id_token không hợp lệ")
+	}
+	key, err := p.keys.find(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("payload id_token không hợp lệ: %w", err)
+	}
+	now := time.Now()
+	switch {
+	case strings.TrimRight(claims.Issuer, "/") != p.Issuer:
+		return nil, fmt.Errorf("issuer id_token không khớp: %s", claims.Issuer)
+	case !claims.Audience.contains(p.ClientID):
+		return nil, errors.New("id_token không dành cho ứng dụng này")
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, errors.New("id_token đã hết hạn")
+	case claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return nil, errors.New("id_token được phát hành trong tương lai")
+	case claims.Nonce != nonce:
+		return nil, errors.New("nonce id_token không khớp")
+	case claims.Subject == "":
+		return nil, errors.New("id_token thiếu sub")
+	}
+	return &claims, nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("thuật toán ký không hỗ trợ: %s", alg)
+	}
+	digest := hashBytes(hash, signed)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return errors.New("chữ ký id_token không hợp lệ")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			break
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("chữ ký id_token không hợp lệ")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("chữ ký id_token không hợp lệ")
+		}
+		return nil
+	}
+	return fmt.Errorf("khóa không phù hợp với thuật toán %s", alg)
+}
+
+func hashBytes(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// keySet giữ các khóa công khai tải từ jwks_uri.
+type keySet struct {
+	url string
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func (ks *keySet) find(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if ks == nil {
+		return nil, errors.New("provider chưa có JWKS")
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if key := ks.lookup(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(ks.fetchedAt) < jwksRefreshInterval && ks.keys != nil {
+		return nil, fmt.Errorf("không tìm thấy khóa ký %q", kid)
+	}
+	if err := ks.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key := ks.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("không tìm thấy khóa ký %q", kid)
+}
+
+// lookup tìm khóa theo kid; token không có kid chỉ hợp lệ khi JWKS có đúng một khóa.
+func (ks *keySet) lookup(kid string) crypto.PublicKey {
+	if kid != "" {
+		return ks.keys[kid]
+	}
+	if len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func (ks *keySet) refresh(ctx context.Context) error {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, ks.url, "", &doc); err != nil {
+		return fmt.Errorf("không tải được JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	ks.keys = keys
+	ks.fetchedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,330 @@
+// Package oauth cài đặt đăng nhập OAuth2 authorization code + PKCE, kèm xác thực ID token
+// cho nhà cung cấp OpenID Connect (IdP công ty, mock OIDC server) và luồng OAuth2 riêng của GitHub.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Provider là một nhà cung cấp đăng nhập đã cấu hình.
+type Provider struct {
+	Name         string // Định danh dùng trong URL và bảng user_identities
+	DisplayName  string
+	Type         string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	Scopes       []string
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+
+	mu         sync.Mutex
+	discovered bool
+	keys       *keySet
+}
+
+// Identity là thông tin tài khoản bên ngoài sau khi đăng nhập thành công.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Token là phản hồi từ token endpoint.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// LoadProviders đọc danh sách provider từ OAUTH_PROVIDERS (vd. "company,github") và các biến
+// OAUTH_<TÊN>_TYPE, _CLIENT_ID, _CLIENT_SECRET, _ISSUER, _SCOPES, _DISPLAY_NAME.
+// TYPE mặc định là github với provider tên github, còn lại là oidc.
+func LoadProviders() ([]*Provider, error) {
+	var providers []*Provider
+	for _, name := range strings.Split(os.Getenv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		env := func(key string) string {
+			return strings.TrimSpace(os.Getenv("OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + key))
+		}
+
+		p := &Provider{
+			Name:         name,
+			DisplayName:  env("DISPLAY_NAME"),
+			Type:         strings.ToLower(env("TYPE")),
+			ClientID:     env("CLIENT_ID"),
+			ClientSecret: env("CLIENT_SECRET"),
+			Issuer:       strings.TrimRight(env("ISSUER"), "/"),
+		}
+		if p.Type == "" {
+			p.Type = TypeOIDC
+			if name == TypeGitHub {
+				p.Type = TypeGitHub
+			}
+		}
+		if p.DisplayName == "" {
+			p.DisplayName = name
+		}
+		if scopes := env("SCOPES"); scopes != "" {
+			p.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		if p.ClientID == "" {
+			return nil, fmt.Errorf("OAuth provider %s thiếu CLIENT_ID", name)
+		}
+
+		switch p.Type {
+		case TypeOIDC:
+			if p.Issuer == "" {
+				return nil, fmt.Errorf("OAuth provider %s thiếu ISSUER", name)
+			}
+			if p.Scopes == nil {
+				p.Scopes = []string{"openid", "email", "profile"}
+			}
+		case TypeGitHub:
+			p.AuthURL = "https://github.com/login/oauth/authorize"
+			p.TokenURL = "https://github.com/login/oauth/access_token"
+			p.UserInfoURL = "https://api.github.com/user"
+			if p.Scopes == nil {
+				p.Scopes = []string{"read:user", "user:email"}
+			}
+		default:
+			return nil, fmt.Errorf("OAuth provider %s có TYPE không hỗ trợ: %s", name, p.Type)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// discover đọc cấu hình từ {issuer}/.well-known/openid-configuration.
+// Kết quả được giữ lại sau lần thành công đầu tiên; lỗi thì request sau sẽ thử lại.
+func (p *Provider) discover(ctx context.Context) error {
+	if p.Type != TypeOIDC {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered {
+		return nil
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return fmt.Errorf("không đọc được cấu hình OIDC của %s: %w", p.Name, err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != p.Issuer {
+		return fmt.Errorf("issuer không khớp: cấu hình %s, discovery trả về %s", p.Issuer, doc.Issuer)
+	}
+	p.AuthURL = doc.AuthorizationEndpoint
+	p.TokenURL = doc.TokenEndpoint
+	p.UserInfoURL = doc.UserInfoEndpoint
+	p.JWKSURL = doc.JWKSURI
+	p.keys = &keySet{url: doc.JWKSURI}
+	p.discovered = true
+	return nil
+}
+
+// AuthCodeURL trả về URL chuyển người dùng sang trang đăng nhập của provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, verifier string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if p.Type == TypeOIDC {
+		q.Set("nonce", nonce)
+	}
+	sep := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.AuthURL + sep + q.Encode(), nil
+}
+
+// Exchange đổi authorization code lấy token, gửi kèm PKCE verifier.
+func (p *Provider) Exchange(ctx context.Context, code, redirectURI, verifier string) (*Token, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token Token
+	if err := doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("đổi code lấy token thất bại: %w", err)
+	}
+	if token.AccessToken == "" && token.IDToken == "" {
+		return nil, errors.New("token endpoint không trả về token")
+	}
+	return &token, nil
+}
+
+// Identity xác định tài khoản bên ngoài từ token. Với OIDC, ID token phải hợp lệ
+// (chữ ký, issuer, audience, hạn dùng, nonce); với GitHub, đọc từ API người dùng.
+func (p *Provider) Identity(ctx context.Context, token *Token, nonce string) (*Identity, error) {
+	switch p.Type {
+	case TypeOIDC:
+		return p.oidcIdentity(ctx, token, nonce)
+	case TypeGitHub:
+		return githubIdentity(ctx, token.AccessToken)
+	}
+	return nil, fmt.Errorf("provider không hỗ trợ: %s", p.Type)
+}
+
+func (p *Provider) oidcIdentity(ctx context.Context, token *Token, nonce string) (*Identity, error) {
+	if token.IDToken == "" {
+		return nil, errors.New("provider không trả về id_token")
+	}
+	claims, err := p.verifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	identity := &Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}
+	// Một số IdP chỉ trả email/tên qua userinfo
+	if (identity.Email == "" || identity.Name == "") && p.UserInfoURL != "" && token.AccessToken != "" {
+		var info idTokenClaims
+		if err := getJSON(ctx, p.UserInfoURL, token.AccessToken, &info); err == nil && info.Subject == identity.Subject {
+			if identity.Email == "" {
+				identity.Email = info.Email
+				identity.EmailVerified = bool(info.EmailVerified)
+			}
+			if identity.Name == "" {
+				identity.Name = info.Name
+			}
+		}
+	}
+	if identity.Name == "" {
+		identity.Name = claims.PreferredUsername
+	}
+	return identity, nil
+}
+
+func githubIdentity(ctx context.Context, accessToken string) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, "https://api.github.com/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, "https://api.github.com/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+	identity := &Identity{Subject: fmt.Sprint(user.ID), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email, identity.EmailVerified = e.Email, e.Verified
+		}
+	}
+	return identity, nil
+}
+
+// NewVerifier sinh chuỗi ngẫu nhiên dùng cho state, nonce và PKCE code verifier.
+func NewVerifier() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// Challenge tính PKCE code_challenge theo phương thức S256.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func getJSON(ctx context.Context, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return doJSON(req, out)
+}
+
+func doJSON(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: HTTP %d: %s", req.Method, req.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,171 @@
+// Package policy tập trung quy tắc phân quyền: người dùng nào được làm hành động gì với tài nguyên nào.
+// Handler không tự so sánh AuthorID mà luôn hỏi Can(user, action, resource).
+package policy
+
+import (
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/models"
+)
+
+// Action là hành động cần kiểm tra quyền.
+type Action string
+
+const (
+	View   Action = "view"
+	Create Action = "create"
+	Edit   Action = "edit"
+	Delete Action = "delete"
+	Hide   Action = "hide" // Ẩn/hiện nội dung vi phạm khỏi nơi công khai
+)
+
+// Kind là loại tài nguyên, dùng khi chưa có bản ghi cụ thể (vd. kiểm tra quyền tạo mới).
+type Kind string
+
+const (
+	Posts      Kind = "posts"
+	Comments   Kind = "comments"
+	Books      Kind = "books"
+	Images     Kind = "images"
+	Highlights Kind = "highlights"
+)
+
+// IsStaff cho biết người dùng là admin hoặc moderator, được quản lý nội dung của mọi người.
+func IsStaff(user *models.User) bool {
+	return user != nil && !user.Suspended() && (user.Role == models.RoleAdmin || user.Role == models.RoleModerator)
+}
+
+// IsAdmin cho biết người dùng có vai trò admin.
+func IsAdmin(user *models.User) bool {
+	return user != nil && !user.Suspended() && user.Role == models.RoleAdmin
+}
+
+// canAuthor cho biết vai trò được tự đăng nội dung (bài viết, sách, ảnh). Reader chỉ đọc và bình luận.
+func canAuthor(user *models.User) bool {
+	if user == nil {
+		return false
+	}
+	switch user.Role {
+	case models.RoleAdmin, models.RoleModerator, models.RoleAuthor:
+		return true
+	}
+	return false
+}
+
+func owns(user *models.User, ownerID uint) bool {
+	return user != nil && ownerID != 0 && user.ID == ownerID
+}
+
+// Can trả về true nếu user (nil: khách) được thực hiện action trên resource.
+// resource là một Kind, hoặc model (giá trị hay con trỏ) Post, Comment, Book, Highlight, Image.
+// Tài khoản bị khóa được coi như khách.
+func Can(user *models.User, action Action, resource interface{}) bool {
+	if user != nil && user.Suspended() {
+		user = nil
+	}
+	switch r := resource.(type) {
+	case Kind:
+		return canKind(user, action, r)
+	case models.Post:
+		return canPost(user, action, r)
+	case *models.Post:
+		return r != nil && canPost(user, action, *r)
+	case models.Comment:
+		return canComment(user, action, r)
+	case *models.Comment:
+		return r != nil && canComment(user, action, *r)
+	case models.Book:
+		return canBook(user, action, r)
+	case *models.Book:
+		return r != nil && canBook(user, action, *r)
+	case models.Highlight:
+		return canHighlight(user, action, r)
+	case *models.Highlight:
+		return r != nil && canHighlight(user, action, *r)
+	case models.Image:
+		return canImage(user, action, r)
+	case *models.Image:
+		return r != nil && canImage(user, action, *r)
+	}
+	return false
+}
+
+func canKind(user *models.User, action Action, kind Kind) bool {
+	if action != Create {
+		return false
+	}
+	switch kind {
+	case Posts, Books, Images:
+		return canAuthor(user)
+	case Comments, Highlights:
+		return user != nil
+	}
+	return false
+}
+
+// Bài viết nháp, đã lên lịch hoặc bị ẩn chỉ hiển thị cho tác giả và ban quản trị.
+func canPost(user *models.User, action Action, post models.Post) bool {
+	switch action {
+	case View:
+		return (post.IsPublished() && !post.Hidden) || owns(user, post.AuthorID) || IsStaff(user)
+	case Edit, Delete:
+		return owns(user, post.AuthorID) || IsStaff(user)
+	case Hide:
+		return IsStaff(user)
+	}
+	return false
+}
+
+func canComment(user *models.User, action Action, comment models.Comment) bool {
+	switch action {
+	case View:
+		return !comment.Hidden || owns(user, comment.AuthorID) || IsStaff(user)
+	case Edit, Delete:
+		return owns(user, comment.AuthorID) || IsStaff(user)
+	case Hide:
+		return IsStaff(user)
+	}
+	return false
+}
+
+func canBook(user *models.User, action Action, book models.Book) bool {
+	switch action {
+	case View:
+		return (book.Published && !book.Hidden) || owns(user, book.AuthorID) || IsStaff(user)
+	case Edit, Delete:
+		return owns(user, book.AuthorID) || IsStaff(user)
+	case Hide:
+		return IsStaff(user)
+	}
+	return false
+}
+
+// Highlight của tác giả sách hiển thị công khai như ghi chú, nên ban quản trị cũng được xóa.
+func canHighlight(user *models.User, action Action, highlight models.Highlight) bool {
+	switch action {
+	case Edit, Delete:
+		return owns(user, highlight.UserID) || IsStaff(user)
+	}
+	return false
+}
+
+func canImage(user *models.User, action Action, image models.Image) bool {
+	switch action {
+	case View:
+		return true
+	case Delete:
+		return owns(user, image.UploaderID) || IsStaff(user)
+	}
+	return false
+}
+
+// VisibleBooks giới hạn truy vấn sách ở những cuốn user được xem, tương ứng Can(user, View, book).
+func VisibleBooks(db *gorm.DB, user *models.User) *gorm.DB {
+	switch {
+	case IsStaff(user):
+		return db
+	case user != nil:
+		return db.Where("(books.published = ? AND books.hidden = ?) OR books.author_id = ?", true, false, user.ID)
+	}
+	return db.Where("books.published = ? AND books.hidden = ?", true, false)
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/models"
+)
+
+// GormStore lưu bộ đếm vào bảng rate_limit_counters. Mỗi lần tăng là một câu upsert
+// nên các replica cùng đếm trên một bộ đếm mà không cần khóa.
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore tạo store dùng bảng rate_limit_counters (đã được migrate cùng các model khác).
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) Incr(key string, window time.Duration, now time.Time) (Counter, error) {
+	var counter models.RateLimitCounter
+	err := s.db.Raw(`
+		INSERT INTO rate_limit_counters (key, count, reset_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN rate_limit_counters.reset_at <= ? THEN 1 ELSE rate_limit_counters.count + 1 END,
+			reset_at = CASE WHEN rate_limit_counters.reset_at <= ? THEN EXCLUDED.reset_at ELSE rate_limit_counters.reset_at END
+		RETURNING key, count, reset_at`,
+		key, now.Add(window), now, now,
+	).Scan(&counter).Error
+	if err != nil {
+		return Counter{}, err
+	}
+	return Counter{Count: counter.Count, ResetAt: counter.ResetAt}, nil
+}
+
+func (s *GormStore) Peek(key string, now time.Time) (Counter, error) {
+	var counter models.RateLimitCounter
+	err := s.db.Where("key = ? AND reset_at > ?", key, now).First(&counter).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Counter{}, nil
+	}
+	if err != nil {
+		return Counter{}, err
+	}
+	return Counter{Count: counter.Count, ResetAt: counter.ResetAt}, nil
+}
+
+func (s *GormStore) Reset(key string) error {
+	return s.db.Where("key = ?", key).Delete(&models.RateLimitCounter{}).Error
+}
+
+// DeleteExpired xóa các bộ đếm đã hết cửa sổ trước thời điểm now.
+func (s *GormStore) DeleteExpired(now time.Time) (int64, error) {
+	result := s.db.Where("reset_at <= ?", now).Delete(&models.RateLimitCounter{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// memorySweepInterval là chu kỳ dọn bộ đếm hết hạn khỏi map.
+const memorySweepInterval = time.Minute
+
+// MemoryStore giữ bộ đếm trong bộ nhớ tiến trình. Chỉ chính xác khi chạy một replica.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]Counter
+	lastSweep time.Time
+}
+
+// NewMemoryStore tạo store rỗng.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]Counter)}
+}
+
+func (s *MemoryStore) Incr(key string, window time.Duration, now time.Time) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	counter, ok := s.counters[key]
+	if !ok || !now.Before(counter.ResetAt) {
+		counter = Counter{ResetAt: now.Add(window)}
+	}
+	counter.Count++
+	s.counters[key] = counter
+	return counter, nil
+}
+
+func (s *MemoryStore) Peek(key string, now time.Time) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter, ok := s.counters[key]
+	if !ok || !now.Before(counter.ResetAt) {
+		return Counter{}, nil
+	}
+	return counter, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.counters, key)
+	return nil
+}
+
+// sweep xóa bộ đếm hết hạn, tối đa mỗi phút một lần; gọi khi đang giữ mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	for key, counter := range s.counters {
+		if !now.Before(counter.ResetAt) {
+			delete(s.counters, key)
+		}
+	}
+	s.lastSweep = now
+}
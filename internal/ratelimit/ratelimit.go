@@ -0,0 +1,95 @@
+// Package ratelimit đếm số request theo khóa (IP, tài khoản) trong cửa sổ thời gian cố định.
+// Bộ đếm nằm trong Store thay thế được: bộ nhớ tiến trình, hoặc Postgres để nhiều replica dùng chung.
+package ratelimit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Counter là trạng thái của một khóa trong cửa sổ hiện tại.
+type Counter struct {
+	Count   int
+	ResetAt time.Time
+}
+
+// Store lưu bộ đếm. Incr phải nguyên tử để nhiều request (hoặc replica) đồng thời không đếm thiếu.
+type Store interface {
+	// Incr tăng bộ đếm của key; nếu cửa sổ cũ đã hết hạn thì mở cửa sổ mới dài window.
+	Incr(key string, window time.Duration, now time.Time) (Counter, error)
+	// Peek đọc bộ đếm mà không tăng; khóa chưa có hoặc đã hết hạn trả về Counter rỗng.
+	Peek(key string, now time.Time) (Counter, error)
+	// Reset xóa bộ đếm của key.
+	Reset(key string) error
+}
+
+// Expirer được cài bởi các store cần job định kỳ để xóa bộ đếm hết hạn.
+type Expirer interface {
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// Policy là giới hạn Limit request trong mỗi cửa sổ Window.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// String trả về dạng "10/1m0s", cùng cú pháp với biến môi trường.
+func (p Policy) String() string {
+	return fmt.Sprintf("%d/%s", p.Limit, p.Window)
+}
+
+// ParsePolicy đọc chuỗi dạng "10/1m" (10 request mỗi phút) hoặc "5/1h".
+func ParsePolicy(name, value string) (Policy, error) {
+	limit, window, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %s phải có dạng <số request>/<thời gian>, vd. 10/1m", name)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s có số request không hợp lệ: %s", name, limit)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s có thời gian không hợp lệ: %s", name, window)
+	}
+	return Policy{Name: name, Limit: n, Window: d}, nil
+}
+
+// PolicyFromEnv trả về policy mặc định, bị ghi đè bởi RATE_LIMIT_<TÊN> nếu có (vd. RATE_LIMIT_LOGIN=10/1m).
+func PolicyFromEnv(def Policy) (Policy, error) {
+	value := strings.TrimSpace(os.Getenv("RATE_LIMIT_" + strings.ToUpper(strings.ReplaceAll(def.Name, "-", "_"))))
+	if value == "" {
+		return def, nil
+	}
+	return ParsePolicy(def.Name, value)
+}
+
+// Allow tăng bộ đếm của key theo policy và cho biết request còn nằm trong giới hạn không.
+func Allow(store Store, policy Policy, key string, now time.Time) (Counter, bool, error) {
+	counter, err := store.Incr(policy.Name+":"+key, policy.Window, now)
+	if err != nil {
+		return Counter{}, false, err
+	}
+	return counter, counter.Count <= policy.Limit, nil
+}
+
+// FromEnv chọn store theo biến môi trường RATE_LIMIT_STORE:
+//   - "database" (mặc định): bảng rate_limit_counters trên Postgres, dùng chung giữa các replica
+//   - "memory": bộ nhớ tiến trình, mỗi replica đếm riêng
+func FromEnv(db *gorm.DB) (Store, error) {
+	driver := strings.ToLower(strings.TrimSpace(os.Getenv("RATE_LIMIT_STORE")))
+	switch driver {
+	case "", "database", "db", "postgres":
+		return NewGormStore(db), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("RATE_LIMIT_STORE không hỗ trợ: %s", driver)
+}
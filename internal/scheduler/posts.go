@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/models"
+)
+
+// PublishDuePosts chuyển các bài viết đã lên lịch sang trạng thái published khi đến giờ.
+func PublishDuePosts(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Model(&models.Post{}).
+		Where("status = ? AND publish_at <= ?", models.PostStatusScheduled, now).
+		Update("status", models.PostStatusPublished)
+	return result.RowsAffected, result.Error
+}
+
+// StartPostPublisher định kỳ xuất bản các bài viết đã lên lịch.
+func StartPostPublisher(db *gorm.DB, interval time.Duration) {
+	Every("publish-scheduled-posts", interval, func() error {
+		count, err := PublishDuePosts(db, time.Now())
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Printf("📰 Published %d scheduled post(s)", count)
+		}
+		return nil
+	})
+}
+
+var imageRefPattern = regexp.MustCompile(`/images/(\d+)`)
+
+// referencedImageIDs trả về ID các ảnh (/images/:id) được dùng trong nội dung.
+func referencedImageIDs(texts ...string) []uint {
+	seen := make(map[uint]bool)
+	ids := make([]uint, 0)
+	for _, text := range texts {
+		for _, match := range imageRefPattern.FindAllStringSubmatch(text, -1) {
+			id, err := strconv.ParseUint(match[1], 10, 64)
+			if err != nil || seen[uint(id)] {
+				continue
+			}
+			seen[uint(id)] = true
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// imageUsedElsewhere kiểm tra ảnh còn được bài viết khác (kể cả lịch sử chỉnh sửa), bình luận
+// ở bài khác, sách hay ảnh đại diện của người dùng nào tham chiếu không.
+func imageUsedElsewhere(tx *gorm.DB, imageID, postID uint) (bool, error) {
+	pattern := fmt.Sprintf("/images/%d([^0-9]|$)", imageID)
+
+	var count int64
+	if err := tx.Unscoped().Model(&models.Post{}).
+		Where("id <> ? AND (content ~ ? OR cover_url ~ ?)", postID, pattern, pattern).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	// Khôi phục phiên bản cũ của bài khác sẽ đưa ảnh trở lại nội dung
+	if err := tx.Unscoped().Model(&models.PostRevision{}).
+		Where("post_id <> ? AND content ~ ?", postID, pattern).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := tx.Unscoped().Model(&models.Comment{}).
+		Where("post_id <> ? AND content ~ ?", postID, pattern).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := tx.Unscoped().Model(&models.User{}).Where("avatar_image_id = ?", imageID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := tx.Unscoped().Model(&models.BookPage{}).Where("content ~ ?", pattern).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := tx.Unscoped().Model(&models.Book{}).Where("cover_url ~ ?", pattern).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PurgePost xóa vĩnh viễn bài viết cùng bình luận, chú thích, lịch sử chỉnh sửa, liên kết tag,
+// slug cũ và các ảnh do chính tác giả upload mà chỉ bài viết này sử dụng.
+func PurgePost(db *gorm.DB, postID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var post models.Post
+		if err := tx.Unscoped().First(&post, postID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("post_id = ?", post.ID).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("post_id = ?", post.ID).Delete(&models.Annotation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("post_id = ?", post.ID).Delete(&models.PostRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&post).Association("TagList").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Where("resource_type = ? AND resource_id = ?", models.SlugResourcePost, post.ID).
+			Delete(&models.SlugHistory{}).Error; err != nil {
+			return err
+		}
+
+		for _, imageID := range referencedImageIDs(post.Content, post.CoverURL) {
+			used, err := imageUsedElsewhere(tx, imageID, post.ID)
+			if err != nil {
+				return err
+			}
+			if used {
+				continue
+			}
+			// Ảnh của người khác được nhúng vào bài không thuộc quyền tác giả, không được xóa theo bài
+			if err := tx.Unscoped().Where("uploader_id = ?", post.AuthorID).Delete(&models.Image{}, imageID).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Delete(&post).Error
+	})
+}
+
+// PurgeTrashedPosts xóa vĩnh viễn các bài viết đã nằm trong thùng rác lâu hơn retention.
+func PurgeTrashedPosts(db *gorm.DB, retention time.Duration) (int, error) {
+	var ids []uint
+	if err := db.Unscoped().Model(&models.Post{}).
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", time.Now().Add(-retention)).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		if err := PurgePost(db, id); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartTrashPurger định kỳ dọn thùng rác bài viết.
+func StartTrashPurger(db *gorm.DB, interval, retention time.Duration) {
+	Every("purge-trashed-posts", interval, func() error {
+		count, err := PurgeTrashedPosts(db, retention)
+		if count > 0 {
+			log.Printf("🗑️  Purged %d trashed post(s)", count)
+		}
+		return err
+	})
+}
@@ -0,0 +1,19 @@
+package scheduler
+
+import (
+	"time"
+
+	"fiber-learning-community/internal/ratelimit"
+)
+
+// StartRateLimitExpirer định kỳ dọn bộ đếm rate limit hết hạn (với store cần dọn thủ công).
+func StartRateLimitExpirer(store ratelimit.Store, interval time.Duration) {
+	expirer, ok := store.(ratelimit.Expirer)
+	if !ok {
+		return
+	}
+	Every("expire-rate-limits", interval, func() error {
+		_, err := expirer.DeleteExpired(time.Now())
+		return err
+	})
+}
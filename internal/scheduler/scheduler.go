@@ -0,0 +1,30 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// Every chạy job theo chu kỳ interval trong một goroutine riêng.
+// Job được chạy ngay một lần khi khởi động để không phải chờ chu kỳ đầu tiên.
+func Every(name string, interval time.Duration, job func() error) {
+	go func() {
+		run := func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("⚠️  Job %s panic: %v", name, r)
+				}
+			}()
+			if err := job(); err != nil {
+				log.Printf("⚠️  Job %s failed: %v", name, err)
+			}
+		}
+
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			run()
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"fiber-learning-community/internal/models"
+	"fiber-learning-community/internal/sessionstore"
+)
+
+// ExpireSessions xóa dữ liệu phiên hết hạn (với backend cần dọn thủ công)
+// và các bản ghi phiên đăng nhập đã hết hạn của người dùng.
+func ExpireSessions(db *gorm.DB, storage fiber.Storage, now time.Time) (int64, error) {
+	var total int64
+	if expirer, ok := storage.(sessionstore.Expirer); ok {
+		count, err := expirer.DeleteExpired(now)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	result := db.Where("expires_at <= ?", now).Delete(&models.UserSession{})
+	return total + result.RowsAffected, result.Error
+}
+
+// StartSessionExpirer định kỳ dọn phiên hết hạn.
+func StartSessionExpirer(db *gorm.DB, storage fiber.Storage, interval time.Duration) {
+	Every("expire-sessions", interval, func() error {
+		count, err := ExpireSessions(db, storage, time.Now())
+		if count > 0 {
+			log.Printf("🔑 Expired %d session record(s)", count)
+		}
+		return err
+	})
+}
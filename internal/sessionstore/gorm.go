@@ -0,0 +1,76 @@
+package sessionstore
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"fiber-learning-community/internal/models"
+)
+
+// noExpiry là hạn dùng cho dữ liệu Fiber lưu với exp = 0 (không hết hạn).
+const noExpiry = 100 * 365 * 24 * time.Hour
+
+// GormStorage lưu phiên vào bảng sessions qua kết nối GORM sẵn có.
+// Dữ liệu hết hạn bị bỏ qua khi đọc và được dọn bởi DeleteExpired.
+type GormStorage struct {
+	db *gorm.DB
+}
+
+// NewGormStorage tạo storage dùng bảng sessions (đã được migrate cùng các model khác).
+func NewGormStorage(db *gorm.DB) *GormStorage {
+	return &GormStorage{db: db}
+}
+
+func (s *GormStorage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+	var record models.SessionRecord
+	err := s.db.Where("id = ? AND expires_at > ?", key, time.Now()).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record.Data, nil
+}
+
+func (s *GormStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	if exp <= 0 {
+		exp = noExpiry
+	}
+	record := models.SessionRecord{ID: key, Data: val, ExpiresAt: time.Now().Add(exp)}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "expires_at"}),
+	}).Create(&record).Error
+}
+
+func (s *GormStorage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	return s.db.Where("id = ?", key).Delete(&models.SessionRecord{}).Error
+}
+
+func (s *GormStorage) Reset() error {
+	return s.db.Where("1 = 1").Delete(&models.SessionRecord{}).Error
+}
+
+// Close không đóng kết nối vì GORM DB được dùng chung với cả ứng dụng.
+func (s *GormStorage) Close() error {
+	return nil
+}
+
+// DeleteExpired xóa các phiên đã hết hạn trước thời điểm now.
+func (s *GormStorage) DeleteExpired(now time.Time) (int64, error) {
+	result := s.db.Where("expires_at <= ?", now).Delete(&models.SessionRecord{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,279 @@
+package sessionstore
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redisDialTimeout    = 5 * time.Second
+	redisCommandTimeout = 3 * time.Second // Mỗi lệnh; quá hạn thì đóng kết nối thay vì treo request
+)
+
+// RedisStorage lưu phiên vào máy chủ tương thích Redis (Redis, Valkey, KeyDB, miniredis...)
+// qua giao thức RESP. Chỉ dùng GET/SET PX/DEL/SCAN nên chạy được với các bản giả lập cục bộ.
+// Redis tự hết hạn khóa theo PX nên không cần job dọn phiên.
+type RedisStorage struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	tls      *tls.Config // nil: kết nối TCP thường (redis://)
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisStorage tạo storage từ URL dạng redis://[:password@]host:port[/db], hoặc rediss://
+// cho kết nối TLS (Redis được quản lý trên cloud). Kết nối được mở khi dùng lần đầu và tự mở lại nếu bị ngắt.
+func NewRedisStorage(rawURL, prefix string) (*RedisStorage, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("REDIS_URL không hợp lệ: %w", err)
+	}
+	s := &RedisStorage{addr: u.Host, prefix: prefix}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		s.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("REDIS_URL phải bắt đầu bằng redis:// hoặc rediss://")
+	}
+	if !strings.Contains(s.addr, ":") {
+		s.addr += ":6379"
+	}
+	if u.User != nil {
+		s.password, _ = u.User.Password()
+		if s.password == "" {
+			s.password = u.User.Username()
+		}
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if s.db, err = strconv.Atoi(path); err != nil {
+			return nil, fmt.Errorf("số database trong REDIS_URL không hợp lệ: %s", path)
+		}
+	}
+	return s, nil
+}
+
+func (s *RedisStorage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+	reply, err := s.do("GET", s.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis GET: kiểu trả về không hợp lệ %T", reply)
+	}
+	return data, nil
+}
+
+func (s *RedisStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	args := []string{"SET", s.prefix + key, string(val)}
+	if exp > 0 {
+		args = append(args, "PX", strconv.FormatInt(exp.Milliseconds(), 10))
+	}
+	_, err := s.do(args...)
+	return err
+}
+
+func (s *RedisStorage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	_, err := s.do("DEL", s.prefix+key)
+	return err
+}
+
+// Reset chỉ xóa các khóa mang prefix phiên, không đụng tới dữ liệu khác trong cùng database.
+func (s *RedisStorage) Reset() error {
+	cursor := "0"
+	for {
+		reply, err := s.do("SCAN", cursor, "MATCH", s.prefix+"*", "COUNT", "100")
+		if err != nil {
+			return err
+		}
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 2 {
+			return errors.New("redis SCAN: kiểu trả về không hợp lệ")
+		}
+		next, _ := parts[0].([]byte)
+		keys, _ := parts[1].([]interface{})
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, k := range keys {
+				if b, ok := k.([]byte); ok {
+					args = append(args, string(b))
+				}
+			}
+			if _, err := s.do(args...); err != nil {
+				return err
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+func (s *RedisStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeConn()
+}
+
+func (s *RedisStorage) closeConn() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn, s.reader = nil, nil
+	return err
+}
+
+// do gửi một lệnh và đọc phản hồi. Lỗi mạng hoặc quá hạn sẽ đóng kết nối; nếu kết nối đó đã mở từ trước
+// (có thể bị server đóng khi rảnh) thì thử lại một lần trên kết nối mới. Các lệnh dùng ở đây đều lặp lại được.
+func (s *RedisStorage) do(args ...string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reused := s.conn != nil
+	reply, err := s.attempt(args)
+	if err != nil && reused && s.conn == nil {
+		reply, err = s.attempt(args)
+	}
+	return reply, err
+}
+
+func (s *RedisStorage) attempt(args []string) (interface{}, error) {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := s.roundTrip(args)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		s.closeConn()
+	}
+	return reply, err
+}
+
+func (s *RedisStorage) connect() error {
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var conn net.Conn
+	var err error
+	if s.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn, s.reader = conn, bufio.NewReader(conn)
+	if s.password != "" {
+		if _, err := s.roundTrip([]string{"AUTH", s.password}); err != nil {
+			s.closeConn()
+			return err
+		}
+	}
+	if s.db != 0 {
+		if _, err := s.roundTrip([]string{"SELECT", strconv.Itoa(s.db)}); err != nil {
+			s.closeConn()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisStorage) roundTrip(args []string) (interface{}, error) {
+	if err := s.conn.SetDeadline(time.Now().Add(redisCommandTimeout)); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := s.conn.Write([]byte(sb.String())); err != nil {
+		return nil, err
+	}
+	return readReply(s.reader)
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readReply đọc một phản hồi RESP2: chuỗi đơn, lỗi, số nguyên, bulk string và mảng.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: phản hồi rỗng")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: phản hồi không hợp lệ %q", line)
+}
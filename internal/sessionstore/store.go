@@ -0,0 +1,57 @@
+// Package sessionstore cung cấp các backend lưu phiên cho middleware session của Fiber,
+// để người dùng không bị đăng xuất khi deploy/khởi động lại và có thể chia sẻ phiên giữa nhiều replica.
+package sessionstore
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"gorm.io/gorm"
+)
+
+const redisKeyPrefix = "session:"
+
+// Expirer được cài bởi các backend cần job định kỳ để xóa phiên hết hạn.
+type Expirer interface {
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// FromEnv chọn backend theo biến môi trường SESSION_STORE:
+//   - "database" (mặc định): bảng sessions trên kết nối Postgres hiện có
+//   - "redis": máy chủ tương thích Redis tại REDIS_URL
+//   - "memory": lưu trong bộ nhớ tiến trình (chỉ nên dùng khi phát triển)
+func FromEnv(db *gorm.DB) (fiber.Storage, error) {
+	driver := strings.ToLower(strings.TrimSpace(os.Getenv("SESSION_STORE")))
+	switch driver {
+	case "", "database", "db", "postgres":
+		return NewGormStorage(db), nil
+	case "redis":
+		rawURL := strings.TrimSpace(os.Getenv("REDIS_URL"))
+		if rawURL == "" {
+			rawURL = "redis://localhost:6379"
+		}
+		storage, err := NewRedisStorage(rawURL, redisKeyPrefix)
+		if err != nil {
+			return nil, err
+		}
+		return storage, nil
+	case "memory":
+		// session.New tự tạo memory storage khi Storage == nil
+		return nil, nil
+	}
+	return nil, fmt.Errorf("SESSION_STORE không hỗ trợ: %s", driver)
+}
+
+// Expiration trả về thời gian sống của phiên, cấu hình qua SESSION_TTL_HOURS (mặc định 24 giờ).
+// Hạn được gia hạn mỗi lần phiên được lưu lại.
+func Expiration() time.Duration {
+	hours, err := time.ParseDuration(strings.TrimSpace(os.Getenv("SESSION_TTL_HOURS")) + "h")
+	if err != nil || hours <= 0 {
+		return session.ConfigDefault.Expiration
+	}
+	return hours
+}
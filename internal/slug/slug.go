@@ -0,0 +1,53 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const maxLength = 80
+
+// Make tạo slug ASCII từ tiêu đề, bỏ dấu tiếng Việt.
+// Ví dụ: "Triển khai Kubernetes" -> "trien-khai-kubernetes".
+func Make(title string) string {
+	var b strings.Builder
+	lastDash := true
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		switch {
+		case r == 'đ' || r == 'Đ':
+			b.WriteRune('d')
+			lastDash = false
+		case unicode.Is(unicode.Mn, r):
+			// Bỏ dấu thanh và dấu phụ sau khi tách tổ hợp
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			lastDash = false
+		default:
+			if !lastDash {
+				b.WriteRune('-')
+				lastDash = true
+			}
+		}
+	}
+
+	result := strings.Trim(b.String(), "-")
+	if len(result) > maxLength {
+		result = strings.Trim(result[:maxLength], "-")
+	}
+	return result
+}
+
+// IsNumeric cho biết slug chỉ gồm chữ số, dễ nhầm với ID cũ trong URL.
+func IsNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,421 @@
+package totp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Bộ mã hóa QR tối giản (ISO/IEC 18004): chế độ byte, mức sửa lỗi M, phiên bản 1-10
+// (tối đa 213 byte), đủ cho link otpauth:// mà không cần thư viện ngoài.
+
+// ErrQRTooLong trả về khi dữ liệu vượt quá sức chứa của phiên bản 10.
+var ErrQRTooLong = errors.New("dữ liệu quá dài để tạo mã QR")
+
+// qrVersion mô tả cách chia khối dữ liệu của một phiên bản ở mức sửa lỗi M.
+type qrVersion struct {
+	ecPerBlock int
+	blocks     []int // số codeword dữ liệu của từng khối
+	alignment  []int // tọa độ tâm các mẫu căn chỉnh
+}
+
+var qrVersions = []qrVersion{
+	1:  {10, []int{16}, nil},
+	2:  {16, []int{28}, []int{6, 18}},
+	3:  {26, []int{44}, []int{6, 22}},
+	4:  {18, []int{32, 32}, []int{6, 26}},
+	5:  {24, []int{43, 43}, []int{6, 30}},
+	6:  {16, []int{27, 27, 27, 27}, []int{6, 34}},
+	7:  {18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	8:  {22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	9:  {22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	10: {26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+func (v qrVersion) dataCodewords() int {
+	total := 0
+	for _, n := range v.blocks {
+		total += n
+	}
+	return total
+}
+
+type qrCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// QRCodeSVG mã hóa text thành ảnh SVG (mỗi ô vuông 1 đơn vị, viền trắng 4 ô).
+func QRCodeSVG(text string) (string, error) {
+	qr, err := encodeQR([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	const border = 4
+	dim := qr.size + border*2
+	var path strings.Builder
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.modules[y][x] {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+border, y+border)
+			}
+		}
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges" role="img" aria-label="Mã QR">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`, dim, dim, path.String()), nil
+}
+
+func encodeQR(data []byte) (*qrCode, error) {
+	version := 0
+	for v := 1; v < len(qrVersions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= qrVersions[v].dataCodewords()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrQRTooLong
+	}
+	info := qrVersions[version]
+
+	// Chuỗi bit: chế độ byte (0100), độ dài, dữ liệu, terminator, rồi byte đệm 0xEC/0x11.
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 == 1)
+		}
+	}
+	appendBits(0x4, 4)
+	if version >= 10 {
+		appendBits(len(data), 16)
+	} else {
+		appendBits(len(data), 8)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	capacity := info.dataCodewords() * 8
+	for i := 0; i < 4 && len(bits) < capacity; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	// Chia khối, tính mã Reed-Solomon cho từng khối rồi xen kẽ các khối.
+	divisor := rsDivisor(info.ecPerBlock)
+	dataBlocks := make([][]byte, len(info.blocks))
+	ecBlocks := make([][]byte, len(info.blocks))
+	offset := 0
+	for i, n := range info.blocks {
+		dataBlocks[i] = codewords[offset : offset+n]
+		ecBlocks[i] = rsRemainder(dataBlocks[i], divisor)
+		offset += n
+	}
+	var final []byte
+	for i := 0; i < info.blocks[len(info.blocks)-1]; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				final = append(final, block[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			final = append(final, block[i])
+		}
+	}
+
+	qr := newQRCode(version)
+	qr.drawFunctionPatterns(version, info)
+	qr.drawCodewords(final)
+
+	best, bestPenalty := -1, 0
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if penalty := qr.penalty(); best < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask) // XOR lần hai để trả về trạng thái chưa mask
+	}
+	qr.applyMask(best)
+	qr.drawFormatBits(best)
+	return qr, nil
+}
+
+func newQRCode(version int) *qrCode {
+	size := version*4 + 17
+	qr := &qrCode{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range qr.modules {
+		qr.modules[i] = make([]bool, size)
+		qr.function[i] = make([]bool, size)
+	}
+	return qr
+}
+
+func (qr *qrCode) set(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+func (qr *qrCode) drawFunctionPatterns(version int, info qrVersion) {
+	for i := 0; i < qr.size; i++ {
+		qr.set(6, i, i%2 == 0)
+		qr.set(i, 6, i%2 == 0)
+	}
+	qr.drawFinder(3, 3)
+	qr.drawFinder(qr.size-4, 3)
+	qr.drawFinder(3, qr.size-4)
+
+	last := len(info.alignment) - 1
+	for i, y := range info.alignment {
+		for j, x := range info.alignment {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	qr.drawFormatBits(0) // giữ chỗ, vẽ lại sau khi chọn mask
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := qr.size-11+i%3, i/3
+			qr.set(a, b, dark)
+			qr.set(b, a, dark)
+		}
+	}
+}
+
+func (qr *qrCode) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= qr.size || y >= qr.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			qr.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawFormatBits ghi mức sửa lỗi M (00) và mask vào hai bản sao thông tin định dạng.
+func (qr *qrCode) drawFormatBits(mask int) {
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		qr.set(8, i, bit(i))
+	}
+	qr.set(8, 7, bit(6))
+	qr.set(8, 8, bit(7))
+	qr.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		qr.set(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.set(8, qr.size-15+i, bit(i))
+	}
+	qr.set(8, qr.size-8, true) // ô tối cố định
+}
+
+// drawCodewords đặt dữ liệu theo đường zigzag hai cột từ góc dưới phải, bỏ qua cột timing.
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if qr.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				qr.modules[y][x] = (data[i>>3]>>(7-uint(i&7)))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty chấm điểm mask theo 4 quy tắc của chuẩn; điểm thấp hơn dễ quét hơn.
+func (qr *qrCode) penalty() int {
+	result := 0
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i < qr.size; i++ {
+			if get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				result += run - 2
+			}
+			run = 1
+		}
+		if run >= 5 {
+			result += run - 2
+		}
+		// Mẫu giống finder 1:1:3:1:1 có 4 ô sáng ở một phía
+		for i := 0; i+11 <= qr.size; i++ {
+			pattern := []bool{true, false, true, true, true, false, true}
+			match := true
+			for k, dark := range pattern {
+				if get(i+k) != dark {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			if !get(i+7) && !get(i+8) && !get(i+9) && !get(i+10) {
+				result += 40
+			}
+			if i >= 4 && !get(i-1) && !get(i-2) && !get(i-3) && !get(i-4) {
+				result += 40
+			}
+		}
+	}
+	for y := 0; y < qr.size; y++ {
+		line(func(i int) bool { return qr.modules[y][i] })
+	}
+	for x := 0; x < qr.size; x++ {
+		line(func(i int) bool { return qr.modules[i][x] })
+	}
+
+	dark := 0
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x+1 < qr.size && y+1 < qr.size {
+				c := qr.modules[y][x]
+				if c == qr.modules[y][x+1] && c == qr.modules[y+1][x] && c == qr.modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+	total := qr.size * qr.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*10
+}
+
+// rsDivisor tạo đa thức sinh Reed-Solomon bậc degree trên GF(2^8) (đa thức 0x11D).
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
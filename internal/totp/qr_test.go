@@ -0,0 +1,376 @@
+package totp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Các test dưới đây giải mã lại mã QR theo ISO/IEC 18004 bằng cài đặt riêng (bảng khối, mask,
+// thứ tự đọc, kiểm tra Reed-Solomon bằng syndrome) thay vì so với chính đầu ra của bộ mã hóa.
+
+// Bảng 9 của chuẩn, mức sửa lỗi M: số codeword sửa lỗi mỗi khối và số codeword dữ liệu của từng khối.
+var specBlocksM = map[int]struct {
+	ec   int
+	data []int
+}{
+	1:  {10, []int{16}},
+	2:  {16, []int{28}},
+	3:  {26, []int{44}},
+	4:  {18, []int{32, 32}},
+	5:  {24, []int{43, 43}},
+	6:  {16, []int{27, 27, 27, 27}},
+	7:  {18, []int{31, 31, 31, 31}},
+	8:  {22, []int{38, 38, 39, 39}},
+	9:  {22, []int{36, 36, 36, 37, 37}},
+	10: {26, []int{43, 43, 43, 43, 44}},
+}
+
+// Chuỗi thông tin định dạng mức M với mask 0-7 (Bảng C.1 của chuẩn, đã XOR 101010000010010).
+var specFormatM = []string{
+	"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+	"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+}
+
+// Thông tin phiên bản 7-10 (Bảng D.1).
+var specVersionInfo = map[int]string{
+	7:  "000111110010010100",
+	8:  "001000010110111100",
+	9:  "001001101010011001",
+	10: "001010010011010011",
+}
+
+// specAlignment là tâm các mẫu căn chỉnh (Phụ lục E).
+var specAlignment = map[int][]int{
+	2: {6, 18}, 3: {6, 22}, 4: {6, 26}, 5: {6, 30}, 6: {6, 34},
+	7: {6, 22, 38}, 8: {6, 24, 42}, 9: {6, 26, 46}, 10: {6, 28, 50},
+}
+
+// Ví dụ "HELLO WORLD" 1-M quen thuộc: 16 codeword dữ liệu và 10 codeword sửa lỗi tương ứng.
+func TestRSRemainderKnownVector(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestQRRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"A",
+		"otpauth://totp/DevOps:an@example.com?issuer=DevOps&secret=JBSWY3DPEHPK3PXP",
+		URI("Cộng đồng Học DevOps", "nguyen.van.an+totp@devops.example.com", "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"),
+		strings.Repeat("x", 60),
+		strings.Repeat("y", 120),
+		strings.Repeat("z", 213),
+	}
+	versions := map[int]bool{}
+	for _, input := range inputs {
+		qr, err := encodeQR([]byte(input))
+		if err != nil {
+			t.Fatalf("encodeQR(%d bytes): %v", len(input), err)
+		}
+		got, version, err := decodeQR(qr.modules)
+		if err != nil {
+			t.Fatalf("decode %d bytes: %v", len(input), err)
+		}
+		if string(got) != input {
+			t.Errorf("decode = %q, want %q", got, input)
+		}
+		versions[version] = true
+	}
+	for _, v := range []int{1, 4, 7, 10} {
+		if !versions[v] {
+			t.Errorf("inputs did not cover version %d", v)
+		}
+	}
+}
+
+func TestQRTooLong(t *testing.T) {
+	if _, err := encodeQR(bytes.Repeat([]byte("x"), 214)); err != ErrQRTooLong {
+		t.Errorf("encodeQR(214 bytes) err = %v, want ErrQRTooLong", err)
+	}
+}
+
+func TestQRCodeSVG(t *testing.T) {
+	svg, err := QRCodeSVG("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Phiên bản 1 có 21 ô mỗi cạnh, cộng viền 4 ô hai bên
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29"`) {
+		t.Errorf("unexpected SVG header: %.80s", svg)
+	}
+	// Ô tối góc trên trái của finder nằm ngay sau viền
+	if !strings.Contains(svg, `d="M4 4h1v1h-1z`) {
+		t.Errorf("SVG does not start with the finder corner: %.200s", svg)
+	}
+}
+
+// decodeQR đọc dữ liệu chế độ byte của mã QR mức M, kiểm tra mọi phần cố định theo chuẩn.
+func decodeQR(modules [][]bool) ([]byte, int, error) {
+	size := len(modules)
+	version := (size - 17) / 4
+	spec, ok := specBlocksM[version]
+	if !ok || size != version*4+17 {
+		return nil, 0, fmt.Errorf("kích thước %d không hợp lệ", size)
+	}
+	at := func(x, y int) bool { return modules[y][x] }
+	bitString := func(get func(i int) bool, n int) string {
+		var sb strings.Builder
+		for i := n - 1; i >= 0; i-- {
+			if get(i) {
+				sb.WriteByte('1')
+			} else {
+				sb.WriteByte('0')
+			}
+		}
+		return sb.String()
+	}
+
+	// Hai bản sao thông tin định dạng phải giống nhau và là một chuỗi hợp lệ của mức M
+	format1 := bitString(func(i int) bool {
+		switch {
+		case i <= 5:
+			return at(8, i)
+		case i == 6:
+			return at(8, 7)
+		case i == 7:
+			return at(8, 8)
+		case i == 8:
+			return at(7, 8)
+		}
+		return at(14-i, 8)
+	}, 15)
+	format2 := bitString(func(i int) bool {
+		if i < 8 {
+			return at(size-1-i, 8)
+		}
+		return at(8, size-15+i)
+	}, 15)
+	if format1 != format2 {
+		return nil, 0, fmt.Errorf("hai bản sao định dạng khác nhau: %s, %s", format1, format2)
+	}
+	mask := -1
+	for m, s := range specFormatM {
+		if s == format1 {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		return nil, 0, fmt.Errorf("thông tin định dạng %s không phải mức M", format1)
+	}
+	if !at(8, size-8) {
+		return nil, 0, fmt.Errorf("thiếu ô tối cố định")
+	}
+
+	if want, ok := specVersionInfo[version]; ok {
+		for _, corner := range []func(i int) bool{
+			func(i int) bool { return at(size-11+i%3, i/3) },
+			func(i int) bool { return at(i/3, size-11+i%3) },
+		} {
+			if got := bitString(corner, 18); got != want {
+				return nil, 0, fmt.Errorf("thông tin phiên bản %s, want %s", got, want)
+			}
+		}
+	}
+
+	// Vùng chức năng: finder kèm viền tách và vùng định dạng, timing, căn chỉnh, thông tin phiên bản
+	reserved := make([][]bool, size)
+	for y := range reserved {
+		reserved[y] = make([]bool, size)
+	}
+	reserve := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				reserved[y][x] = true
+			}
+		}
+	}
+	reserve(0, 0, 9, 9)
+	reserve(size-8, 0, 8, 9)
+	reserve(0, size-8, 9, 8)
+	reserve(6, 0, 1, size)
+	reserve(0, 6, size, 1)
+	centers := specAlignment[version]
+	for i, cy := range centers {
+		for j, cx := range centers {
+			last := len(centers) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // Trùng finder
+			}
+			reserve(cx-2, cy-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		reserve(size-11, 0, 3, 6)
+		reserve(0, size-11, 6, 3)
+	}
+	for i := 8; i < size-8; i++ {
+		if at(i, 6) != (i%2 == 0) || at(6, i) != (i%2 == 0) {
+			return nil, 0, fmt.Errorf("timing sai tại %d", i)
+		}
+	}
+
+	totalCodewords := 0
+	for _, n := range spec.data {
+		totalCodewords += n + spec.ec
+	}
+	dataModules := 0
+	for y := range reserved {
+		for x := range reserved[y] {
+			if !reserved[y][x] {
+				dataModules++
+			}
+		}
+	}
+	if dataModules/8 != totalCodewords {
+		return nil, 0, fmt.Errorf("%d ô dữ liệu cho %d codeword", dataModules, totalCodewords)
+	}
+
+	// Đọc zigzag hai cột từ góc dưới phải, bỏ mask
+	var raw []byte
+	var cur byte
+	bits := 0
+	upward := true
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for k := 0; k < size; k++ {
+			y := k
+			if upward {
+				y = size - 1 - k
+			}
+			for x := right; x >= right-1; x-- {
+				if reserved[y][x] {
+					continue
+				}
+				dark := at(x, y) != specMask(mask, x, y)
+				cur <<= 1
+				if dark {
+					cur |= 1
+				}
+				if bits++; bits%8 == 0 {
+					raw = append(raw, cur)
+					cur = 0
+				}
+			}
+		}
+		upward = !upward
+	}
+	raw = raw[:totalCodewords]
+
+	// Tách các khối đã xen kẽ và kiểm tra syndrome Reed-Solomon của từng khối
+	blocks := make([][]byte, len(spec.data))
+	pos := 0
+	longest := spec.data[len(spec.data)-1]
+	for i := 0; i < longest; i++ {
+		for b, n := range spec.data {
+			if i < n {
+				blocks[b] = append(blocks[b], raw[pos])
+				pos++
+			}
+		}
+	}
+	for i := 0; i < spec.ec; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], raw[pos])
+			pos++
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		if !rsSyndromesZero(block, spec.ec) {
+			return nil, 0, fmt.Errorf("khối %d sai mã Reed-Solomon", b)
+		}
+		data = append(data, block[:spec.data[b]]...)
+	}
+
+	// Chế độ byte (0100), độ dài 8 bit (16 bit từ phiên bản 10), dữ liệu
+	reader := bitReader{data: data}
+	if mode := reader.read(4); mode != 0x4 {
+		return nil, 0, fmt.Errorf("chế độ %04b, want 0100", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	n := reader.read(countBits)
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(reader.read(8))
+	}
+	if reader.pos+4 <= len(data)*8 && reader.read(4) != 0 {
+		return nil, 0, fmt.Errorf("thiếu terminator")
+	}
+	return out, version, nil
+}
+
+// specMask là công thức mask của Bảng 10, i là hàng và j là cột.
+func specMask(mask, j, i int) bool {
+	switch mask {
+	case 0:
+		return (i+j)%2 == 0
+	case 1:
+		return i%2 == 0
+	case 2:
+		return j%3 == 0
+	case 3:
+		return (i+j)%3 == 0
+	case 4:
+		return (i/2+j/3)%2 == 0
+	case 5:
+		return (i*j)%2+(i*j)%3 == 0
+	case 6:
+		return ((i*j)%2+(i*j)%3)%2 == 0
+	}
+	return ((i+j)%2+(i*j)%3)%2 == 0
+}
+
+// rsSyndromesZero kiểm tra codeword(α^k) = 0 với k = 0..ec-1 trên GF(256) dùng bảng log/antilog.
+func rsSyndromesZero(codeword []byte, ec int) bool {
+	var exp [512]byte
+	var log [256]int
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = byte(x), byte(x)
+		log[x] = i
+		if x <<= 1; x >= 256 {
+			x ^= 0x11D
+		}
+	}
+	mul := func(a, b byte) byte {
+		if a == 0 || b == 0 {
+			return 0
+		}
+		return exp[log[a]+log[b]]
+	}
+	for k := 0; k < ec; k++ {
+		var s byte
+		for _, c := range codeword {
+			s = mul(s, exp[k]) ^ c
+		}
+		if s != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := (r.data[r.pos>>3] >> (7 - uint(r.pos&7))) & 1
+		v = v<<1 | int(bit)
+		r.pos++
+	}
+	return v
+}
@@ -0,0 +1,100 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret là khóa "12345678901234567890" dùng trong RFC 4226 Phụ lục D và RFC 6238 Phụ lục B.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// RFC 4226 Phụ lục D: HOTP-SHA1 6 chữ số với bộ đếm 0-9.
+func TestCodeRFC4226(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, expected := range want {
+		got, err := Code(rfcSecret, int64(counter))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", counter, err)
+		}
+		if got != expected {
+			t.Errorf("Code(%d) = %s, want %s", counter, got, expected)
+		}
+	}
+}
+
+// RFC 6238 Phụ lục B (SHA1). Bảng trong RFC dùng 8 chữ số; mã 6 chữ số là 6 chữ số cuối.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(T=%d): %v", tt.unix, err)
+		}
+		if want := tt.want[len(tt.want)-Digits:]; got != want {
+			t.Errorf("Code(T=%d) = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestCodeSecretFormat(t *testing.T) {
+	want, _ := Code(rfcSecret, 1)
+	spaced := strings.ToLower(rfcSecret[:8] + " " + rfcSecret[8:])
+	if got, err := Code(spaced, 1); err != nil || got != want {
+		t.Errorf("Code(lowercase, spaced) = %q, %v; want %q", got, err, want)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	for delta := int64(-Skew); delta <= Skew; delta++ {
+		code, _ := Code(rfcSecret, step+delta)
+		got, ok := Validate(rfcSecret, code[:3]+" "+code[3:], now)
+		if !ok || got != step+delta {
+			t.Errorf("Validate(step%+d) = %d, %v; want %d, true", delta, got, ok, step+delta)
+		}
+	}
+	for _, delta := range []int64{-Skew - 1, Skew + 1} {
+		code, _ := Code(rfcSecret, step+delta)
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Validate accepted a code %+d steps away", delta)
+		}
+	}
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("Validate accepted a short code")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("GenerateSecret() = %q: %d bytes, %v", secret, len(key), err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Học DevOps", "an@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/H%E1%BB%8Dc%20DevOps:an@example.com?issuer=H%E1%BB%8Dc+DevOps&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Errorf("URI() = %s, want %s", got, want)
+	}
+}
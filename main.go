@@ -22,6 +22,11 @@ import (
 
 	"fiber-learning-community/internal/database"
 	"fiber-learning-community/internal/handlers"
+	"fiber-learning-community/internal/mailer"
+	"fiber-learning-community/internal/oauth"
+	"fiber-learning-community/internal/ratelimit"
+	"fiber-learning-community/internal/scheduler"
+	"fiber-learning-community/internal/sessionstore"
 )
 
 var (
@@ -184,12 +189,93 @@ func init() {
 	markdownPolicy.AllowAttrs("src", "alt", "title", "loading", "width", "height", "class").OnElements("img")
 }
 
+// trustedProxyConfig đọc TRUSTED_PROXIES (IP hoặc CIDR của reverse proxy, phân tách bằng dấu phẩy)
+// và PROXY_HEADER (mặc định X-Forwarded-For). Không có proxy tin cậy thì không đọc header nào.
+func trustedProxyConfig() ([]string, string) {
+	proxies := make([]string, 0)
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if len(proxies) == 0 {
+		return nil, ""
+	}
+	header := strings.TrimSpace(os.Getenv("PROXY_HEADER"))
+	if header == "" {
+		header = fiber.HeaderXForwardedFor
+	}
+	return proxies, header
+}
+
+// corsAllowOrigins đọc danh sách origin được phép gọi API từ trình duyệt (phân tách bằng dấu phẩy).
+// Wildcard bị bỏ qua vì request mang cookie phiên.
+func corsAllowOrigins() string {
+	origins := make([]string, 0)
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOW_ORIGINS"), ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			log.Println("⚠️  CORS_ALLOW_ORIGINS: bỏ qua wildcard *, hãy liệt kê origin cụ thể")
+			continue
+		}
+		origins = append(origins, origin)
+	}
+	return strings.Join(origins, ",")
+}
+
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("Warning: .env file not found, using OS environment")
 	}
 
-	database.Init()
+	handlers.SetMarkdownRenderer(Markdown)
+	if code, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
+	db := database.Init()
+	if err := handlers.BackfillSlugs(db); err != nil {
+		log.Printf("⚠️  Slug backfill warning: %v", err)
+	}
+	if err := handlers.BackfillTags(db); err != nil {
+		log.Printf("⚠️  Tag backfill warning: %v", err)
+	}
+	if err := handlers.BackfillHandles(db); err != nil {
+		log.Printf("⚠️  Handle backfill warning: %v", err)
+	}
+	scheduler.StartPostPublisher(db, time.Minute)
+	scheduler.StartTrashPurger(db, time.Hour, handlers.TrashRetention())
+
+	sessionStorage, err := sessionstore.FromEnv(db)
+	if err != nil {
+		log.Fatalf("failed to configure session store: %v", err)
+	}
+	handlers.ConfigureSessions(sessionStorage)
+
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure mailer: %v", err)
+	}
+	mailer.SetDefault(mail)
+
+	providers, err := oauth.LoadProviders()
+	if err != nil {
+		log.Fatalf("failed to configure OAuth providers: %v", err)
+	}
+	handlers.SetOAuthProviders(providers)
+	scheduler.StartSessionExpirer(db, sessionStorage, 10*time.Minute)
+
+	limiterStore, err := ratelimit.FromEnv(db)
+	if err != nil {
+		log.Fatalf("failed to configure rate limit store: %v", err)
+	}
+	if err := handlers.ConfigureRateLimits(limiterStore); err != nil {
+		log.Fatalf("failed to configure rate limits: %v", err)
+	}
+	scheduler.StartRateLimitExpirer(limiterStore, 10*time.Minute)
 
 	engine := html.New("./views", ".html")
 	engine.AddFunc("now", func() time.Time {
@@ -224,51 +310,148 @@ func main() {
 	engine.AddFunc("trim", func(s string) string {
 		return strings.TrimSpace(s)
 	})
+	trustedProxies, proxyHeader := trustedProxyConfig()
 	app := fiber.New(fiber.Config{
 		Views:       engine,
 		ViewsLayout: "layouts/main",
+		// c.IP() (rate limit, phiên đăng nhập, token) chỉ đọc ProxyHeader khi kết nối đến từ TRUSTED_PROXIES;
+		// không cấu hình thì luôn là IP của kết nối TCP, client không giả được IP bằng header
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          trustedProxies,
+		ProxyHeader:             proxyHeader,
+		EnableIPValidation:      true,
 	})
 
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET,POST,OPTIONS",
-	}))
+	// CORS chỉ bật cho các origin được liệt kê rõ trong CORS_ALLOW_ORIGINS
+	if origins := corsAllowOrigins(); origins != "" {
+		app.Use(cors.New(cors.Config{
+			AllowOrigins:     origins,
+			AllowHeaders:     "Origin, Content-Type, Accept, Authorization, " + handlers.CSRFHeader,
+			AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+			AllowCredentials: true,
+		}))
+	}
 
 	app.Static("/static", "./public")
+	app.Use(handlers.TrackSessions())
+	app.Use(handlers.BearerAuth())
+	app.Use(handlers.CSRFProtection())
+	app.Use(handlers.RequireTwoFactorEnrollment())
 
 	app.Get("/", handlers.Home())
 	app.Get("/courses", handlers.Courses())
 	app.Get("/contributors", handlers.Contributors())
+	app.Get("/u/:handle", handlers.ProfilePage())
+	app.Get("/authors/:id", handlers.AuthorProfileRedirect())
 	app.Get("/about", handlers.About())
 	app.Get("/contribute", handlers.Contribute())
 	app.Get("/posts", handlers.PostsPage())
 	app.Get("/posts/preview", handlers.PostPreviewPage())
-	app.Get("/posts/:id", handlers.PostDetailPage())
+	app.Get("/posts/:slug", handlers.PostDetailPage())
 	app.Get("/books", handlers.BooksPage())
 	app.Get("/api/books/search", handlers.SearchBooks())
-	app.Get("/books/:id", handlers.BookDetailPage())
-	app.Get("/books/:id/read", handlers.BookReadPage())
+	app.Get("/search", handlers.SearchPage())
+	app.Get("/api/search", handlers.SearchAPI())
+	app.Get("/tags/:name", handlers.TagPage())
+	app.Get("/tags/:name/feed.xml", handlers.TagFeed("atom"))
+	app.Get("/tags/:name/rss.xml", handlers.TagFeed("rss"))
+	app.Get("/feed.xml", handlers.PostsFeed("atom"))
+	app.Get("/rss.xml", handlers.PostsFeed("rss"))
+	app.Get("/authors/:id/feed.xml", handlers.AuthorFeed("atom"))
+	app.Get("/authors/:id/rss.xml", handlers.AuthorFeed("rss"))
+	app.Get("/api/tags/autocomplete", handlers.TagAutocomplete())
+	app.Get("/books/import", handlers.BookImportPage())
+	app.Get("/books/:slug", handlers.BookDetailPage())
+	app.Get("/books/:slug/read", handlers.BookReadPage())
+	app.Get("/books/:slug/feed.xml", handlers.BookFeed("atom"))
+	app.Get("/books/:slug/rss.xml", handlers.BookFeed("rss"))
+	app.Get("/books/:slug/export.epub", handlers.BookExport("epub"))
+	app.Get("/books/:slug/export.html", handlers.BookExport("html"))
 	app.Get("/auth/register", handlers.RegisterPage())
 	app.Get("/auth/login", handlers.LoginPage())
-	app.Post("/auth/register", handlers.Register())
-	app.Post("/auth/login", handlers.Login())
+	app.Post("/auth/register", handlers.RateLimit(handlers.RateLimitRegister), handlers.Register())
+	app.Post("/auth/login", handlers.RateLimit(handlers.RateLimitLogin), handlers.Login())
 	app.Post("/auth/logout", handlers.Logout())
-	app.Post("/posts", handlers.CreatePost())
-	app.Post("/posts/:id/comments", handlers.CreateComment())
+	app.Get("/auth/csrf", handlers.CSRFToken())
+	app.Get("/auth/verify", handlers.VerifyEmail())
+	app.Post("/auth/verify/resend", handlers.RateLimit(handlers.RateLimitPasswordReset), handlers.ResendVerification())
+	app.Get("/auth/forgot", handlers.ForgotPasswordPage())
+	app.Post("/auth/forgot", handlers.RateLimit(handlers.RateLimitPasswordReset), handlers.ForgotPassword())
+	app.Get("/auth/reset", handlers.ResetPasswordPage())
+	app.Post("/auth/reset", handlers.ResetPassword())
+	app.Get("/auth/2fa", handlers.TwoFactorLoginPage())
+	app.Post("/auth/2fa", handlers.RateLimit(handlers.RateLimitLogin), handlers.VerifyTwoFactorLogin())
+	app.Get("/auth/oauth/:provider", handlers.OAuthLogin())
+	app.Get("/auth/oauth/:provider/callback", handlers.OAuthCallback())
+	app.Get("/account", handlers.AccountPage())
+	app.Get("/account/profile", handlers.ProfileSettingsPage())
+	app.Post("/account/profile", handlers.UpdateProfile())
+	app.Post("/account/identities/:provider/link", handlers.LinkIdentity())
+	app.Post("/account/identities/:id/delete", handlers.UnlinkIdentity())
+	app.Get("/account/tokens", handlers.APITokensPage())
+	app.Post("/account/tokens", handlers.CreateAPIToken())
+	app.Post("/account/tokens/:id/delete", handlers.RevokeAPIToken())
+	app.Get("/account/sessions", handlers.SessionsPage())
+	app.Get("/account/2fa", handlers.TwoFactorPage())
+	app.Post("/account/2fa/setup", handlers.StartTwoFactorSetup())
+	app.Post("/account/2fa/enable", handlers.EnableTwoFactor())
+	app.Post("/account/2fa/recovery-codes", handlers.RegenerateRecoveryCodes())
+	app.Post("/account/2fa/disable", handlers.DisableTwoFactor())
+
+	admin := app.Group("/admin", handlers.RequireAdmin())
+	admin.Get("/", handlers.AdminDashboard())
+	admin.Get("/users", handlers.AdminUsersPage())
+	admin.Post("/users/:id/role", handlers.UpdateUserRole())
+	admin.Post("/users/:id/suspend", handlers.SuspendUser())
+	admin.Post("/users/:id/unsuspend", handlers.UnsuspendUser())
+	admin.Post("/users/:id/2fa/reset", handlers.ResetUserTwoFactor())
+	admin.Post("/settings/2fa", handlers.UpdateTwoFactorPolicy())
+	admin.Get("/posts", handlers.AdminPostsPage())
+	admin.Get("/books", handlers.AdminBooksPage())
+	admin.Get("/comments", handlers.AdminCommentsPage())
+	admin.Get("/images", handlers.AdminImagesPage())
+
+	app.Post("/account/sessions/logout-others", handlers.LogoutOtherSessions())
+	app.Post("/posts", handlers.RequireScope(handlers.ScopePostsWrite), handlers.CreatePost())
+	app.Post("/posts/:id/comments", handlers.RateLimit(handlers.RateLimitComment), handlers.CreateComment())
+	app.Post("/posts/:id/comments/:commentId/edit", handlers.UpdateComment())
+	app.Post("/posts/:id/comments/:commentId/delete", handlers.DeleteComment())
+	app.Delete("/posts/:id/comments/:commentId", handlers.DeleteComment())
+	app.Post("/posts/:id/comments/:commentId/visibility", handlers.SetCommentVisibility())
 	app.Post("/posts/:id/annotations", handlers.CreateAnnotation())
-	app.Post("/posts/:id/edit", handlers.UpdatePost())
-	app.Post("/books", handlers.CreateBook())
-	app.Post("/books/:id/edit", handlers.UpdateBook())
-	app.Delete("/books/:id", handlers.DeleteBook())
-	app.Post("/books/:id/pages", handlers.CreateBookPage())
-	app.Post("/books/:bookId/pages/:pageId/edit", handlers.UpdateBookPage())
-	app.Delete("/books/:bookId/pages/:pageId", handlers.DeleteBookPage())
+	app.Post("/posts/:id/anchors/repin", handlers.RepinNote())
+	app.Post("/posts/:id/edit", handlers.RequireScope(handlers.ScopePostsWrite), handlers.UpdatePost())
+	app.Get("/posts/:id/revisions", handlers.PostRevisionsPage())
+	app.Get("/posts/:id/revisions/diff", handlers.PostRevisionDiff())
+	app.Post("/posts/:id/revisions/:revisionId/restore", handlers.RestorePostRevision())
+	app.Post("/posts/:id/delete", handlers.RequireScope(handlers.ScopePostsWrite), handlers.DeletePost())
+	app.Delete("/posts/:id", handlers.RequireScope(handlers.ScopePostsWrite), handlers.DeletePost())
+	app.Post("/posts/:id/visibility", handlers.SetPostVisibility())
+	app.Get("/trash", handlers.TrashPage())
+	app.Post("/trash/posts/:id/restore", handlers.RestorePost())
+	app.Post("/trash/posts/:id/purge", handlers.PurgePost())
+	app.Post("/books", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.CreateBook())
+	app.Post("/books/import", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.RateLimit(handlers.RateLimitUpload), handlers.ImportBook())
+	app.Post("/books/:id/edit", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.UpdateBook())
+	app.Delete("/books/:id", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.DeleteBook())
+	app.Post("/books/:id/visibility", handlers.SetBookVisibility())
+	app.Post("/books/:id/pages", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.CreateBookPage())
+	app.Post("/books/:bookId/pages/:pageId/edit", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.UpdateBookPage())
+	app.Delete("/books/:bookId/pages/:pageId", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.DeleteBookPage())
+	app.Post("/books/:id/pages/reorder", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.ReorderBookPages())
+	app.Post("/books/:id/pages/:pageId/chapter", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.AssignPageChapter())
+	app.Post("/books/:id/chapters", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.CreateBookChapter())
+	app.Post("/books/:id/chapters/:chapterId/edit", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.UpdateBookChapter())
+	app.Post("/books/:id/chapters/:chapterId/delete", handlers.RequireScope(handlers.ScopeBooksWrite), handlers.DeleteBookChapter())
+	app.Post("/books/:id/progress", handlers.SaveReadingProgress())
+	app.Get("/books/:id/reading-stats", handlers.BookReadingStats())
 	app.Post("/books/:bookId/pages/:pageId/highlights", handlers.SaveHighlight())
 	app.Get("/books/:bookId/pages/:pageId/highlights", handlers.GetHighlights())
 	app.Delete("/books/:bookId/pages/:pageId/highlights/:highlightId", handlers.DeleteHighlight())
-	app.Post("/upload/image", handlers.UploadImage())
+	app.Post("/upload/image", handlers.RequireScope(handlers.ScopeImagesWrite), handlers.RateLimit(handlers.RateLimitUpload), handlers.UploadImage())
 	app.Get("/images/:id", handlers.GetImage())
+	app.Post("/images/:id/delete", handlers.RequireScope(handlers.ScopeImagesWrite), handlers.DeleteImage())
+	app.Delete("/images/:id", handlers.RequireScope(handlers.ScopeImagesWrite), handlers.DeleteImage())
 
 	port := os.Getenv("PORT")
 	if port == "" {